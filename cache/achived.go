@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,9 +20,36 @@ type ArchivedInfo struct {
 	Scene   string
 	Score   uint32
 	Size    uint32
+	Version uint32 //当前公开的版本
+
+	Format   uint32
+	Encoding string
+	Blob     string
+}
+
+/**
+每次发布生成的存档版本，Operator为发布者，Note为修改说明
+*/
+type ArchivedVersionInfo struct {
+	BaseInfo
+	Entity   string
+	Archived string
+	Version  uint32
+	Note     string
+	File     string
+	MD5      string
+	Size     uint32
+	Format   uint32
+	Encoding string
+	Blob     string
 }
 
-func (mine *cacheContext) CreateArchived(info *EntityInfo) error {
+/**
+比较存档版本时忽略的字段
+*/
+var archivedIgnores = []string{"id", "creator", "operator", "Created", "Updated", "letters", "published"}
+
+func (mine *cacheContext) CreateArchived(info *EntityInfo, operator, note string) error {
 	if info == nil {
 		return errors.New("the entity info is nil")
 	}
@@ -37,19 +63,90 @@ func (mine *cacheContext) CreateArchived(info *EntityInfo) error {
 	db.Entity = info.UID
 	db.Scene = info.Owner
 	db.Creator = info.Creator
-	db.Operator = info.Operator
+	db.Operator = operator
 
 	db.Access = 0
 	db.Score = 0
-	var er error
-	db.File, db.MD5, db.Size, er = info.encode()
+	db.Version = nosql.GetArchivedLastVersion(info.UID) + 1
+	file, er := info.encode()
 	if er != nil {
 		return er
 	}
+	db.ArchivedFile = *file
 	er = nosql.CreateArchived(db)
+	if er != nil {
+		return er
+	}
+	_, er = mine.createArchivedVersion(db.Entity, db.UID.Hex(), operator, note, file, db.Version)
 	return er
 }
 
+func (mine *cacheContext) createArchivedVersion(entity, archived, operator, note string, file *nosql.ArchivedFile, version uint32) (string, error) {
+	db := new(nosql.ArchivedVersion)
+	db.UID = primitive.NewObjectID()
+	db.ID = nosql.GetArchivedVersionNextID()
+	db.Created = time.Now().Unix()
+	db.CreatedTime = time.Now()
+	db.Creator = operator
+	db.Operator = operator
+	db.Entity = entity
+	db.Archived = archived
+	db.Version = version
+	db.Note = note
+	db.ArchivedFile = *file
+	return db.UID.Hex(), nosql.CreateArchivedVersion(db)
+}
+
+/**
+按照版本从新到旧返回实体的存档版本，num为0时返回全部
+*/
+func (mine *cacheContext) GetArchivedVersions(entity string, num int64) []*ArchivedVersionInfo {
+	array, err := nosql.GetArchivedVersions(entity, num)
+	if err != nil {
+		return make([]*ArchivedVersionInfo, 0, 1)
+	}
+	list := make([]*ArchivedVersionInfo, 0, len(array))
+	for _, db := range array {
+		info := new(ArchivedVersionInfo)
+		info.initInfo(db)
+		list = append(list, info)
+	}
+	return list
+}
+
+func (mine *cacheContext) GetArchivedVersion(entity string, version uint32) *ArchivedVersionInfo {
+	db, err := nosql.GetArchivedVersion(entity, version)
+	if err != nil {
+		return nil
+	}
+	info := new(ArchivedVersionInfo)
+	info.initInfo(db)
+	return info
+}
+
+/**
+比较实体的两个存档版本，Before为from版本的值，After为to版本的值
+*/
+func (mine *cacheContext) DiffArchivedVersions(entity string, from, to uint32) ([]*nosql.RevisionField, error) {
+	a := mine.GetArchivedVersion(entity, from)
+	if a == nil {
+		return nil, fmt.Errorf("not found the archived of version %d", from)
+	}
+	b := mine.GetArchivedVersion(entity, to)
+	if b == nil {
+		return nil, fmt.Errorf("not found the archived of version %d", to)
+	}
+	before, err := decodeArchivedFile(a.file())
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeArchivedFile(b.file())
+	if err != nil {
+		return nil, err
+	}
+	return diffFields(jsonFields(before, archivedIgnores), jsonFields(after, archivedIgnores)), nil
+}
+
 func (mine *cacheContext) GetArchivedByEntity(entity string) *ArchivedInfo {
 	if len(entity) < 1 {
 		return nil
@@ -193,11 +290,10 @@ func (mine *ArchivedInfo) initInfo(db *nosql.Archived) bool {
 	mine.Concept = db.Concept
 	mine.Name = db.Name
 	mine.Entity = db.Entity
-	mine.File = db.File
-	mine.MD5 = db.MD5
 	mine.Scene = db.Scene
 	mine.Access = db.Access
-	mine.Size = db.Size
+	mine.Version = db.Version
+	mine.setFile(&db.ArchivedFile)
 	//if strings.Contains(mine.File,"http://rdp-down.suii.cn/") {
 	//	f := strings.Replace(mine.File, "http://rdp-down.suii.cn/", "", 1)
 	//	_ = mine.setFile(f)
@@ -205,19 +301,75 @@ func (mine *ArchivedInfo) initInfo(db *nosql.Archived) bool {
 	return true
 }
 
-func (mine *ArchivedInfo) UpdateFile(info *EntityInfo, operator string) error {
+func (mine *ArchivedInfo) file() *nosql.ArchivedFile {
+	return &nosql.ArchivedFile{File: mine.File, MD5: mine.MD5, Size: mine.Size, Format: mine.Format,
+		Encoding: mine.Encoding, Blob: mine.Blob}
+}
+
+func (mine *ArchivedInfo) setFile(file *nosql.ArchivedFile) {
+	mine.File = file.File
+	mine.MD5 = file.MD5
+	mine.Size = file.Size
+	mine.Format = file.Format
+	mine.Encoding = file.Encoding
+	mine.Blob = file.Blob
+}
+
+/**
+发布新的存档版本并作为公开的版本，没有版本记录的旧存档先记录为第一个版本，
+切换公开版本失败时删除新的版本记录，避免留下没有公开过的版本
+*/
+func (mine *ArchivedInfo) Publish(info *EntityInfo, operator, note string) error {
 	if info == nil {
 		return errors.New("the entity info is nil")
 	}
-	data, md5, size, er := info.encode()
+	last := nosql.GetArchivedLastVersion(mine.Entity)
+	if last < 1 {
+		_, err := cacheCtx.createArchivedVersion(mine.Entity, mine.UID, mine.Operator, "", mine.file(), 1)
+		if err != nil {
+			return err
+		}
+		last = 1
+	}
+	file, er := info.encode()
 	if er != nil {
 		return er
 	}
-	err := nosql.UpdateArchivedFile(mine.UID, operator, string(data), md5, size)
+	version := last + 1
+	uid, err := cacheCtx.createArchivedVersion(mine.Entity, mine.UID, operator, note, file, version)
+	if err != nil {
+		return err
+	}
+	err = mine.setVersion(operator, file, version)
+	if err != nil {
+		if er := nosql.DeleteArchivedVersion(uid); er != nil {
+			logger.Warnf("delete the archived version failed that entity = %s; version = %d; err = %s", mine.Entity, version, er.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+/**
+将公开的存档回滚到指定的版本，不影响草稿实体
+*/
+func (mine *ArchivedInfo) Rollback(version uint32, operator string) error {
+	if version == mine.Version {
+		return nil
+	}
+	info := cacheCtx.GetArchivedVersion(mine.Entity, version)
+	if info == nil {
+		return fmt.Errorf("not found the archived of version %d", version)
+	}
+	return mine.setVersion(operator, info.file(), version)
+}
+
+func (mine *ArchivedInfo) setVersion(operator string, file *nosql.ArchivedFile, version uint32) error {
+	err := nosql.UpdateArchivedVersion(mine.UID, operator, file, version)
 	if err == nil {
-		mine.File = data
-		mine.MD5 = md5
-		mine.Size = size
+		mine.setFile(file)
+		mine.Version = version
+		mine.Operator = operator
 		mine.Updated = time.Now().Unix()
 	}
 	return err
@@ -242,28 +394,50 @@ func (mine *ArchivedInfo) replaceAttribute(old, news string) error {
 			prop.Key = news
 		}
 	}
-	return mine.UpdateFile(entity, mine.Operator)
+	// 存档的内容变化需要发布为新的版本，保证版本记录与公开的存档一致
+	return mine.Publish(entity, mine.Operator, "replace the attribute "+old)
 }
 
 func (mine *ArchivedInfo) Decode() (*EntityInfo, error) {
 	entity := new(EntityInfo)
-	var data []byte
-	var err error
-	if mine.Size > 0 {
-		data, err = base64.StdEncoding.DecodeString(mine.File)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		data = []byte(mine.File)
+	data, err := decodeArchivedFile(mine.file())
+	if err != nil {
+		return nil, err
 	}
 	er := json.Unmarshal(data, entity)
 	if er != nil {
 		return nil, er
 	}
 	now := cacheCtx.GetEntity(entity.UID)
+	if now == nil {
+		return nil, errors.New("not found the entity of archived")
+	}
 	entity.Status = now.Status
 	entity.Published = true
 	entity.Access = mine.Access
 	return entity, nil
 }
+
+func (mine *ArchivedVersionInfo) initInfo(db *nosql.ArchivedVersion) {
+	mine.UID = db.UID.Hex()
+	mine.ID = db.ID
+	mine.Created = db.Created
+	mine.Updated = db.Updated
+	mine.Creator = db.Creator
+	mine.Operator = db.Operator
+	mine.Entity = db.Entity
+	mine.Archived = db.Archived
+	mine.Version = db.Version
+	mine.Note = db.Note
+	mine.File = db.File
+	mine.MD5 = db.MD5
+	mine.Size = db.Size
+	mine.Format = db.Format
+	mine.Encoding = db.Encoding
+	mine.Blob = db.Blob
+}
+
+func (mine *ArchivedVersionInfo) file() *nosql.ArchivedFile {
+	return &nosql.ArchivedFile{File: mine.File, MD5: mine.MD5, Size: mine.Size, Format: mine.Format,
+		Encoding: mine.Encoding, Blob: mine.Blob}
+}
@@ -0,0 +1,444 @@
+package cache
+
+import (
+	"errors"
+	"github.com/micro/go-micro/v2/logger"
+	"math"
+	"math/rand"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	AnalyticsDegree      = "degree"
+	AnalyticsPageRank    = "pagerank"
+	AnalyticsBetweenness = "betweenness"
+	AnalyticsComponents  = "components"
+	AnalyticsCommunities = "communities"
+)
+
+const (
+	ErrorAnalyticsComputing = "the graph analytics is computing, try again later"
+	ErrorAnalyticsTooLarge  = "the graph is too large to analyze, analyze by scene"
+)
+
+const (
+	analyticsExpire     = 600   //缓存的有效秒数
+	analyticsMaxNodes   = 50000 //可以分析的最大实体数量
+	pageRankDamping     = 0.85
+	pageRankIterations  = 100
+	pageRankTolerance   = 1e-8
+	propagateIterations = 30
+)
+
+/**
+实体关系图的分析结果，节点为实体，边为关系（忽略方向），scene为空时是全部实体
+*/
+type GraphAnalytics struct {
+	Scene       string
+	Updated     int64
+	Nodes       []string //实体UID，按照UID排序
+	Edges       uint32
+	Degree      map[string]uint32
+	PageRank    map[string]float64
+	Betweenness map[string]float64 //归一化到0~1
+	Components  [][]string         //连通分量，按照数量从大到小排序
+	Communities [][]string         //标签传播得到的社区，按照数量从大到小排序
+}
+
+/**
+排名结果，Score为对应算法的分值
+*/
+type AnalyticsPair struct {
+	Entity string
+	Score  float64
+}
+
+/**
+按照场景缓存分析结果，lock只保护缓存，计算在后台进行，同一个场景同时只有一个计算
+*/
+type analyticsCache struct {
+	lock    sync.Mutex
+	items   map[string]*GraphAnalytics
+	running map[string]bool
+	failed  map[string]error //最近一次计算失败的原因，读取后清除
+}
+
+type analyticGraph struct {
+	nodes    []string
+	index    map[string]int
+	adjacent [][]int
+	weights  []map[int]float64
+	edges    uint32
+}
+
+func (mine *analyticGraph) addEdge(from, to string, weight uint32) {
+	a, ok1 := mine.index[from]
+	b, ok2 := mine.index[to]
+	if !ok1 || !ok2 || a == b {
+		return
+	}
+	w := float64(weight)
+	if w < 1 {
+		w = 1
+	}
+	if old, had := mine.weights[a][b]; had {
+		// 两个实体之间的多条关系合并为一条边，权重累加
+		mine.weights[a][b] = old + w
+		mine.weights[b][a] = old + w
+		return
+	}
+	mine.weights[a][b] = w
+	mine.weights[b][a] = w
+	mine.adjacent[a] = append(mine.adjacent[a], b)
+	mine.adjacent[b] = append(mine.adjacent[b], a)
+	mine.edges += 1
+}
+
+/**
+获取场景下实体关系图的分析结果，结果缓存一段时间，过期或者refresh为真时在后台重新计算，
+计算期间返回之前的结果，没有结果时返回正在计算的错误
+*/
+func (mine *cacheContext) GetGraphAnalytics(scene string, refresh bool) (*GraphAnalytics, error) {
+	mine.analytics.lock.Lock()
+	defer mine.analytics.lock.Unlock()
+	if mine.analytics.items == nil {
+		mine.analytics.items = make(map[string]*GraphAnalytics, 10)
+		mine.analytics.running = make(map[string]bool, 10)
+		mine.analytics.failed = make(map[string]error, 10)
+	}
+	info, ok := mine.analytics.items[scene]
+	if ok && !refresh && time.Now().Unix()-info.Updated < analyticsExpire {
+		return info, nil
+	}
+	if err, had := mine.analytics.failed[scene]; had && !refresh {
+		delete(mine.analytics.failed, scene)
+		return nil, err
+	}
+	if !mine.analytics.running[scene] {
+		mine.analytics.running[scene] = true
+		go mine.computeAnalytics(scene)
+	}
+	if ok {
+		return info, nil
+	}
+	return nil, errors.New(ErrorAnalyticsComputing)
+}
+
+func (mine *cacheContext) computeAnalytics(scene string) {
+	var info *GraphAnalytics
+	g, err := mine.buildAnalyticGraph(scene)
+	if err == nil && len(g.nodes) > analyticsMaxNodes {
+		err = errors.New(ErrorAnalyticsTooLarge)
+	}
+	if err == nil {
+		info = g.analyze()
+		info.Scene = scene
+		logger.Infof("analyze the graph that scene = %s; nodes = %d; edges = %d", scene, len(info.Nodes), info.Edges)
+	} else {
+		logger.Warnf("analyze the graph failed that scene = %s; err = %s", scene, err.Error())
+	}
+	mine.analytics.lock.Lock()
+	defer mine.analytics.lock.Unlock()
+	delete(mine.analytics.running, scene)
+	if err != nil {
+		mine.analytics.failed[scene] = err
+		return
+	}
+	delete(mine.analytics.failed, scene)
+	mine.analytics.items[scene] = info
+}
+
+func (mine *cacheContext) buildAnalyticGraph(scene string) (*analyticGraph, error) {
+	g := &analyticGraph{index: make(map[string]int, 200)}
+	for _, entity := range mine.AllEntities() {
+		if len(scene) > 0 && entity.Owner != scene {
+			continue
+		}
+		g.nodes = append(g.nodes, entity.UID)
+	}
+	sort.Strings(g.nodes)
+	g.adjacent = make([][]int, len(g.nodes))
+	g.weights = make([]map[int]float64, len(g.nodes))
+	for i, uid := range g.nodes {
+		g.index[uid] = i
+		g.weights[i] = make(map[int]float64, 5)
+	}
+	edges, err := nosql.GetAllVEdges()
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		if edge.Deleted > 0 {
+			continue
+		}
+		g.addEdge(edge.Source, edge.Target.Entity, edge.Weight)
+	}
+	for _, list := range g.adjacent {
+		sort.Ints(list)
+	}
+	return g, nil
+}
+
+func (mine *analyticGraph) analyze() *GraphAnalytics {
+	info := new(GraphAnalytics)
+	info.Updated = time.Now().Unix()
+	info.Nodes = mine.nodes
+	info.Edges = mine.edges
+	info.Degree = make(map[string]uint32, len(mine.nodes))
+	for i, uid := range mine.nodes {
+		info.Degree[uid] = uint32(len(mine.adjacent[i]))
+	}
+	info.PageRank = mine.switchScores(mine.pageRank())
+	info.Betweenness = mine.switchScores(mine.betweenness())
+	info.Components = mine.switchGroups(mine.components())
+	info.Communities = mine.switchGroups(mine.propagate())
+	return info
+}
+
+func (mine *analyticGraph) switchScores(scores []float64) map[string]float64 {
+	tmp := make(map[string]float64, len(scores))
+	for i, score := range scores {
+		tmp[mine.nodes[i]] = score
+	}
+	return tmp
+}
+
+/**
+labels为每个节点所属分组的编号，返回按照数量从大到小排序的分组
+*/
+func (mine *analyticGraph) switchGroups(labels []int) [][]string {
+	groups := make(map[int][]string, 10)
+	for i, label := range labels {
+		groups[label] = append(groups[label], mine.nodes[i])
+	}
+	list := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, group)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i]) != len(list[j]) {
+			return len(list[i]) > len(list[j])
+		}
+		return list[i][0] < list[j][0]
+	})
+	return list
+}
+
+/**
+按照关系权重加权的PageRank，没有关系的节点将分值平均分给所有节点
+*/
+func (mine *analyticGraph) pageRank() []float64 {
+	count := len(mine.nodes)
+	ranks := make([]float64, count)
+	if count < 1 {
+		return ranks
+	}
+	totals := make([]float64, count)
+	for i := range mine.nodes {
+		ranks[i] = 1.0 / float64(count)
+		for _, w := range mine.weights[i] {
+			totals[i] += w
+		}
+	}
+	for k := 0; k < pageRankIterations; k += 1 {
+		dangling := 0.0
+		for i := range mine.nodes {
+			if totals[i] == 0 {
+				dangling += ranks[i]
+			}
+		}
+		next := make([]float64, count)
+		base := (1-pageRankDamping)/float64(count) + pageRankDamping*dangling/float64(count)
+		for i := range mine.nodes {
+			next[i] = base
+		}
+		for i := range mine.nodes {
+			for _, j := range mine.adjacent[i] {
+				next[j] += pageRankDamping * ranks[i] * mine.weights[i][j] / totals[i]
+			}
+		}
+		diff := 0.0
+		for i := range mine.nodes {
+			diff += math.Abs(next[i] - ranks[i])
+		}
+		ranks = next
+		if diff < pageRankTolerance {
+			break
+		}
+	}
+	return ranks
+}
+
+/**
+Brandes算法计算无权的中介中心性，并按照(n-1)(n-2)/2归一化
+*/
+func (mine *analyticGraph) betweenness() []float64 {
+	count := len(mine.nodes)
+	scores := make([]float64, count)
+	for s := 0; s < count; s += 1 {
+		stack := make([]int, 0, count)
+		previous := make([][]int, count)
+		sigma := make([]float64, count)
+		distance := make([]int, count)
+		for i := range distance {
+			distance[i] = -1
+		}
+		sigma[s] = 1
+		distance[s] = 0
+		queue := []int{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range mine.adjacent[v] {
+				if distance[w] < 0 {
+					distance[w] = distance[v] + 1
+					queue = append(queue, w)
+				}
+				if distance[w] == distance[v]+1 {
+					sigma[w] += sigma[v]
+					previous[w] = append(previous[w], v)
+				}
+			}
+		}
+		delta := make([]float64, count)
+		for i := len(stack) - 1; i >= 0; i -= 1 {
+			w := stack[i]
+			for _, v := range previous[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				scores[w] += delta[w]
+			}
+		}
+	}
+	if count > 2 {
+		// 无向图中每对节点计算了两次
+		scale := float64((count-1)*(count-2)) / 2
+		for i := range scores {
+			scores[i] = scores[i] / 2 / scale
+		}
+	}
+	return scores
+}
+
+func (mine *analyticGraph) components() []int {
+	labels := make([]int, len(mine.nodes))
+	for i := range labels {
+		labels[i] = -1
+	}
+	for i := range mine.nodes {
+		if labels[i] >= 0 {
+			continue
+		}
+		labels[i] = i
+		queue := []int{i}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			for _, w := range mine.adjacent[v] {
+				if labels[w] < 0 {
+					labels[w] = i
+					queue = append(queue, w)
+				}
+			}
+		}
+	}
+	return labels
+}
+
+/**
+标签传播发现社区，每个节点采用邻居中权重之和最大的标签，相同时保留当前标签或者随机选择，
+随机数使用固定的种子，保证相同的数据得到相同的结果
+*/
+func (mine *analyticGraph) propagate() []int {
+	labels := make([]int, len(mine.nodes))
+	order := make([]int, len(mine.nodes))
+	for i := range labels {
+		labels[i] = i
+		order[i] = i
+	}
+	random := rand.New(rand.NewSource(1))
+	for k := 0; k < propagateIterations; k += 1 {
+		changed := false
+		random.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		for _, i := range order {
+			if len(mine.adjacent[i]) < 1 {
+				continue
+			}
+			sums := make(map[int]float64, len(mine.adjacent[i]))
+			for _, j := range mine.adjacent[i] {
+				sums[labels[j]] += mine.weights[i][j]
+			}
+			max := 0.0
+			for _, sum := range sums {
+				if sum > max {
+					max = sum
+				}
+			}
+			if sums[labels[i]] == max {
+				continue
+			}
+			best := make([]int, 0, len(sums))
+			for label, sum := range sums {
+				if sum == max {
+					best = append(best, label)
+				}
+			}
+			sort.Ints(best)
+			labels[i] = best[random.Intn(len(best))]
+			changed = true
+		}
+		if !changed {
+			break
+		}
+	}
+	return labels
+}
+
+/**
+按照分值从高到低排序后返回前num个
+*/
+func (mine *GraphAnalytics) Top(scores map[string]float64, num int) []*AnalyticsPair {
+	list := make([]*AnalyticsPair, 0, len(scores))
+	for uid, score := range scores {
+		list = append(list, &AnalyticsPair{Entity: uid, Score: score})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Entity < list[j].Entity
+	})
+	if num > 0 && len(list) > num {
+		list = list[:num]
+	}
+	return list
+}
+
+func (mine *GraphAnalytics) DegreeScores() map[string]float64 {
+	tmp := make(map[string]float64, len(mine.Degree))
+	for uid, degree := range mine.Degree {
+		tmp[uid] = float64(degree)
+	}
+	return tmp
+}
+
+/**
+实体所在的分组，没有找到时返回nil
+*/
+func (mine *GraphAnalytics) GroupOf(groups [][]string, uid string) []string {
+	for _, group := range groups {
+		for _, item := range group {
+			if item == uid {
+				return group
+			}
+		}
+	}
+	return nil
+}
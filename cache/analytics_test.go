@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"omo.msa.vocabulary/proxy"
+	"testing"
+	"time"
+)
+
+func waitAnalytics(t *testing.T, ctx *cacheContext, scene string) *GraphAnalytics {
+	t.Helper()
+	for i := 0; i < 100; i += 1 {
+		info, err := ctx.GetGraphAnalytics(scene, false)
+		if err == nil {
+			return info
+		}
+		if err.Error() != ErrorAnalyticsComputing {
+			t.Fatalf("get analytics: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the analytics is not computed")
+	return nil
+}
+
+func TestGraphAnalyticsBackground(t *testing.T) {
+	ctx := initTestContext(t)
+	a := newTestEntity(t, "a")
+	b := newTestEntity(t, "b")
+	if _, err := ctx.CreateVEdge(a.UID, a.UID, "r", "", "", "tester", 0, 1, 0,
+		proxy.VNode{Name: "b", Entity: b.UID}); err != nil {
+		t.Fatalf("create edge: %v", err)
+	}
+	if _, err := ctx.GetGraphAnalytics("", false); err == nil || err.Error() != ErrorAnalyticsComputing {
+		t.Fatalf("the first analytics err = %v, want computing", err)
+	}
+	info := waitAnalytics(t, ctx, "")
+	if info.Edges != 1 || info.Degree[a.UID] != 1 {
+		t.Errorf("the analytics edges = %d; degree = %d, want 1 and 1", info.Edges, info.Degree[a.UID])
+	}
+	// 重新计算时返回之前的结果
+	again, err := ctx.GetGraphAnalytics("", true)
+	if err != nil || again != info {
+		t.Errorf("refresh the analytics = %v, %v, want the cached result", again, err)
+	}
+	for i := 0; i < 100; i += 1 {
+		ctx.analytics.lock.Lock()
+		running := ctx.analytics.running[""]
+		ctx.analytics.lock.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the refresh of analytics is not finished")
+}
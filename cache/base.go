@@ -4,12 +4,12 @@ import (
 	"github.com/micro/go-micro/v2/logger"
 	"github.com/mozillazg/go-pinyin"
 	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy"
 	"omo.msa.vocabulary/proxy/graph"
 	"omo.msa.vocabulary/proxy/nosql"
 	"omo.msa.vocabulary/tool"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 	"unicode"
 )
@@ -53,34 +53,15 @@ type FileInfo struct {
 	Type        string    `json:"contentType" bson:"contentType"`
 }
 
-type NodeTemp struct {
-	Entity  string
-	Name    string
-	Cover   string
-	Concept string
-}
-
-type LinkTemp struct {
-	UUID      string
-	From      string
-	To        string
-	Kind      LinkType
-	Relation  string
-	Name      string
-	Direction uint8
-	Weight    uint32
-}
-
-type CountMap struct {
-	Map   sync.Map
-	Count uint32
-}
-
 type cacheContext struct {
 	graph        *GraphInfo
 	entityTables []string
-	nodesMap     *CountMap
-	linkMap      *CountMap
+	syncing      int32
+	analytics    analyticsCache
+	integrity    integrityState
+	migrating    int32
+	notifier     WorkflowNotifier
+	review       reviewState
 }
 
 var cacheCtx *cacheContext
@@ -92,20 +73,21 @@ func InitData() error {
 	cacheCtx.entityTables = append(cacheCtx.entityTables, DefaultEntityTable)
 	cacheCtx.entityTables = append(cacheCtx.entityTables, UserEntityTable)
 	cacheCtx.graph = new(GraphInfo)
-	cacheCtx.nodesMap = new(CountMap)
-	cacheCtx.linkMap = new(CountMap)
 	cacheCtx.graph.construct()
 
-	err := nosql.InitDB(config.Schema.Database.IP, config.Schema.Database.Port, config.Schema.Database.Name, config.Schema.Database.Type)
+	err := nosql.InitDB(config.Schema.Database.IP, config.Schema.Database.Port, config.Schema.Database.Name, config.Schema.Database.Type,
+		cacheCtx.entityTables)
 	if nil != err {
 		return err
 	}
-	err1 := graph.InitNeo4J(&config.Schema.Graph)
+	graph.SetLabels(conceptLabels())
+	graph.SetKinds(linkKinds())
+	err1 := proxy.InitGraph(&config.Schema.Graph)
 	if err1 != nil {
 		return err1
 	}
 
-	logger.Infof("init graph!!! node number = %d,link number = %d", len(cacheCtx.graph.nodes), len(cacheCtx.graph.links))
+	logger.Infof("init graph!!! node number = %d,link number = %d", len(cacheCtx.graph.Nodes()), len(cacheCtx.graph.Links()))
 	return nil
 }
 
@@ -113,15 +95,26 @@ func Context() *cacheContext {
 	return cacheCtx
 }
 
-func switchAttributes() {
+/**
+清理、迁移等管理操作只允许配置的管理员执行
+*/
+func (mine *cacheContext) IsAdmin(operator string) bool {
+	return len(operator) > 0 && tool.HasItem(config.Schema.Basic.Admins, operator)
+}
+
+func switchAttributes() error {
 	info := cacheCtx.GetAttribute("60b9908fa0449d245dbde674")
 	if info == nil {
-		return
+		return nil
 	}
 	list := cacheCtx.getEntitiesByAttribute("60a4e2e36956c7f1bbe32414")
 	for _, item := range list {
-		item.replaceAttribute("60a4e2e36956c7f1bbe32414", "60b9908fa0449d245dbde674")
+		err := item.replaceAttribute("60a4e2e36956c7f1bbe32414", "60b9908fa0449d245dbde674")
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func CheckBoxes() {
@@ -132,8 +125,11 @@ func CheckBoxes() {
 	}
 }
 
-func CheckRepeatedAttribute() {
-	all, _ := nosql.GetAllAttributes()
+func CheckRepeatedAttribute() error {
+	all, err := nosql.GetAllAttributes()
+	if err != nil {
+		return err
+	}
 	list := make([]*nosql.Attribute, 0, 100)
 	repeats := make([]*nosql.Attribute, 0, 100)
 	for _, item := range all {
@@ -164,6 +160,7 @@ func CheckRepeatedAttribute() {
 			_ = cacheCtx.RemoveAttribute(uid, repeat.Operator)
 		}
 	}
+	return nil
 }
 
 func getAttributeUID(name string, list []*nosql.Attribute) string {
@@ -186,21 +183,26 @@ func hadOne(name string, list []*nosql.Attribute) bool {
 	return false
 }
 
-func checkEntityLetters() {
+func checkEntityLetters() error {
 	for _, table := range cacheCtx.entityTables {
 		all, er := nosql.GetEntities(table)
-		if er == nil {
-			for _, entity := range all {
-				if len(entity.FirstLetters) < 2 {
-					letter := firstLetter(entity.Name)
-					_ = nosql.UpdateEntityLetter(table, entity.UID.Hex(), letter)
-				}
+		if er != nil {
+			return er
+		}
+		for _, entity := range all {
+			if len(entity.FirstLetters) < 2 {
+				letter := firstLetter(entity.Name)
+				_ = nosql.UpdateEntityLetter(table, entity.UID.Hex(), letter)
 			}
 		}
 	}
+	return nil
 }
 
-func checkSequence() {
+/**
+修复旧的序列名称并且与各个表中已有的最大ID对齐，避免重复的ID，不认识的序列(比如其他实体表的)保持不变
+*/
+func CheckSequence() error {
 	arr := make([]string, 0, 6)
 	arr = append(arr, "voc_"+nosql.TableArchived)
 	arr = append(arr, "voc_"+nosql.TableAttribute)
@@ -217,24 +219,25 @@ func checkSequence() {
 		}
 	}
 
-	arr2 := make([]string, 0, 6)
-	arr2 = append(arr2, nosql.TableArchived)
-	arr2 = append(arr2, nosql.TableAttribute)
-	arr2 = append(arr2, nosql.TableBox)
-	arr2 = append(arr2, nosql.TableConcept)
-	arr2 = append(arr2, nosql.TableEvent)
-	arr2 = append(arr2, nosql.TableRelation)
-	arr2 = append(arr2, nosql.TableRelationCase)
-	arr2 = append(arr2, nosql.TableSequence)
-	arr2 = append(arr2, nosql.TableAddress)
-	arr2 = append(arr2, DefaultEntityTable)
-	arr2 = append(arr2, DefaultEntityTable+"_school")
-	all2, _ := nosql.GetAllSequences()
-	for _, s := range all2 {
-		if !tool.HasItem(arr2, s.Name) {
-			_ = nosql.DeleteSequence(s.UID.Hex())
-		}
-	}
+	tables := make([]string, 0, 20)
+	tables = append(tables, nosql.TableArchived)
+	tables = append(tables, nosql.TableAttribute)
+	tables = append(tables, nosql.TableBox)
+	tables = append(tables, nosql.TableConcept)
+	tables = append(tables, nosql.TableEvent)
+	tables = append(tables, nosql.TableRelation)
+	tables = append(tables, nosql.TableRelationCase)
+	tables = append(tables, nosql.TableAddress)
+	tables = append(tables, nosql.TableRecord)
+	tables = append(tables, nosql.TableEdge)
+	tables = append(tables, nosql.TableExamine)
+	tables = append(tables, nosql.TableArchivedVersion)
+	tables = append(tables, nosql.TableRevision)
+	tables = append(tables, nosql.TableImportJob)
+	tables = append(tables, DefaultEntityTable)
+	tables = append(tables, UserEntityTable)
+
+	return nosql.RepairSequences(tables)
 }
 
 func HadChinese(str string) bool {
@@ -248,93 +251,15 @@ func HadChinese(str string) bool {
 	return count > 0
 }
 
-func (mine *cacheContext) addSyncNode(uid, name, concept, cover string) {
-	tmp := NodeTemp{
-		Entity:  uid,
-		Name:    name,
-		Concept: concept,
-		Cover:   cover,
-	}
-	mine.nodesMap.Map.Store(uid, &tmp)
-	mine.nodesMap.Count += 1
-}
-
-func (mine *cacheContext) addSyncLink(from, to, relation, name string, kind LinkType, dir uint8) {
-	tmp := LinkTemp{
-		UUID:      from + "-" + to,
-		From:      from,
-		To:        to,
-		Relation:  relation,
-		Kind:      kind,
-		Name:      name,
-		Direction: dir,
-	}
-	mine.linkMap.Map.Store(tmp.UUID, &tmp)
-	mine.linkMap.Count += 1
-}
-
-func (mine *CountMap) deleteSyncNode(uid string) {
-	mine.Map.Delete(uid)
-	if mine.Count > 0 {
-		mine.Count -= 1
-	}
-}
-
-func (mine *CountMap) getSyncNode(uid string) *NodeTemp {
-	info, ok := mine.Map.Load(uid)
-	if ok {
-		return info.(*NodeTemp)
-	}
-	return nil
-}
-
 func (mine *cacheContext) EntityTables() []string {
 	return mine.entityTables
 }
 
-func (mine *cacheContext) CheckSyncNodes() {
-	if mine.nodesMap.Count < 1 {
-		return
-	}
-	array := make([]string, 0, 20)
-	call := func(key interface{}, val interface{}) bool {
-		item := val.(*NodeTemp)
-		_, err := mine.graph.CreateNode(0, item.Name, item.Entity, item.Cover, item.Concept, nil)
-		if err == nil {
-			array = append(array, item.Entity)
-		}
-		return true
-	}
-	mine.nodesMap.Map.Range(call)
-	for i := 0; i < len(array); i += 1 {
-		mine.nodesMap.deleteSyncNode(array[i])
-	}
-}
-
-func (mine *cacheContext) CheckSyncLinks() {
-	if mine.linkMap.Count < 1 {
-		return
-	}
-	array := make([]string, 0, 20)
-	call := func(key interface{}, val interface{}) bool {
-		item := val.(*LinkTemp)
-		err := mine.createLink(item.From, item.To, item.Kind, item.Relation, item.Name, item.Direction, item.Weight)
-		if err == nil {
-			array = append(array, item.UUID)
-		}
-		return true
-	}
-	mine.linkMap.Map.Range(call)
-	for i := 0; i < len(array); i += 1 {
-		mine.linkMap.deleteSyncNode(array[i])
-	}
-}
-
-func (mine *cacheContext) createLink(from, to string, kind LinkType, relationUID, name string, dire uint8, weight uint32) error {
-	fromNode := mine.GetGraphNode(from)
-	toNode := mine.GetGraphNode(to)
-	_, err := mine.graph.CreateLink(fromNode, toNode, kind, name, relationUID, DirectionType(dire), weight)
-	return err
+/**
+返回启动时索引同步的结果，索引只在InitDB时创建
+*/
+func (mine *cacheContext) GetIndexReport() *nosql.IndexReport {
+	return nosql.GetIndexReport()
 }
 
 func StringToUint32(str string) uint32 {
@@ -352,13 +277,12 @@ func stringToUint8(str string) uint8 {
 	return uint8(num)
 }
 
+/**
+Excel的天数从1899/12/30开始计算
+*/
 func convertExcelDays(days int64) (year uint16, month uint8) {
-	y := days / 366
-	rest := days - (y * 365) - (y / 4)
-	m := rest / 30
-	year = uint16(y + 1900)
-	month = uint8(m) + 1
-	return year, month
+	date := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days))
+	return uint16(date.Year()), uint8(date.Month())
 }
 
 func parseDate(date string) (year uint16, month uint8) {
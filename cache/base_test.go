@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"omo.msa.vocabulary/config"
+	"testing"
+)
+
+/**
+使用内存存储初始化缓存，每个测试的数据互不影响
+*/
+func initTestContext(t *testing.T) *cacheContext {
+	t.Helper()
+	config.Schema.Database.Type = "memory"
+	config.Schema.Graph.Type = "memory"
+	if err := InitData(); err != nil {
+		t.Fatalf("init the cache failed: %v", err)
+	}
+	return Context()
+}
+
+func newTestEntity(t *testing.T, name string) *EntityInfo {
+	t.Helper()
+	info := new(EntityInfo)
+	info.Name = name
+	info.Creator = "tester"
+	info.Operator = "tester"
+	if err := Context().CreateEntity(info, nil); err != nil {
+		t.Fatalf("create the entity failed: %v", err)
+	}
+	return info
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/micro/go-micro/v2/logger"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"math"
+	"omo.msa.vocabulary/config"
 	"omo.msa.vocabulary/proxy"
 	"omo.msa.vocabulary/proxy/nosql"
 	"omo.msa.vocabulary/tool"
@@ -317,6 +318,9 @@ func (mine *cacheContext) UpdateBoxContentStatus(entity string, st EntityStatus,
 }
 
 func (mine *cacheContext) CreateBox(info *BoxInfo) error {
+	if len(info.Workflow) > 0 && config.Schema.GetWorkflow(info.Workflow) == nil {
+		return errors.New("not found the workflow of " + info.Workflow)
+	}
 	db := new(nosql.Box)
 	db.UID = primitive.NewObjectID()
 	db.ID = nosql.GetBoxNextID()
@@ -361,14 +365,6 @@ func (mine *cacheContext) HadBoxByName(name string) bool {
 	return had
 }
 
-func (mine *cacheContext) checkEntityFromBoxes(uid, name string) {
-	boxes := mine.GetBoxesByKeyword(uid)
-	for _, box := range boxes {
-		_ = box.RemoveKeyword(uid)
-		_ = box.AppendKeyword(name)
-	}
-}
-
 //endregion
 
 //region Base Fun
@@ -390,6 +386,7 @@ func (mine *BoxInfo) initInfo(db *nosql.Box) {
 	mine.Owner = db.Owner
 	mine.Workflow = db.Workflow
 	mine.Users = db.Users
+	mine.Reviewers = db.Reviewers
 	if len(mine.Owner) < 1 {
 		_ = mine.updateOwner(DefaultOwner)
 	}
@@ -517,6 +514,25 @@ func (mine *BoxInfo) UpdateConcept(con, operator string) error {
 	return nil
 }
 
+/**
+更新收藏夹使用的工作流，为空时不限制实体状态的变化
+*/
+func (mine *BoxInfo) UpdateWorkflow(workflow, operator string) error {
+	if mine.Workflow == workflow {
+		return nil
+	}
+	if len(workflow) > 0 && config.Schema.GetWorkflow(workflow) == nil {
+		return errors.New("not found the workflow of " + workflow)
+	}
+	err := nosql.UpdateBoxWorkflow(mine.UID, operator, workflow)
+	if err == nil {
+		mine.Workflow = workflow
+		mine.Operator = operator
+		mine.Updated = time.Now().Unix()
+	}
+	return err
+}
+
 func (mine *BoxInfo) HadPublished() bool {
 	for _, content := range mine.Contents {
 		if content.Status == uint8(EntityStatusUsable) {
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"io/ioutil"
+	"omo.msa.vocabulary/proxy/nosql"
+	"omo.msa.vocabulary/tool"
+)
+
+const (
+	ArchivedFormatLegacy uint32 = 0 //没有版本号的早期存档
+	ArchivedFormatV1     uint32 = 1
+	ArchivedFormatLatest        = ArchivedFormatV1
+)
+
+const (
+	ArchivedEncodingBase64 = ""     //早期存档，base64编码的JSON，大小为0时为JSON原文
+	ArchivedEncodingGzip   = "gzip" //gzip压缩后base64编码，或者保存在GridFS中
+)
+
+const archivedBlobSize = 256 * 1024 //压缩后超过该大小的存档保存到GridFS
+
+/**
+存档数据结构的升级，key为升级前的版本，升级后版本加1
+*/
+var archivedUpgrades = map[uint32]func(map[string]json.RawMessage) error{
+	ArchivedFormatLegacy: upgradeArchivedLegacy,
+}
+
+/**
+早期存档中没有数据的数组为null
+*/
+func upgradeArchivedLegacy(doc map[string]json.RawMessage) error {
+	for _, key := range []string{"links", "synonyms", "tags", "relates", "properties", "events", "relations"} {
+		if value, ok := doc[key]; !ok || string(value) == "null" {
+			doc[key] = json.RawMessage("[]")
+		}
+	}
+	return nil
+}
+
+/**
+将实体的JSON编码为最新格式的存档文件
+*/
+func encodeArchivedFile(name string, data []byte) (*nosql.ArchivedFile, error) {
+	file := new(nosql.ArchivedFile)
+	file.MD5 = tool.CalculateMD5(data)
+	file.Size = uint32(len(data))
+	file.Format = ArchivedFormatLatest
+	file.Encoding = ArchivedEncodingGzip
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	if buf.Len() > archivedBlobSize {
+		file.Blob, err = nosql.UploadBlob(nosql.BucketArchived, name, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	file.File = base64.StdEncoding.EncodeToString(buf.Bytes())
+	return file, nil
+}
+
+/**
+读取存档文件中的JSON原文，不做升级
+*/
+func readArchivedFile(file *nosql.ArchivedFile) ([]byte, error) {
+	switch file.Encoding {
+	case ArchivedEncodingBase64:
+		if file.Size > 0 {
+			return base64.StdEncoding.DecodeString(file.File)
+		}
+		return []byte(file.File), nil
+	case ArchivedEncodingGzip:
+		var body []byte
+		var err error
+		if len(file.Blob) > 0 {
+			body, err = nosql.DownloadBlob(nosql.BucketArchived, file.Blob)
+		} else {
+			body, err = base64.StdEncoding.DecodeString(file.File)
+		}
+		if err != nil {
+			return nil, err
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("not support the archived encoding of %s", file.Encoding)
+	}
+}
+
+/**
+读取存档文件并升级到最新的数据结构
+*/
+func decodeArchivedFile(file *nosql.ArchivedFile) ([]byte, error) {
+	data, err := readArchivedFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return upgradeArchivedData(file.Format, data)
+}
+
+func upgradeArchivedData(format uint32, data []byte) ([]byte, error) {
+	if format == ArchivedFormatLatest {
+		return data, nil
+	}
+	if format > ArchivedFormatLatest {
+		return nil, fmt.Errorf("the archived format %d is newer than %d", format, ArchivedFormatLatest)
+	}
+	doc := make(map[string]json.RawMessage, 30)
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	for ; format < ArchivedFormatLatest; format += 1 {
+		fun, ok := archivedUpgrades[format]
+		if !ok {
+			return nil, fmt.Errorf("not found the upgrade of archived format %d", format)
+		}
+		err = fun(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
+
+/**
+存档迁移的结果
+*/
+type ArchivedMigration struct {
+	Total    uint32
+	Migrated uint32
+	Latest   uint32   //已经是最新格式
+	Mismatch []string //MD5校验失败的存档或者版本
+	Failed   []string
+}
+
+/**
+将存档和存档版本重新编码为最新的格式，编码前校验原文的MD5，写入后再次读取校验，
+校验失败的数据保持不变，verify为真时只校验不写入
+*/
+func (mine *cacheContext) MigrateArchives(verify bool) (*ArchivedMigration, error) {
+	result := &ArchivedMigration{Mismatch: make([]string, 0, 5), Failed: make([]string, 0, 5)}
+	archives, err := nosql.GetAllArchived()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := nosql.GetAllArchivedVersions()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range archives {
+		file := item.ArchivedFile
+		mine.migrateArchivedFile(result, item.UID.Hex(), item.Entity, &file, verify, func(tmp *nosql.ArchivedFile) error {
+			return nosql.UpdateArchivedFile(item.UID.Hex(), item.Operator, tmp)
+		}, func() (*nosql.ArchivedFile, error) {
+			db, er := nosql.GetArchived(item.UID.Hex())
+			if er != nil {
+				return nil, er
+			}
+			return &db.ArchivedFile, nil
+		})
+	}
+	for _, item := range versions {
+		file := item.ArchivedFile
+		mine.migrateArchivedFile(result, item.UID.Hex(), item.Entity, &file, verify, func(tmp *nosql.ArchivedFile) error {
+			return nosql.UpdateArchivedVersionFile(item.UID.Hex(), tmp)
+		}, func() (*nosql.ArchivedFile, error) {
+			db, er := nosql.GetArchivedVersion(item.Entity, item.Version)
+			if er != nil {
+				return nil, er
+			}
+			return &db.ArchivedFile, nil
+		})
+	}
+	logger.Infof("migrate the archives done that total = %d; migrated = %d; latest = %d; mismatch = %d; failed = %d",
+		result.Total, result.Migrated, result.Latest, len(result.Mismatch), len(result.Failed))
+	return result, nil
+}
+
+func (mine *cacheContext) migrateArchivedFile(result *ArchivedMigration, uid, entity string, file *nosql.ArchivedFile, verify bool,
+	save func(*nosql.ArchivedFile) error, load func() (*nosql.ArchivedFile, error)) {
+	result.Total += 1
+	data, err := readArchivedFile(file)
+	if err != nil {
+		result.Failed = append(result.Failed, uid)
+		logger.Warnf("read the archived failed that uid = %s; err = %s", uid, err.Error())
+		return
+	}
+	if len(file.MD5) > 0 && tool.CalculateMD5(data) != file.MD5 {
+		result.Mismatch = append(result.Mismatch, uid)
+		logger.Warnf("the md5 of archived is mismatch that uid = %s", uid)
+		return
+	}
+	if file.Format == ArchivedFormatLatest && file.Encoding == ArchivedEncodingGzip {
+		result.Latest += 1
+		return
+	}
+	if verify {
+		return
+	}
+	data, err = upgradeArchivedData(file.Format, data)
+	if err == nil {
+		file, err = encodeArchivedFile(entity, data)
+	}
+	if err == nil {
+		err = save(file)
+	}
+	if err == nil {
+		err = checkArchivedFile(load, file.MD5)
+	}
+	if err != nil {
+		result.Failed = append(result.Failed, uid)
+		logger.Warnf("migrate the archived failed that uid = %s; err = %s", uid, err.Error())
+		return
+	}
+	result.Migrated += 1
+}
+
+func checkArchivedFile(load func() (*nosql.ArchivedFile, error), md5 string) error {
+	file, err := load()
+	if err != nil {
+		return err
+	}
+	data, err := readArchivedFile(file)
+	if err != nil {
+		return err
+	}
+	if tool.CalculateMD5(data) != md5 {
+		return errors.New("the md5 is mismatch after migrate")
+	}
+	return nil
+}
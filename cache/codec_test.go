@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/rand"
+	"omo.msa.vocabulary/proxy/nosql"
+	"omo.msa.vocabulary/tool"
+	"testing"
+)
+
+func TestArchivedFileRoundTrip(t *testing.T) {
+	initTestContext(t)
+	noise := make([]byte, archivedBlobSize*2)
+	rand.New(rand.NewSource(1)).Read(noise)
+	cases := []struct {
+		name string
+		data []byte
+		blob bool
+	}{
+		{"inline", []byte(`{"name":"test","tags":["a"]}`), false},
+		{"blob", []byte(`{"name":"` + base64.StdEncoding.EncodeToString(noise) + `"}`), true},
+	}
+	for _, c := range cases {
+		file, err := encodeArchivedFile("entity", c.data)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", c.name, err)
+		}
+		if (len(file.Blob) > 0) != c.blob || file.Encoding != ArchivedEncodingGzip || file.Format != ArchivedFormatLatest {
+			t.Errorf("%s: the encoded file = blob %q, encoding %q, format %d", c.name, file.Blob, file.Encoding, file.Format)
+		}
+		if file.Size != uint32(len(c.data)) || file.MD5 != tool.CalculateMD5(c.data) {
+			t.Errorf("%s: the size or md5 is not of the raw json", c.name)
+		}
+		got, err := decodeArchivedFile(file)
+		if err != nil || string(got) != string(c.data) {
+			t.Errorf("%s: decode = %d bytes, %v", c.name, len(got), err)
+		}
+	}
+}
+
+func TestReadLegacyArchivedFile(t *testing.T) {
+	data := []byte(`{"name":"test","tags":null}`)
+	cases := []struct {
+		name string
+		file *nosql.ArchivedFile
+	}{
+		{"base64", &nosql.ArchivedFile{File: base64.StdEncoding.EncodeToString(data), Size: uint32(len(data))}},
+		{"raw json", &nosql.ArchivedFile{File: string(data)}},
+	}
+	for _, c := range cases {
+		got, err := readArchivedFile(c.file)
+		if err != nil || string(got) != string(data) {
+			t.Errorf("%s: read = %s, %v", c.name, got, err)
+		}
+		upgraded, err := decodeArchivedFile(c.file)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", c.name, err)
+		}
+		doc := make(map[string]json.RawMessage)
+		_ = json.Unmarshal(upgraded, &doc)
+		if string(doc["tags"]) != "[]" || string(doc["properties"]) != "[]" {
+			t.Errorf("%s: the null arrays are not upgraded: %s", c.name, upgraded)
+		}
+	}
+	if _, err := readArchivedFile(&nosql.ArchivedFile{Encoding: "zip"}); err == nil {
+		t.Error("the unknown encoding should fail")
+	}
+	if _, err := upgradeArchivedData(ArchivedFormatLatest+1, data); err == nil {
+		t.Error("the newer format should fail")
+	}
+}
+
+func newTestArchived(t *testing.T, file *nosql.ArchivedFile) string {
+	t.Helper()
+	db := new(nosql.Archived)
+	db.UID = primitive.NewObjectID()
+	db.Entity = primitive.NewObjectID().Hex()
+	db.ArchivedFile = *file
+	if err := nosql.CreateArchived(db); err != nil {
+		t.Fatalf("create archived: %v", err)
+	}
+	return db.UID.Hex()
+}
+
+func TestMigrateArchives(t *testing.T) {
+	ctx := initTestContext(t)
+	data := []byte(`{"name":"test","tags":null}`)
+	legacy := newTestArchived(t, &nosql.ArchivedFile{File: base64.StdEncoding.EncodeToString(data),
+		Size: uint32(len(data)), MD5: tool.CalculateMD5(data)})
+	raw := newTestArchived(t, &nosql.ArchivedFile{File: string(data)})
+	mismatch := newTestArchived(t, &nosql.ArchivedFile{File: string(data), MD5: "bad"})
+
+	result, err := ctx.MigrateArchives(true)
+	if err != nil || result.Total != 3 || result.Migrated != 0 || len(result.Mismatch) != 1 || result.Mismatch[0] != mismatch {
+		t.Fatalf("verify archives = %+v, %v", result, err)
+	}
+	if db, _ := nosql.GetArchived(legacy); db.Encoding != ArchivedEncodingBase64 {
+		t.Error("the verify should not write the archived")
+	}
+	result, err = ctx.MigrateArchives(false)
+	if err != nil || result.Migrated != 2 || len(result.Failed) != 0 {
+		t.Fatalf("migrate archives = %+v, %v", result, err)
+	}
+	for _, uid := range []string{legacy, raw} {
+		db, _ := nosql.GetArchived(uid)
+		if db.Encoding != ArchivedEncodingGzip || db.Format != ArchivedFormatLatest {
+			t.Errorf("the archived %s is not migrated", uid)
+		}
+		got, er := decodeArchivedFile(&db.ArchivedFile)
+		if er != nil || tool.CalculateMD5(got) != db.MD5 {
+			t.Errorf("the migrated archived %s is broken: %v", uid, er)
+		}
+	}
+	if db, _ := nosql.GetArchived(mismatch); db.File != string(data) || db.Encoding != ArchivedEncodingBase64 {
+		t.Error("the mismatch archived should keep unchanged")
+	}
+	if result, _ = ctx.MigrateArchives(false); result.Latest != 2 || result.Migrated != 0 {
+		t.Errorf("migrate again = %+v, want all latest", result)
+	}
+}
@@ -118,7 +118,7 @@ func getConcept(all []*nosql.Concept, uid string) *nosql.Concept {
 	return nil
 }
 
-func CheckConcepts() {
+func CheckConcepts() error {
 	dbs := nosql.GetConcepts()
 	for _, db := range dbs {
 		if db.Type == 0 {
@@ -128,6 +128,7 @@ func CheckConcepts() {
 			}
 		}
 	}
+	return nil
 }
 
 func (mine *cacheContext) CreateTopConcept(info *ConceptInfo) error {
@@ -306,6 +307,18 @@ func (mine *ConceptInfo) Label() string {
 	}
 }
 
+/**
+图数据库允许使用的节点标签
+*/
+func conceptLabels() []string {
+	list := make([]string, 0, ConceptTypeEra+1)
+	for tp := ConceptTypeUnknown; tp <= ConceptTypeEra; tp += 1 {
+		tmp := &ConceptInfo{Type: uint8(tp)}
+		list = append(list, tmp.Label())
+	}
+	return list
+}
+
 func (mine *ConceptInfo) RemoveChild(uid string) bool {
 	for i := 0; i < len(mine.Children); i += 1 {
 		if mine.Children[i].UID == uid {
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"strings"
+)
+
+type RemoveMode uint8
+
+const (
+	RemoveRefuse  RemoveMode = 0 //存在其他数据引用时拒绝删除
+	RemoveCascade RemoveMode = 1 //同时删除或者清理引用的数据
+)
+
+const (
+	ErrorHadReferences  = "the entity had been referenced by other data"
+	ErrorRemoveNotDraft = "the entity is not draft so can not remove"
+)
+
+/**
+实体的依赖数据，事件、中心关系、审核和存档属于实体自身，其余为其他数据对实体的引用
+*/
+type EntityDependency struct {
+	Entity     string   `json:"entity"`
+	Events     []string `json:"events"`
+	Edges      []string `json:"edges"`      //以实体为中心的关系
+	References []string `json:"references"` //其他实体指向该实体的关系
+	Boxes      []string `json:"boxes"`      //内容包含该实体的收藏夹
+	Properties []string `json:"properties"` //属性值引用该实体的实体
+	Examines   []string `json:"examines"`
+	Archived   string   `json:"archived"`
+}
+
+func (mine *EntityDependency) Referenced() bool {
+	return len(mine.References) > 0 || len(mine.Boxes) > 0 || len(mine.Properties) > 0
+}
+
+func (mine *cacheContext) GetEntityDependency(uid string) *EntityDependency {
+	info := &EntityDependency{Entity: uid, Events: make([]string, 0, 5), Edges: make([]string, 0, 5),
+		References: make([]string, 0, 5), Boxes: make([]string, 0, 2), Properties: make([]string, 0, 2),
+		Examines: make([]string, 0, 2)}
+	events, _ := nosql.GetEventsByEntity(uid)
+	for _, item := range events {
+		info.Events = append(info.Events, item.UID.Hex())
+	}
+	edges, _ := nosql.GetVEdgesByCenter(uid)
+	for _, item := range edges {
+		info.Edges = append(info.Edges, item.UID.Hex())
+	}
+	sources, _ := nosql.GetVEdgesBySource(uid)
+	targets, _ := nosql.GetVEdgesByTarget(uid)
+	for _, item := range append(sources, targets...) {
+		if item.Center != uid && !hadItem(info.References, item.UID.Hex()) {
+			info.References = append(info.References, item.UID.Hex())
+		}
+	}
+	for _, box := range mine.GetBoxesByKeyword(uid) {
+		info.Boxes = append(info.Boxes, box.UID)
+	}
+	for _, table := range mine.EntityTables() {
+		dbs, _ := nosql.GetEntitiesByPropEntity(table, uid)
+		for _, db := range dbs {
+			if db.UID.Hex() != uid {
+				info.Properties = append(info.Properties, db.UID.Hex())
+			}
+		}
+	}
+	for _, item := range mine.GetExaminesByTarget(uid) {
+		info.Examines = append(info.Examines, item.UID)
+	}
+	if archived, _ := nosql.GetArchivedByEntity(uid); archived != nil {
+		info.Archived = archived.UID.Hex()
+	}
+	return info
+}
+
+/**
+删除草稿实体以及实体自身的数据，存在其他数据引用时按照mode拒绝删除或者清理引用，返回受影响的数据
+*/
+func (mine *cacheContext) RemoveEntity(uid, operator string, mode RemoveMode) (*EntityDependency, error) {
+	if len(uid) < 1 {
+		return nil, errors.New("the entity uid is empty")
+	}
+	tmp := mine.GetEntity(uid)
+	if tmp == nil {
+		return nil, nil
+	}
+	if tmp.Status != EntityStatusDraft {
+		return nil, newEntityError(EntityErrorNotDraft, ErrorRemoveNotDraft)
+	}
+	info := mine.GetEntityDependency(uid)
+	if mode != RemoveCascade && info.Referenced() {
+		return info, newEntityError(EntityErrorReferenced, ErrorHadReferences)
+	}
+	err := nosql.RemoveEntity(tmp.table(), uid, operator)
+	if err != nil {
+		return info, err
+	}
+	mine.addSyncRemoveNode(uid)
+	failed := make([]string, 0, 2)
+	check := func(kind, item string, er error) {
+		if er != nil {
+			logger.Warnf("remove the dependency of entity failed that kind = %s; uid = %s; err = %s", kind, item, er.Error())
+			failed = append(failed, fmt.Sprintf("%s(%s): %s", kind, item, er.Error()))
+		}
+	}
+	for _, item := range info.Events {
+		check("event", item, nosql.RemoveEvent(item, operator))
+	}
+	for _, item := range info.Edges {
+		check("edge", item, mine.RemoveVEdge(item, operator))
+	}
+	for _, item := range info.References {
+		check("edge", item, mine.RemoveVEdge(item, operator))
+	}
+	for _, item := range info.Boxes {
+		if box := mine.GetBox(item); box != nil {
+			check("box", item, box.RemoveKeywords([]string{uid}, operator))
+		}
+	}
+	for _, item := range info.Properties {
+		if entity := mine.GetEntity(item); entity != nil {
+			check("property", item, entity.removeEntityReference(uid, operator))
+		}
+	}
+	for _, item := range info.Examines {
+		check("examine", item, nosql.RemoveExamine(item, operator))
+	}
+	if len(info.Archived) > 0 {
+		check("archived", info.Archived, nosql.RemoveArchived(info.Archived, operator))
+	}
+	if len(failed) > 0 {
+		// 实体已经删除，返回没有清理的数据
+		return info, errors.New("remove the dependencies of entity failed that " + strings.Join(failed, "; "))
+	}
+	return info, nil
+}
+
+/**
+属性值中引用的实体删除后，只保留属性值的名称
+*/
+func (mine *EntityInfo) removeEntityReference(entity, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "reference", operator)
+	list := make([]*proxy.PropertyInfo, 0, len(mine.Properties))
+	for _, prop := range mine.Properties {
+		words := make([]proxy.WordInfo, 0, len(prop.Words))
+		for _, word := range prop.Words {
+			if word.UID == entity {
+				word.UID = ""
+			}
+			words = append(words, word)
+		}
+		list = append(list, &proxy.PropertyInfo{Key: prop.Key, Words: words})
+	}
+	err := nosql.UpdateEntityProperties(mine.table(), mine.UID, operator, list)
+	if err == nil {
+		mine.Properties = list
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRemoveEntityNotDraft(t *testing.T) {
+	ctx := initTestContext(t)
+	entity := newTestEntity(t, "a")
+	if err := entity.UpdateStatus(EntityStatusPending, "tester", ""); err != nil {
+		t.Fatalf("submit entity: %v", err)
+	}
+	_, err := ctx.RemoveEntity(entity.UID, "tester", RemoveCascade)
+	var tmp *EntityError
+	if !errors.As(err, &tmp) || tmp.Kind != EntityErrorNotDraft {
+		t.Fatalf("remove the pending entity err = %v, want the not draft error", err)
+	}
+	if ctx.GetEntity(entity.UID) == nil {
+		t.Error("the pending entity should not be removed")
+	}
+}
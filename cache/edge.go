@@ -52,6 +52,7 @@ func (mine *cacheContext) GetVEdgesBySource(entity string) []*VEdgeInfo {
 	for _, db := range dbs {
 		info := new(VEdgeInfo)
 		info.initInfo(db)
+		list = append(list, info)
 	}
 	return list
 }
@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -137,6 +136,10 @@ func (mine *cacheContext) CreateEntity(info *EntityInfo, relations []*pb.VEdgeIn
 	err = nosql.CreateEntity(db, info.table())
 	if err == nil {
 		info.initInfo(db)
+		er := mine.createRevision(info.UID, RevisionCreate, "", info.Operator, nil, &entityRevision{snapshot: db}, nil)
+		if er != nil {
+			logger.Warnf("create the revision failed that entity = %s; err = %s", info.UID, er.Error())
+		}
 		_ = info.UpdateStaticRelations(info.Operator, relations)
 		mine.syncGraphNode(info)
 	}
@@ -238,6 +241,8 @@ func (mine *EntityInfo) table() string {
 
 func (mine *EntityInfo) updateConcept(concept, operator string) error {
 	if mine.Concept != concept {
+		rev := mine.beginRevision()
+		defer mine.commitRevision(rev, "concept", operator)
 		err := nosql.UpdateEntityConcept(mine.table(), mine.UID, concept, operator)
 		if err == nil {
 			mine.Concept = concept
@@ -257,6 +262,8 @@ func (mine *EntityInfo) replaceAttribute(old, news string) error {
 			prop.Key = news
 		}
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "attribute", mine.Operator)
 	err := nosql.UpdateEntityProperties(mine.table(), mine.UID, mine.Operator, props)
 	if err == nil {
 		mine.Properties = props
@@ -291,21 +298,32 @@ func (mine *EntityInfo) UpdateAdd(add, operator string) error {
 	if len(add) < 1 {
 		return errors.New("the entity add is empty")
 	}
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if mine.Add == add || strings.Contains(mine.Add, add) {
 		return nil
 	}
 	if cacheCtx.HadEntityByName(mine.Name, add, "") {
 		return errors.New("the entity name and add repeated")
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "add", operator)
 	err := nosql.UpdateEntityAdd(mine.table(), mine.UID, add, operator)
 	if err == nil {
 		mine.Add = add
 		mine.Operator = operator
+		cacheCtx.addSyncUpdateNode(mine.UID)
 	}
 	return err
 }
 
+/**
+分数是排序用的统计数据，不属于实体的内容，发布后也可以修改
+*/
 func (mine *EntityInfo) UpdateScore(score uint32, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "score", operator)
 	err := nosql.UpdateEntityScore(mine.table(), mine.UID, score, operator)
 	if err == nil {
 		mine.Score = score
@@ -315,9 +333,18 @@ func (mine *EntityInfo) UpdateScore(score uint32, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateBase(name, desc, add, concept, cover, mark, quote, sum, operator string) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "base", operator)
+	return mine.saveBase(name, desc, add, concept, cover, mark, quote, sum, operator)
+}
+
+/**
+修改基础信息，不记录修订，由调用方记录
+*/
+func (mine *EntityInfo) saveBase(name, desc, add, concept, cover, mark, quote, sum, operator string) error {
 	if concept == "" {
 		concept = mine.Concept
 	}
@@ -332,7 +359,10 @@ func (mine *EntityInfo) UpdateBase(name, desc, add, concept, cover, mark, quote,
 	}
 	var err error
 	if len(cover) > 0 && cover != mine.Cover {
-		err = mine.UpdateCover(cover, operator)
+		err = mine.setCover(cover, operator)
+		if err == nil {
+			cacheCtx.addSyncUpdateNode(mine.UID)
+		}
 	}
 	if desc != mine.Description || sum != mine.Summary {
 		err = nosql.UpdateEntityRemark(mine.table(), mine.UID, desc, sum, operator)
@@ -346,6 +376,7 @@ func (mine *EntityInfo) UpdateBase(name, desc, add, concept, cover, mark, quote,
 	if name != mine.Name || add != mine.Add || concept != mine.Concept || quote != mine.Quote {
 		err = nosql.UpdateEntityBase(mine.table(), mine.UID, name, add, concept, quote, mark, operator)
 		if err == nil {
+			renamed := name != mine.Name || add != mine.Add
 			mine.Name = name
 			mine.Add = add
 			mine.Quote = quote
@@ -353,25 +384,47 @@ func (mine *EntityInfo) UpdateBase(name, desc, add, concept, cover, mark, quote,
 			mine.Mark = mark
 			mine.Operator = operator
 			mine.Updated = time.Now().Unix()
+			if renamed {
+				cacheCtx.addSyncUpdateNode(mine.UID)
+			}
 		}
 	}
 	return err
 }
 
 func (mine *EntityInfo) UpdateName(name, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	return mine.updateName(name, operator)
+}
+
+func (mine *EntityInfo) updateName(name, operator string) error {
 	if cacheCtx.HadEntityByName(name, mine.Add, mine.Owner) {
 		return errors.New("the name and add existed")
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "name", operator)
 	err := nosql.UpdateEntityName(mine.table(), mine.UID, name, mine.Add, operator)
 	if err == nil {
 		mine.Name = name
 		mine.Operator = operator
 		mine.Updated = time.Now().Unix()
+		cacheCtx.addSyncUpdateNode(mine.UID)
 	}
 	return err
 }
 
 func (mine *EntityInfo) UpdateRemark(desc, sum, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	return mine.updateRemark(desc, sum, operator)
+}
+
+func (mine *EntityInfo) updateRemark(desc, sum, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "remark", operator)
 	err := nosql.UpdateEntityRemark(mine.table(), mine.UID, desc, sum, operator)
 	if err == nil {
 		mine.Description = desc
@@ -383,10 +436,12 @@ func (mine *EntityInfo) UpdateRemark(desc, sum, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateStatic(info *EntityInfo, relations []*pb.VEdgeInfo) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
 	}
-	_ = mine.UpdateBase(info.Name, info.Description, info.Add, info.Concept, info.Cover, info.Mark, info.Quote, info.Summary, info.Operator)
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "static", info.Operator)
+	_ = mine.saveBase(info.Name, info.Description, info.Add, info.Concept, info.Cover, info.Mark, info.Quote, info.Summary, info.Operator)
 	err := nosql.UpdateEntityStatic(mine.table(), mine.UID, info.Operator, info.Tags, info.Properties)
 	if err == nil {
 		mine.Tags = info.Tags
@@ -394,18 +449,28 @@ func (mine *EntityInfo) UpdateStatic(info *EntityInfo, relations []*pb.VEdgeInfo
 		mine.Updated = time.Now().Unix()
 	}
 	if len(info.StaticEvents) > 0 {
-		_ = mine.UpdateStaticEvents(info.Operator, info.StaticEvents)
+		_ = mine.saveStaticEvents(info.Operator, info.StaticEvents)
 	}
 	if len(relations) > 0 {
-		_ = mine.UpdateStaticRelations(info.Operator, relations)
+		_ = mine.saveStaticRelations(info.Operator, relations)
 	}
 	return err
 }
 
 func (mine *EntityInfo) UpdateStaticEvents(operator string, events []*proxy.EventBrief) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
 	}
+	return mine.updateStaticEvents(operator, events)
+}
+
+func (mine *EntityInfo) updateStaticEvents(operator string, events []*proxy.EventBrief) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "events", operator)
+	return mine.saveStaticEvents(operator, events)
+}
+
+func (mine *EntityInfo) saveStaticEvents(operator string, events []*proxy.EventBrief) error {
 	err := nosql.UpdateEntityEvents(mine.table(), mine.UID, operator, events)
 	if err == nil {
 		mine.Operator = operator
@@ -424,10 +489,44 @@ func (mine *EntityInfo) GetVEdgeCount() uint32 {
 }
 
 func (mine *EntityInfo) UpdateStaticRelations(operator string, list []*pb.VEdgeInfo) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
+	}
+	return mine.updateStaticRelations(operator, list)
+}
+
+/**
+有UID的关系必须是以该实体为中心的关系，不能通过一个实体修改其他实体的关系
+*/
+func (mine *EntityInfo) checkStaticRelations(list []*pb.VEdgeInfo) error {
+	for _, brief := range list {
+		if brief.Uid == "" {
+			continue
+		}
+		edge, err := cacheCtx.GetVEdge(brief.Uid)
+		if err != nil {
+			return errors.New("not found the relation by uid " + brief.Uid)
+		}
+		if edge.Center != mine.UID {
+			return errors.New("the relation not belong to the entity " + brief.Uid)
+		}
 	}
+	return nil
+}
+
+func (mine *EntityInfo) updateStaticRelations(operator string, list []*pb.VEdgeInfo) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "relations", operator)
+	return mine.saveStaticRelations(operator, list)
+}
 
+/**
+修改以实体为中心的关系，不记录修订，由调用方记录
+*/
+func (mine *EntityInfo) saveStaticRelations(operator string, list []*pb.VEdgeInfo) error {
+	if err := mine.checkStaticRelations(list); err != nil {
+		return err
+	}
 	for _, brief := range list {
 		target := proxy.VNode{
 			Name:   brief.Target.Name,
@@ -441,31 +540,37 @@ func (mine *EntityInfo) UpdateStaticRelations(operator string, list []*pb.VEdgeI
 				return err
 			}
 		} else {
-			_, err := cacheCtx.CreateVEdge(brief.Uid, brief.Source, brief.Name, brief.Remark, brief.Category, operator, brief.Direction, brief.Weight, brief.Type, target)
+			_, err := cacheCtx.CreateVEdge(mine.UID, brief.Source, brief.Name, brief.Remark, brief.Category, operator, brief.Direction, brief.Weight, brief.Type, target)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	//err := nosql.UpdateEntityRelations(mine.table(), mine.UID, operator, list)
-	//if err == nil {
-	//	mine.Operator = operator
-	//mine.StaticRelations = list
-	//	mine.Updated = time.Now().Unix()
-	//}
-	return nil
+	// 关系保存在关系边中，只更新实体的修改时间，用于审核建议的冲突检查
+	err := nosql.UpdateEntityFields(mine.table(), mine.UID, operator, nil)
+	if err == nil {
+		mine.Operator = operator
+		mine.Updated = time.Now().Unix()
+	}
+	return err
 }
 
 func (mine *EntityInfo) UpdateCover(cover, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	return mine.updateCover(cover, operator)
+}
+
+func (mine *EntityInfo) updateCover(cover, operator string) error {
 	if cover == "" || cover == mine.Cover {
 		return nil
 	}
-	err := nosql.UpdateEntityCover(mine.table(), mine.UID, cover, operator)
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "cover", operator)
+	err := mine.setCover(cover, operator)
 	if err == nil {
-		mine.Cover = cover
-		mine.Operator = operator
-		mine.Updated = time.Now().Unix()
-		//go Context().graph.UpdateNodeCover(mine.UID, cover)
+		cacheCtx.addSyncUpdateNode(mine.UID)
 	}
 	return err
 }
@@ -484,9 +589,14 @@ func (mine *EntityInfo) setCover(cover, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateThumb(thumb, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if thumb == "" || thumb == mine.Thumb {
 		return nil
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "thumb", operator)
 	err := nosql.UpdateEntityThumb(mine.table(), mine.UID, thumb, operator)
 	if err == nil {
 		mine.Thumb = thumb
@@ -497,9 +607,14 @@ func (mine *EntityInfo) UpdateThumb(thumb, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateMark(mark, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if mark == mine.Mark {
 		return nil
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "mark", operator)
 	err := nosql.UpdateEntityMark(mine.table(), mine.UID, mark, operator)
 	if err == nil {
 		mine.Mark = mark
@@ -510,9 +625,14 @@ func (mine *EntityInfo) UpdateMark(mark, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateQuote(quote, operator string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if quote == mine.Quote {
 		return nil
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "quote", operator)
 	err := nosql.UpdateEntityQuote(mine.table(), mine.UID, quote, operator)
 	if err == nil {
 		mine.Quote = quote
@@ -523,6 +643,13 @@ func (mine *EntityInfo) UpdateQuote(quote, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateProperty(uid, val, operator string) error {
+	if err := mine.checkDraft(); err != nil {
+		return err
+	}
+	return mine.updateProperty(uid, val, operator)
+}
+
+func (mine *EntityInfo) updateProperty(uid, val, operator string) error {
 	arr := make([]*proxy.PropertyInfo, 0, len(mine.Properties))
 	arr = append(arr, mine.Properties...)
 	had := false
@@ -546,7 +673,7 @@ func (mine *EntityInfo) UpdateProperty(uid, val, operator string) error {
 		arr = append(arr, info)
 	}
 
-	return mine.UpdateProperties(arr, operator)
+	return mine.updateProperties(arr, operator)
 }
 
 func (mine *EntityInfo) GetRecords() ([]*nosql.Record, error) {
@@ -564,9 +691,15 @@ func (mine *EntityInfo) GetRecords() ([]*nosql.Record, error) {
 }
 
 func (mine *EntityInfo) UpdateTags(tags []string, operator string) error {
-	//if mine.Status == EntityStatusUsable {
-	//	return errors.New(ErrorHadPublished)
-	//}
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	return mine.updateTags(tags, operator)
+}
+
+func (mine *EntityInfo) updateTags(tags []string, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "tags", operator)
 	err := nosql.UpdateEntityTags(mine.table(), mine.UID, operator, tags)
 	if err == nil {
 		mine.Tags = tags
@@ -577,9 +710,15 @@ func (mine *EntityInfo) UpdateTags(tags []string, operator string) error {
 }
 
 func (mine *EntityInfo) UpdateSynonyms(list []string, operator string) error {
-	//if mine.Status == EntityStatusUsable {
-	//	return errors.New(ErrorHadPublished)
-	//}
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	return mine.updateSynonyms(list, operator)
+}
+
+func (mine *EntityInfo) updateSynonyms(list []string, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "synonyms", operator)
 	err := nosql.UpdateEntitySynonyms(mine.table(), mine.UID, operator, list)
 	if err == nil {
 		mine.Synonyms = list
@@ -590,13 +729,7 @@ func (mine *EntityInfo) UpdateSynonyms(list []string, operator string) error {
 }
 
 func (mine *EntityInfo) createRecord(operator, remark string, from, to EntityStatus) {
-	opt := OptionAgree
-	if to > from {
-		opt = OptionAgree
-	} else {
-		opt = OptionRefuse
-	}
-
+	opt := statusOption(from, to)
 	_ = mine.insertRecord(operator, remark, fmt.Sprintf("%d", from), fmt.Sprintf("%d", to), opt)
 }
 
@@ -630,49 +763,68 @@ func (mine *EntityInfo) UpdateStatus(status EntityStatus, operator, remark strin
 	if mine.Status == status {
 		return nil
 	}
-	err := nosql.UpdateEntityStatus(mine.table(), mine.UID, uint8(status), operator)
+	err := checkStatusTransition(mine.Status, status)
 	if err != nil {
 		return err
 	}
-	mine.Operator = operator
-	mine.createRecord(operator, remark, mine.Status, status)
-	if status == EntityStatusUsable {
-		mine.Published = true
-		tmp := Context().GetArchivedByEntity(mine.UID)
-		if tmp == nil {
-			err = Context().CreateArchived(mine)
-			if err != nil {
-				return err
-			}
-			//cacheCtx.checkRelations(nil, mine)
-		} else {
-			_, er := tmp.Decode()
-			if er != nil {
-				return er
-			}
-			err = tmp.UpdateFile(mine, operator)
-			if err != nil {
-				return err
-			}
-			//cacheCtx.checkRelations(old, mine)
+	if statusOption(mine.Status, status) == OptionRefuse && len(strings.TrimSpace(remark)) < 1 {
+		return newEntityError(EntityErrorRemark, ErrorRemarkEmpty)
+	}
+	flows, err := cacheCtx.checkWorkflows(mine, status, operator)
+	if err != nil {
+		return err
+	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "status", operator)
+	err = nosql.UpdateEntityStatus(mine.table(), mine.UID, uint8(status), operator)
+	if err != nil {
+		return err
+	}
+	published := mine.Published
+	err = mine.runWorkflowActions(flows, workflowActions(flows, status), mine.Status, status, operator, remark)
+	if err != nil {
+		// 动作执行失败时恢复原来的状态，不记录这次状态的变化
+		mine.Published = published
+		er := nosql.UpdateEntityStatus(mine.table(), mine.UID, uint8(mine.Status), mine.Operator)
+		if er != nil {
+			logger.Warnf("revert the entity status failed that entity = %s; status = %d; err = %s", mine.UID, mine.Status, er.Error())
 		}
+		return err
 	}
+	mine.Operator = operator
+	mine.createRecord(operator, remark, mine.Status, status)
 	cacheCtx.UpdateBoxContentStatus(mine.UID, status, mine.Published)
+	if mine.Status == EntityStatusPending {
+		cacheCtx.finishReview(ReviewKindEntity, mine.UID)
+	}
 	mine.Status = status
 	mine.Updated = time.Now().Unix()
 	return nil
 }
 
-func (mine *EntityInfo) encode() (string, string, uint32, error) {
+/**
+发布实体，没有存档时创建存档，否则更新存档
+*/
+func (mine *EntityInfo) publishArchived(operator, remark string) error {
+	mine.Published = true
+	tmp := Context().GetArchivedByEntity(mine.UID)
+	if tmp == nil {
+		return Context().CreateArchived(mine, operator, remark)
+	}
+	_, er := tmp.Decode()
+	if er != nil {
+		return er
+	}
+	return tmp.Publish(mine, operator, remark)
+}
+
+func (mine *EntityInfo) encode() (*nosql.ArchivedFile, error) {
 	mine.StaticVEdges = mine.GetVEdges()
 	bts, er := json.Marshal(mine)
 	if er != nil {
-		return "", "", 0, er
+		return nil, er
 	}
-	md5 := tool.CalculateMD5(bts)
-	size := len(bts)
-	data := base64.StdEncoding.EncodeToString(bts)
-	return data, md5, uint32(size), nil
+	return encodeArchivedFile(mine.UID, bts)
 }
 
 func (mine *EntityInfo) UpdatePushTime(operator string) error {
@@ -687,9 +839,14 @@ func (mine *EntityInfo) UpdatePushTime(operator string) error {
 }
 
 func (mine *EntityInfo) UpdateRelates(operator string, list []string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if list == nil {
 		list = make([]string, 0, 1)
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "relates", operator)
 	err := nosql.UpdateEntityRelates(mine.table(), mine.UID, operator, list)
 	if err != nil {
 		return err
@@ -705,9 +862,14 @@ func (mine *EntityInfo) UpdateRelates(operator string, list []string) error {
 }
 
 func (mine *EntityInfo) UpdateLinks(operator string, list []string) error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if list == nil {
 		list = make([]string, 0, 1)
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "links", operator)
 	err := nosql.UpdateEntityLinks(mine.table(), mine.UID, operator, list)
 	if err != nil {
 		return err
@@ -970,7 +1132,7 @@ func (mine *EntityInfo) AddEvent(data *pb.ReqEventAdd) (*EventInfo, error) {
 		for i := 0; i < len(relations); i += 1 {
 			relationKind := Context().GetRelation(relations[i].Category)
 			if relationKind != nil {
-				Context().addSyncLink(mine.UID, relations[i].Entity, relationKind.UID, relations[i].Name, switchRelationToLink(relationKind.Kind), relations[i].Direction)
+				Context().addSyncLink(mine.UID, relations[i].Entity, relationKind.UID, relations[i].Name, switchRelationToLink(relationKind.Kind), relations[i].Direction, relations[i].Weight)
 			}
 		}
 
@@ -1054,8 +1216,8 @@ func (mine *EntityInfo) addProp(key string, words []proxy.WordInfo) {
 }
 
 func (mine *EntityInfo) AddProperty(key string, words []proxy.WordInfo) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
 	}
 	if mine.Properties == nil {
 		return errors.New("must call construct fist")
@@ -1064,6 +1226,8 @@ func (mine *EntityInfo) AddProperty(key string, words []proxy.WordInfo) error {
 		return errors.New("the prop key or value is empty")
 	}
 	pair := proxy.PropertyInfo{Key: key, Words: words}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "properties", mine.Operator)
 	err := nosql.AppendEntityProperty(mine.table(), mine.UID, pair)
 	if err == nil {
 		mine.Properties = append(mine.Properties, &pair)
@@ -1073,9 +1237,15 @@ func (mine *EntityInfo) AddProperty(key string, words []proxy.WordInfo) error {
 }
 
 func (mine *EntityInfo) UpdateProperties(array []*proxy.PropertyInfo, operator string) error {
-	if mine.Status != EntityStatusDraft {
-		return errors.New("the entity is not draft so can not update")
+	if err := mine.checkDraft(); err != nil {
+		return err
 	}
+	return mine.updateProperties(array, operator)
+}
+
+func (mine *EntityInfo) updateProperties(array []*proxy.PropertyInfo, operator string) error {
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "properties", operator)
 	err := nosql.UpdateEntityProperties(mine.table(), mine.UID, operator, array)
 	if err == nil {
 		mine.Properties = array
@@ -1109,15 +1279,17 @@ func (mine *EntityInfo) HadPropertyByEntity(uid string) bool {
 }
 
 func (mine *EntityInfo) RemoveProperty(attribute string) error {
-	//if mine.Status == EntityStatusUsable {
-	//	return errors.New(ErrorHadPublished)
-	//}
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
 	if mine.Properties == nil {
 		return errors.New("must call construct fist")
 	}
 	if !mine.HadProperty(attribute) {
 		return errors.New("not found the property when remove")
 	}
+	rev := mine.beginRevision()
+	defer mine.commitRevision(rev, "properties", mine.Operator)
 	err := nosql.SubtractEntityProperty(mine.table(), mine.UID, attribute)
 	if err == nil {
 		for i := 0; i < len(mine.Properties); i += 1 {
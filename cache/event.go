@@ -450,7 +450,7 @@ func (mine *EventInfo) AppendRelation(relation *proxy.RelationCaseInfo) error {
 		mine.Updated = time.Now().Unix()
 		tmp := Context().GetRelation(relation.Category)
 		if tmp != nil {
-			Context().addSyncLink(mine.Entity, relation.Entity, tmp.UID, relation.Name, switchRelationToLink(tmp.Kind), relation.Direction)
+			Context().addSyncLink(mine.Entity, relation.Entity, tmp.UID, relation.Name, switchRelationToLink(tmp.Kind), relation.Direction, relation.Weight)
 		}
 	}
 	return err
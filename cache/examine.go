@@ -1,28 +1,46 @@
 package cache
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/micro/go-micro/v2/logger"
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy"
 	"omo.msa.vocabulary/proxy/nosql"
 	"time"
 )
 
 const (
 	ExamineTypeBase      ExamineType = 0 //基本信息
-	ExamineTypeAttribute ExamineType = 1 //属性
+	ExamineTypeAttribute ExamineType = 1 //属性，单个值
 	ExamineTypeEvent     ExamineType = 2 //事件
+	ExamineTypeRelation  ExamineType = 3 //关系，值为VEdgeInfo数组的json
+	ExamineTypeStatic    ExamineType = 4 //静态事件，值为EventBrief数组的json
+	ExamineTypeProperty  ExamineType = 5 //属性，值为WordInfo数组的json
 )
 
 const (
-	ExamineStatusIdle   = 0 //待审核
-	ExamineStatusFree   = 1 //通过
-	ExamineStatusRefuse = 2 //拒绝
+	ExamineStatusIdle     = 0 //待审核
+	ExamineStatusFree     = 1 //通过
+	ExamineStatusRefuse   = 2 //拒绝
+	ExamineStatusWithdraw = 3 //提交人撤回
 )
 
 const (
-	ExamineBaseAvatar  = "avatar"
-	ExamineBaseDesc    = "desc"
-	ExamineBaseSummary = "summary"
-	ExamineBaseName    = "name"
+	ExamineBaseAvatar   = "avatar"
+	ExamineBaseDesc     = "desc"
+	ExamineBaseSummary  = "summary"
+	ExamineBaseName     = "name"
+	ExamineBaseTags     = "tags"     //值为字符串数组的json
+	ExamineBaseSynonyms = "synonyms" //值为字符串数组的json
+)
+
+const (
+	ErrorExamineConflict = "the entity had updated after the examine submitted"
+	ErrorExamineHandled  = "the examine had been handled"
+	ErrorExamineKey      = "the examine key is not supported"
+	ErrorExamineCreator  = "only the creator can withdraw the examine"
 )
 
 type ExamineType uint8
@@ -32,7 +50,36 @@ type ExamineInfo struct {
 	Data *nosql.Examine
 }
 
+/**
+提交修改建议，同一个人对同一个数据的修改只保留一个，不同的人可以提交互相竞争的建议
+*/
+func (mine *cacheContext) SubmitExamine(creator, target, key, val string, tp uint8) (*ExamineInfo, error) {
+	base, err := checkExamineValue(target, key, val, ExamineType(tp))
+	if err != nil {
+		return nil, err
+	}
+	db, _ := nosql.GetExamineByCreator(target, key, creator, ExamineStatusIdle, tp)
+	if db == nil {
+		return mine.createExamine(creator, target, key, val, tp, base)
+	}
+	info := new(ExamineInfo)
+	info.initInfo(db)
+	err = info.UpdateValue(val, creator)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
 func (mine *cacheContext) CreateExamine(creator, target, key, val string, tp uint8) (*ExamineInfo, error) {
+	base, err := checkExamineValue(target, key, val, ExamineType(tp))
+	if err != nil {
+		return nil, err
+	}
+	return mine.createExamine(creator, target, key, val, tp, base)
+}
+
+func (mine *cacheContext) createExamine(creator, target, key, val string, tp uint8, base int64) (*ExamineInfo, error) {
 	db := new(nosql.Examine)
 	db.UID = primitive.NewObjectID()
 	db.ID = nosql.GetExamineNextID()
@@ -43,6 +90,7 @@ func (mine *cacheContext) CreateExamine(creator, target, key, val string, tp uin
 	db.Kind = tp
 	db.Value = val
 	db.Status = ExamineStatusIdle
+	db.Base = base
 	err := nosql.CreateExamine(db)
 	if err == nil {
 		info := new(ExamineInfo)
@@ -94,6 +142,20 @@ func (mine *cacheContext) GetIdleExamineByTarget(target, key string, tp ExamineT
 	return nil
 }
 
+/**
+同一个数据待审核的全部建议
+*/
+func (mine *cacheContext) GetIdleExaminesByKey(target, key string, tp ExamineType) []*ExamineInfo {
+	dbs, _ := nosql.GetExaminesByKey(target, key, ExamineStatusIdle, uint8(tp))
+	list := make([]*ExamineInfo, 0, len(dbs))
+	for _, db := range dbs {
+		tmp := new(ExamineInfo)
+		tmp.initInfo(db)
+		list = append(list, tmp)
+	}
+	return list
+}
+
 func (mine *cacheContext) GetIdleExaminesByValue(val string, tp ExamineType) []*ExamineInfo {
 	dbs, _ := nosql.GetExaminesByValueType(val, uint8(tp), ExamineStatusIdle)
 	list := make([]*ExamineInfo, 0, len(dbs))
@@ -154,68 +216,307 @@ func (mine *cacheContext) GetExamine(uid string) *ExamineInfo {
 	return tmp
 }
 
+func (mine *cacheContext) RemoveExamine(uid, operator string) error {
+	err := nosql.RemoveExamine(uid, operator)
+	if err == nil {
+		mine.finishReview(ReviewKindExamine, uid)
+	}
+	return err
+}
+
 func (mine *ExamineInfo) initInfo(db *nosql.Examine) {
 	mine.UID = db.UID.Hex()
 	mine.Data = db
 }
 
-func (mine *ExamineInfo) UpdateStatus(st uint8, operator string) error {
+/**
+审核通过时把修改应用到实体，提交后实体已经被修改过的建议视为冲突，需要提交人重新提交
+*/
+func (mine *ExamineInfo) UpdateStatus(st uint8, operator, remark string) error {
+	return mine.updateStatus(st, operator, remark, 0)
+}
+
+/**
+批量审核，同一个实体的多个建议都与批量审核前的实体比较，避免前面的建议修改实体后
+后面的建议都变为冲突，返回失败的建议以及原因
+*/
+func (mine *cacheContext) UpdateExaminesStatus(uids []string, st uint8, operator, remark string) map[string]string {
+	failed := make(map[string]string, 2)
+	list := make([]*ExamineInfo, 0, len(uids))
+	befores := make(map[string]int64, len(uids))
+	for _, uid := range uids {
+		info := mine.GetExamine(uid)
+		if info == nil {
+			failed[uid] = "not found the examine by uid"
+			continue
+		}
+		target := info.Data.Target
+		if _, ok := befores[target]; !ok && ExamineType(info.Data.Kind) != ExamineTypeEvent {
+			if entity := mine.GetEntity(target); entity != nil {
+				befores[target] = entity.Updated
+			}
+		}
+		list = append(list, info)
+	}
+	for _, info := range list {
+		if er := info.updateStatus(st, operator, remark, befores[info.Data.Target]); er != nil {
+			failed[info.UID] = er.Error()
+		}
+	}
+	return failed
+}
+
+func (mine *ExamineInfo) updateStatus(st uint8, operator, remark string, before int64) error {
+	if mine.Data.Status != ExamineStatusIdle {
+		return errors.New(ErrorExamineHandled)
+	}
+	// 先修改状态，同时审核时只有一个可以成功
+	ok, err := nosql.UpdateExamineStatus(mine.UID, operator, remark, ExamineStatusIdle, st)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(ErrorExamineHandled)
+	}
 	if st == ExamineStatusFree {
-		_ = updateTargetValue(mine.Data.Target, mine.Data.Key, mine.Data.Value, operator, ExamineType(mine.Data.Kind))
+		err = updateTargetValue(mine.Data, operator, before)
+		if err != nil {
+			// 修改没有应用到实体，恢复为待审核
+			_, er := nosql.UpdateExamineStatus(mine.UID, mine.Data.Operator, mine.Data.Remark, st, ExamineStatusIdle)
+			if er != nil {
+				logger.Warnf("revert the examine status failed that uid = %s; err = %s", mine.UID, er.Error())
+			}
+			return err
+		}
 	}
-	err := nosql.UpdateExamineStatus(mine.UID, operator, st)
-	if err == nil {
-		mine.Data.Status = st
-		mine.Data.Operator = operator
-		mine.Data.Updated = time.Now().Unix()
+	cacheCtx.finishReview(ReviewKindExamine, mine.UID)
+	mine.Data.Status = st
+	mine.Data.Operator = operator
+	mine.Data.Remark = remark
+	mine.Data.Updated = time.Now().Unix()
+	return nil
+}
+
+/**
+提交人撤回还没有审核的建议
+*/
+func (mine *ExamineInfo) Withdraw(operator string) error {
+	if mine.Data.Creator != operator {
+		return errors.New(ErrorExamineCreator)
 	}
-	return err
+	return mine.UpdateStatus(ExamineStatusWithdraw, operator, "")
 }
 
+/**
+修改建议的值，同时更新冲突检查的基准
+*/
 func (mine *ExamineInfo) UpdateValue(val, operator string) error {
-	err := nosql.UpdateExamineValue(mine.UID, val, operator)
+	if mine.Data.Status != ExamineStatusIdle {
+		return errors.New(ErrorExamineHandled)
+	}
+	base, err := checkExamineValue(mine.Data.Target, mine.Data.Key, val, ExamineType(mine.Data.Kind))
+	if err != nil {
+		return err
+	}
+	err = nosql.UpdateExamineValue(mine.UID, val, operator, base)
 	if err == nil {
 		mine.Data.Value = val
+		mine.Data.Base = base
 		mine.Data.Operator = operator
 		mine.Data.Updated = time.Now().Unix()
 	}
 	return err
 }
 
-func updateTargetValue(target, key, val, operator string, tp ExamineType) error {
+/**
+检查建议的值的格式，返回实体当前的更新时间
+*/
+func checkExamineValue(target, key, val string, tp ExamineType) (int64, error) {
+	if tp == ExamineTypeEvent {
+		return 0, nil
+	}
 	entity := cacheCtx.GetEntity(target)
 	if entity == nil {
+		return 0, errors.New("not found the entity of examine")
+	}
+	var err error
+	switch tp {
+	case ExamineTypeBase:
+		switch key {
+		case ExamineBaseName, ExamineBaseAvatar, ExamineBaseDesc, ExamineBaseSummary:
+		case ExamineBaseTags, ExamineBaseSynonyms:
+			_, err = decodeExamineStrings(val)
+		default:
+			err = errors.New(ErrorExamineKey)
+		}
+	case ExamineTypeAttribute:
+		if cacheCtx.GetAttributeByKey(key) == nil {
+			err = errors.New("not found the attribute of examine")
+		}
+	case ExamineTypeProperty:
+		if cacheCtx.GetAttributeByKey(key) == nil {
+			err = errors.New("not found the attribute of examine")
+		} else {
+			_, err = decodeExamineWords(val)
+		}
+	case ExamineTypeRelation:
+		list, er := decodeExamineRelations(val)
+		if er == nil {
+			er = entity.checkStaticRelations(list)
+		}
+		err = er
+	case ExamineTypeStatic:
+		_, err = decodeExamineEvents(val)
+	default:
+		err = errors.New("the examine type is not supported")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entity.Updated, nil
+}
+
+func decodeExamineStrings(val string) ([]string, error) {
+	list := make([]string, 0, 5)
+	err := json.Unmarshal([]byte(val), &list)
+	return list, err
+}
+
+func decodeExamineWords(val string) ([]proxy.WordInfo, error) {
+	list := make([]proxy.WordInfo, 0, 3)
+	err := json.Unmarshal([]byte(val), &list)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) < 1 {
+		return nil, errors.New("the property values is empty")
+	}
+	return list, nil
+}
+
+func decodeExamineRelations(val string) ([]*pb.VEdgeInfo, error) {
+	list := make([]*pb.VEdgeInfo, 0, 3)
+	err := json.Unmarshal([]byte(val), &list)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list {
+		if item == nil || item.Target == nil || item.Target.Name == "" {
+			return nil, errors.New("the target of relation is empty")
+		}
+	}
+	return list, nil
+}
+
+func decodeExamineEvents(val string) ([]*proxy.EventBrief, error) {
+	list := make([]*proxy.EventBrief, 0, 3)
+	err := json.Unmarshal([]byte(val), &list)
+	return list, err
+}
+
+/**
+把属性的多个值替换为建议的值
+*/
+func (mine *EntityInfo) replacePropertyWords(attribute string, words []proxy.WordInfo, operator string) error {
+	arr := make([]*proxy.PropertyInfo, 0, len(mine.Properties)+1)
+	had := false
+	for _, info := range mine.Properties {
+		if info.Key == attribute {
+			arr = append(arr, &proxy.PropertyInfo{Key: attribute, Words: words})
+			had = true
+		} else {
+			arr = append(arr, info)
+		}
+	}
+	if !had {
+		arr = append(arr, &proxy.PropertyInfo{Key: attribute, Words: words})
+	}
+	return mine.updateProperties(arr, operator)
+}
+
+/**
+before为用于冲突检查的实体更新时间，为0时使用实体当前的更新时间，
+没有基准的旧建议不检查冲突
+*/
+func updateTargetValue(data *nosql.Examine, operator string, before int64) error {
+	tp := ExamineType(data.Kind)
+	key := data.Key
+	val := data.Value
+	if tp == ExamineTypeEvent {
+		event := cacheCtx.GetEvent(val)
+		if event != nil {
+			return event.UpdateAccess(operator, AccessPublic)
+		}
 		return nil
 	}
+	entity := cacheCtx.GetEntity(data.Target)
+	if entity == nil {
+		return errors.New("not found the entity of examine")
+	}
+	if before == 0 {
+		before = entity.Updated
+	}
+	if data.Base > 0 && before != data.Base {
+		return newEntityError(EntityErrorConflict, ErrorExamineConflict)
+	}
 	var err error
 	var had = false
 	if tp == ExamineTypeBase {
 		if key == ExamineBaseName {
-			err = entity.UpdateName(val, operator)
+			err = entity.updateName(val, operator)
 			had = true
 		} else if key == ExamineBaseAvatar {
-			err = entity.UpdateCover(val, operator)
+			err = entity.updateCover(val, operator)
 			had = true
 		} else if key == ExamineBaseSummary {
-			err = entity.UpdateRemark(entity.Description, val, operator)
+			err = entity.updateRemark(entity.Description, val, operator)
 			had = true
 		} else if key == ExamineBaseDesc {
-			err = entity.UpdateRemark(val, entity.Summary, operator)
+			err = entity.updateRemark(val, entity.Summary, operator)
+			had = true
+		} else if key == ExamineBaseTags {
+			list, _ := decodeExamineStrings(val)
+			err = entity.updateTags(list, operator)
+			had = true
+		} else if key == ExamineBaseSynonyms {
+			list, _ := decodeExamineStrings(val)
+			err = entity.updateSynonyms(list, operator)
 			had = true
-		}
-		if err == nil && had {
-			err = entity.UpdateStatus(EntityStatusUsable, operator, "")
 		}
 	} else if tp == ExamineTypeAttribute {
 		att := cacheCtx.GetAttributeByKey(key)
 		if att != nil {
-			err = entity.UpdateProperty(att.UID, val, operator)
+			err = entity.updateProperty(att.UID, val, operator)
+			had = true
 		}
-	} else if tp == ExamineTypeEvent {
-		event := cacheCtx.GetEvent(val)
-		if event != nil {
-			err = event.UpdateAccess(operator, AccessPublic)
+	} else if tp == ExamineTypeProperty {
+		att := cacheCtx.GetAttributeByKey(key)
+		if att != nil {
+			words, er := decodeExamineWords(val)
+			if er != nil {
+				return er
+			}
+			err = entity.replacePropertyWords(att.UID, words, operator)
+			had = true
 		}
+	} else if tp == ExamineTypeRelation {
+		list, er := decodeExamineRelations(val)
+		if er != nil {
+			return er
+		}
+		err = entity.updateStaticRelations(operator, list)
+		had = true
+	} else if tp == ExamineTypeStatic {
+		list, er := decodeExamineEvents(val)
+		if er != nil {
+			return er
+		}
+		err = entity.updateStaticEvents(operator, list)
+		had = true
+	}
+	// 审核通过的修改直接更新已经发布的存档
+	if err == nil && had && entity.Status == EntityStatusUsable {
+		err = entity.publishArchived(operator, "")
 	}
 	return err
 }
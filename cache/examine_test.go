@@ -0,0 +1,28 @@
+package cache
+
+import "testing"
+
+func TestUpdateExamineStatusOnce(t *testing.T) {
+	ctx := initTestContext(t)
+	entity := newTestEntity(t, "a")
+	examine, err := ctx.SubmitExamine("u1", entity.UID, ExamineBaseName, "a2", uint8(ExamineTypeBase))
+	if err != nil {
+		t.Fatalf("submit examine: %v", err)
+	}
+	// 两个审核人同时读取到待审核的建议
+	first := ctx.GetExamine(examine.UID)
+	second := ctx.GetExamine(examine.UID)
+	if err = first.UpdateStatus(ExamineStatusFree, "r1", ""); err != nil {
+		t.Fatalf("agree examine: %v", err)
+	}
+	if err = second.UpdateStatus(ExamineStatusRefuse, "r2", "no"); err == nil || err.Error() != ErrorExamineHandled {
+		t.Fatalf("the second review err = %v, want %s", err, ErrorExamineHandled)
+	}
+	info := ctx.GetExamine(examine.UID)
+	if info.Data.Status != ExamineStatusFree || info.Data.Operator != "r1" {
+		t.Errorf("the examine status = %d; operator = %s, want free by r1", info.Data.Status, info.Data.Operator)
+	}
+	if now := ctx.GetEntity(entity.UID); now.Name != "a2" {
+		t.Errorf("the entity name = %s, want a2", now.Name)
+	}
+}
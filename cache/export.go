@@ -0,0 +1,333 @@
+package cache
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GraphFormatGraphML = "graphml"
+	GraphFormatGEXF    = "gexf"
+	GraphFormatJSON    = "json" //JSON Graph Format
+)
+
+/**
+导出时节点和边的属性，节点ID使用实体UID，边ID使用图数据库中的ID
+*/
+type exportNode struct {
+	id      string
+	name    string
+	concept string
+	label   string
+	cover   string
+}
+
+type exportEdge struct {
+	id        string
+	source    string
+	target    string
+	name      string
+	relation  string
+	kind      string
+	direction DirectionType
+	weight    uint32
+}
+
+func (mine *exportEdge) directed() bool {
+	return mine.direction != DirectionTypeDouble
+}
+
+func (mine *GraphInfo) exportItems() ([]*exportNode, []*exportEdge) {
+	list := mine.Nodes()
+	nodes := make([]*exportNode, 0, len(list))
+	for _, item := range list {
+		node := &exportNode{id: item.Entity, name: item.Name, cover: item.Cover}
+		if len(item.Labels) > 0 {
+			node.label = item.Labels[0]
+		}
+		entity := Context().GetEntity(item.Entity)
+		if entity != nil {
+			node.concept = entity.Concept
+			if len(node.cover) < 1 {
+				node.cover = entity.Cover
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	links := mine.Links()
+	edges := make([]*exportEdge, 0, len(links))
+	for _, item := range links {
+		edge := &exportEdge{id: strconv.FormatInt(item.ID, 10), name: item.Name, relation: item.Relation,
+			kind: item.Label, direction: item.Direction, weight: item.Weight, source: item.From, target: item.To}
+		if item.Direction == DirectionTypeToFrom {
+			edge.source = item.To
+			edge.target = item.From
+		}
+		edges = append(edges, edge)
+	}
+	return nodes, edges
+}
+
+/**
+将关系图导出为GraphML、GEXF或者JSON Graph Format
+*/
+func (mine *GraphInfo) Export(format string) ([]byte, error) {
+	nodes, edges := mine.exportItems()
+	switch format {
+	case GraphFormatGraphML:
+		return exportGraphML(nodes, edges)
+	case GraphFormatGEXF:
+		return exportGEXF(nodes, edges)
+	case GraphFormatJSON:
+		return exportJSONGraph(mine.center, nodes, edges)
+	default:
+		return nil, errors.New("not support the graph format of " + format)
+	}
+}
+
+/**
+导出关系图，返回建议的文件名和导出的内容，不在服务端保存文件
+*/
+func (mine *cacheContext) ExportGraph(info *GraphInfo, format string) (string, []byte, error) {
+	if info == nil {
+		return "", nil, errors.New("the graph is nil")
+	}
+	bytes, err := info.Export(format)
+	if err != nil {
+		return "", nil, err
+	}
+	name := fmt.Sprintf("graph_%s_%d.%s", exportName(info.center), time.Now().Unix(), format)
+	return name, bytes, nil
+}
+
+/**
+中心实体来自请求参数，不是ObjectID时不能用于文件名
+*/
+func exportName(center string) string {
+	if _, err := primitive.ObjectIDFromHex(center); err != nil {
+		return "unknown"
+	}
+	return center
+}
+
+//region GraphML
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID       string        `xml:"id,attr"`
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed bool          `xml:"directed,attr"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+func exportGraphML(nodes []*exportNode, edges []*exportEdge) ([]byte, error) {
+	doc := graphMLDocument{XMLNS: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = []graphMLKey{
+		{ID: "name", For: "node", Name: "name", Type: "string"},
+		{ID: "concept", For: "node", Name: "concept", Type: "string"},
+		{ID: "label", For: "node", Name: "label", Type: "string"},
+		{ID: "cover", For: "node", Name: "cover", Type: "string"},
+		{ID: "ename", For: "edge", Name: "name", Type: "string"},
+		{ID: "relation", For: "edge", Name: "relation", Type: "string"},
+		{ID: "kind", For: "edge", Name: "kind", Type: "string"},
+		{ID: "direction", For: "edge", Name: "direction", Type: "int"},
+		{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+	}
+	doc.Graph = graphMLGraph{ID: "G", EdgeDefault: "undirected"}
+	for _, node := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: node.id, Data: []graphMLData{
+			{Key: "name", Value: node.name},
+			{Key: "concept", Value: node.concept},
+			{Key: "label", Value: node.label},
+			{Key: "cover", Value: node.cover},
+		}})
+	}
+	for _, edge := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{ID: edge.id, Source: edge.source, Target: edge.target,
+			Directed: edge.directed(), Data: []graphMLData{
+				{Key: "ename", Value: edge.name},
+				{Key: "relation", Value: edge.relation},
+				{Key: "kind", Value: edge.kind},
+				{Key: "direction", Value: strconv.Itoa(int(edge.direction))},
+				{Key: "weight", Value: strconv.FormatUint(uint64(edge.weight), 10)},
+			}})
+	}
+	return marshalXML(doc)
+}
+
+//endregion
+
+//region GEXF
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfAttributes struct {
+	Class string          `xml:"class,attr"`
+	Items []gexfAttribute `xml:"attribute"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string      `xml:"id,attr"`
+	Source string      `xml:"source,attr"`
+	Target string      `xml:"target,attr"`
+	Type   string      `xml:"type,attr"`
+	Label  string      `xml:"label,attr"`
+	Weight uint32      `xml:"weight,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfGraph struct {
+	Mode        string           `xml:"mode,attr"`
+	EdgeDefault string           `xml:"defaultedgetype,attr"`
+	Attributes  []gexfAttributes `xml:"attributes"`
+	Nodes       []gexfNode       `xml:"nodes>node"`
+	Edges       []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+func exportGEXF(nodes []*exportNode, edges []*exportEdge) ([]byte, error) {
+	doc := gexfDocument{XMLNS: "http://gexf.net/1.3", Version: "1.3"}
+	doc.Graph = gexfGraph{Mode: "static", EdgeDefault: "undirected"}
+	doc.Graph.Attributes = []gexfAttributes{
+		{Class: "node", Items: []gexfAttribute{
+			{ID: "concept", Title: "concept", Type: "string"},
+			{ID: "label", Title: "label", Type: "string"},
+			{ID: "cover", Title: "cover", Type: "string"},
+		}},
+		{Class: "edge", Items: []gexfAttribute{
+			{ID: "relation", Title: "relation", Type: "string"},
+			{ID: "kind", Title: "kind", Type: "string"},
+			{ID: "direction", Title: "direction", Type: "integer"},
+		}},
+	}
+	for _, node := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{ID: node.id, Label: node.name, Values: []gexfValue{
+			{For: "concept", Value: node.concept},
+			{For: "label", Value: node.label},
+			{For: "cover", Value: node.cover},
+		}})
+	}
+	for _, edge := range edges {
+		tp := "undirected"
+		if edge.directed() {
+			tp = "directed"
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{ID: edge.id, Source: edge.source, Target: edge.target,
+			Type: tp, Label: edge.name, Weight: edge.weight, Values: []gexfValue{
+				{For: "relation", Value: edge.relation},
+				{For: "kind", Value: edge.kind},
+				{For: "direction", Value: strconv.Itoa(int(edge.direction))},
+			}})
+	}
+	return marshalXML(doc)
+}
+
+//endregion
+
+//region JSON Graph
+type jsonGraphNode struct {
+	Label    string                 `json:"label"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type jsonGraphEdge struct {
+	ID       string                 `json:"id"`
+	Source   string                 `json:"source"`
+	Target   string                 `json:"target"`
+	Relation string                 `json:"relation"`
+	Directed bool                   `json:"directed"`
+	Label    string                 `json:"label"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type jsonGraph struct {
+	ID       string                    `json:"id"`
+	Directed bool                      `json:"directed"`
+	Type     string                    `json:"type"`
+	Nodes    map[string]*jsonGraphNode `json:"nodes"`
+	Edges    []*jsonGraphEdge          `json:"edges"`
+}
+
+func exportJSONGraph(center string, nodes []*exportNode, edges []*exportEdge) ([]byte, error) {
+	graph := &jsonGraph{ID: center, Type: "vocabulary", Nodes: make(map[string]*jsonGraphNode, len(nodes)),
+		Edges: make([]*jsonGraphEdge, 0, len(edges))}
+	for _, node := range nodes {
+		graph.Nodes[node.id] = &jsonGraphNode{Label: node.name, Metadata: map[string]interface{}{
+			"concept": node.concept, "label": node.label, "cover": node.cover}}
+	}
+	for _, edge := range edges {
+		graph.Edges = append(graph.Edges, &jsonGraphEdge{ID: edge.id, Source: edge.source, Target: edge.target,
+			Relation: edge.relation, Directed: edge.directed(), Label: edge.name, Metadata: map[string]interface{}{
+				"kind": edge.kind, "direction": edge.direction, "weight": edge.weight}})
+	}
+	return json.MarshalIndent(map[string]interface{}{"graph": graph}, "", "  ")
+}
+
+//endregion
+
+func marshalXML(doc interface{}) ([]byte, error) {
+	bytes, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	builder := new(strings.Builder)
+	builder.WriteString(xml.Header)
+	builder.Write(bytes)
+	return []byte(builder.String()), nil
+}
@@ -0,0 +1,20 @@
+package cache
+
+import "testing"
+
+func TestExportName(t *testing.T) {
+	cases := []struct {
+		center string
+		want   string
+	}{
+		{"5f8d0d55b54764421b7156c9", "5f8d0d55b54764421b7156c9"},
+		{"../../etc/passwd", "unknown"},
+		{"5f8d0d55b54764421b7156c9/../x", "unknown"},
+		{"", "unknown"},
+	}
+	for _, c := range cases {
+		if got := exportName(c.center); got != c.want {
+			t.Errorf("exportName(%q) = %q, want %q", c.center, got, c.want)
+		}
+	}
+}
@@ -458,30 +458,6 @@ func (mine *cacheContext) HadEntity(uid string) bool {
 	//return false
 }
 
-func (mine *cacheContext) RemoveEntity(uid, operator string) error {
-	if len(uid) < 1 {
-		return errors.New("the entity uid is empty")
-	}
-	tmp := mine.GetEntity(uid)
-	if tmp == nil {
-		return nil
-	}
-	if tmp.Status != EntityStatusDraft {
-		return errors.New("the entity status not equal 0 ")
-	}
-
-	err := nosql.RemoveEntity(tmp.table(), uid, operator)
-	if err == nil {
-		t, _ := nosql.GetArchivedByEntity(uid)
-		if t != nil {
-			_ = nosql.RemoveArchived(t.UID.Hex(), operator)
-			//return errors.New("the entity had published")
-		}
-		mine.checkEntityFromBoxes(uid, tmp.Name)
-	}
-	return err
-}
-
 func (mine *cacheContext) HadOwnerOfAsset(owner string) bool {
 	info := mine.GetEntity(owner)
 	if info != nil {
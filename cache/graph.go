@@ -8,6 +8,12 @@ import (
 	"omo.msa.vocabulary/proxy/nosql"
 	"omo.msa.vocabulary/tool"
 	"regexp"
+	"sync"
+)
+
+const (
+	GraphMaxDepth     = 6
+	GraphDefaultLimit = 200
 )
 
 const (
@@ -19,7 +25,11 @@ const (
 	GraphTypeEvent    = "event"
 )
 
+/**
+缓存的节点和关系会被同步任务修改，同时被接口读取，读写都需要加锁
+*/
 type GraphInfo struct {
+	lock   sync.RWMutex
 	center string
 	nodes  []*NodeInfo
 	links  []*LinkInfo
@@ -37,45 +47,29 @@ func (mine *cacheContext) Graph() *GraphInfo {
 	return mine.graph
 }
 
-func (mine *cacheContext) checkRelations(old, now *EntityInfo) {
-	if now == nil {
+/**
+把实体的关系边同步到图数据库，已经存在的关系在同步任务中会跳过
+*/
+func (mine *cacheContext) checkRelations(info *EntityInfo) {
+	if info == nil {
 		return
 	}
-	if old == nil {
-		edges := mine.GetVEdgesByCenter(now.UID)
-		for _, edge := range edges {
-			relationKind := Context().GetRelation(edge.Relation)
-			if relationKind != nil {
-				Context().addSyncLink(now.UID, edge.Source, relationKind.UID, edge.Name, switchRelationToLink(relationKind.Kind), edge.Direction)
-			}
-		}
-	} else {
-		oldList := make([]string, 0, 10)
-		oldEdges := mine.GetVEdgesByCenter(old.UID)
-		for _, edge := range oldEdges {
-			oldList = append(oldList, edge.Source)
-		}
-		newList := make([]string, 0, 10)
-		newEdges := mine.GetVEdgesByCenter(now.UID)
-		for _, relation := range newEdges {
-			newList = append(newList, relation.Source)
-		}
-		for _, oldR := range oldEdges {
-			if !tool.HasItem(newList, oldR.Source) {
-				link := Context().graph.GetRelationBy(now.UID, oldR.Source)
-				if link != nil {
-					_ = Context().graph.RemoveLink(link.ID)
-				}
-			}
-		}
-		for _, nowR := range newEdges {
-			if !tool.HasItem(oldList, nowR.Source) {
-				relationKind := Context().GetRelation(nowR.Source)
-				if relationKind != nil {
-					Context().addSyncLink(now.UID, nowR.Source, relationKind.UID, nowR.Name, switchRelationToLink(relationKind.Kind), nowR.Direction)
-				}
-			}
-		}
+	edges := mine.GetVEdgesByCenter(info.UID)
+	for _, edge := range edges {
+		mine.syncEdgeLink(edge)
+	}
+}
+
+/**
+与对齐图数据库时一致，虚拟关系同步为Source到Target.Entity的关系，目标不是实体时不同步
+*/
+func (mine *cacheContext) syncEdgeLink(edge *VEdgeInfo) {
+	if len(edge.Target.Entity) < 1 || edge.Source == edge.Target.Entity {
+		return
+	}
+	relationKind := mine.GetRelation(edge.Relation)
+	if relationKind != nil {
+		mine.addSyncLink(edge.Source, edge.Target.Entity, relationKind.UID, edge.Name, switchRelationToLink(relationKind.Kind), edge.Direction, edge.Weight)
 	}
 }
 
@@ -96,6 +90,8 @@ func (mine *cacheContext) CreateLink(from, to *NodeInfo, name, relationUID strin
 }
 
 func (mine *GraphInfo) construct() {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
 	mine.nodes = make([]*NodeInfo, 0, 100)
 	mine.links = make([]*LinkInfo, 0, 100)
 }
@@ -104,16 +100,24 @@ func (mine *GraphInfo) initInfo(db *proxy.Graph) {
 	if db == nil {
 		return
 	}
+	nodes := make([]*NodeInfo, 0, len(db.Nodes))
 	for i := 0; i < len(db.Nodes); i += 1 {
 		node := new(NodeInfo)
 		node.initInfo(db.Nodes[i])
-		mine.nodes = append(mine.nodes, node)
+		nodes = append(nodes, node)
 	}
+	mine.lock.Lock()
+	mine.nodes = append(mine.nodes, nodes...)
+	mine.lock.Unlock()
+	links := make([]*LinkInfo, 0, len(db.Links))
 	for i := 0; i < len(db.Links); i += 1 {
 		link := new(LinkInfo)
 		link.initInfo(db.Links[i], mine.GetNodeByID(db.Links[i].From).Entity, mine.GetNodeByID(db.Links[i].To).Entity)
-		mine.links = append(mine.links, link)
+		links = append(links, link)
 	}
+	mine.lock.Lock()
+	mine.links = append(mine.links, links...)
+	mine.lock.Unlock()
 }
 
 func switchGraphNodeType(tp uint32) string {
@@ -256,6 +260,77 @@ func (mine *GraphInfo) GetPath(from, to string) (*GraphInfo, error) {
 	return g, err
 }
 
+/**
+实体之间的一条路径，Cost为路径上所有关系的距离之和
+*/
+type PathInfo struct {
+	Cost  float64
+	Graph *GraphInfo
+}
+
+/**
+查询两个实体之间距离最短的count条路径，weighted为真时关系的权重越高距离越短，
+relations为允许的关系类型UID，excludes为不能经过的实体UID
+*/
+func (mine *GraphInfo) GetPaths(from, to string, weighted bool, relations, excludes []string, count int) ([]*PathInfo, error) {
+	option := &proxy.PathOption{Weighted: weighted, Relations: relations, Excludes: excludes, Count: count}
+	paths, err := proxy.FindPaths(from, to, option)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*PathInfo, 0, len(paths))
+	for _, path := range paths {
+		g := new(GraphInfo)
+		g.construct()
+		g.center = from
+		g.initInfo(&path.Graph)
+		list = append(list, &PathInfo{Cost: path.Cost, Graph: g})
+	}
+	return list, nil
+}
+
+/**
+查询实体在指定深度内的关系图，relations为关系类型UID，concepts为概念类型，为空时不限制，limit为节点数量上限
+*/
+func (mine *GraphInfo) GetNeighborhood(center string, depth int, relations []string, concepts []uint8, limit int) (*GraphInfo, error) {
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > GraphMaxDepth {
+		return nil, errors.New("the depth is more than the max")
+	}
+	if limit < 1 {
+		limit = GraphDefaultLimit
+	}
+	labels := make([]string, 0, len(concepts))
+	for _, tp := range concepts {
+		tmp := &ConceptInfo{Type: tp}
+		if !tool.HasItem(labels, tmp.Label()) {
+			labels = append(labels, tmp.Label())
+		}
+	}
+	db, err := proxy.FindNeighborhood(center, depth, relations, labels, limit)
+	if err != nil {
+		return nil, err
+	}
+	g := new(GraphInfo)
+	g.construct()
+	g.center = center
+	uids := make(map[int64]string, len(db.Nodes))
+	for _, node := range db.Nodes {
+		n := new(NodeInfo)
+		n.initInfo(node)
+		uids[node.ID] = node.UID
+		g.AppendNode(n)
+	}
+	for _, link := range db.Links {
+		l := new(LinkInfo)
+		l.initInfo(link, uids[link.From], uids[link.To])
+		g.AppendLink(l)
+	}
+	return g, nil
+}
+
 func (mine *GraphInfo) SetCenter(node string) {
 	mine.center = node
 }
@@ -265,18 +340,24 @@ func (mine *GraphInfo) Center() string {
 }
 
 func (mine *GraphInfo) Nodes() []*NodeInfo {
-	return mine.nodes
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]*NodeInfo, len(mine.nodes))
+	copy(list, mine.nodes)
+	return list
 }
 
 func (mine *GraphInfo) Links() []*LinkInfo {
-	return mine.links
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]*LinkInfo, len(mine.links))
+	copy(list, mine.links)
+	return list
 }
 
 func (mine *GraphInfo) GetNode(uid string) *NodeInfo {
-	for i := 0; i < len(mine.nodes); i += 1 {
-		if mine.nodes[i].Entity == uid {
-			return mine.nodes[i]
-		}
+	if node := mine.findNode(uid); node != nil {
+		return node
 	}
 	tmp, _ := proxy.GetNode(uid)
 	if tmp != nil {
@@ -288,12 +369,27 @@ func (mine *GraphInfo) GetNode(uid string) *NodeInfo {
 	return nil
 }
 
+func (mine *GraphInfo) findNode(uid string) *NodeInfo {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	for i := 0; i < len(mine.nodes); i += 1 {
+		if mine.nodes[i].Entity == uid {
+			return mine.nodes[i]
+		}
+	}
+	return nil
+}
+
 func (mine *GraphInfo) GetNodeByID(id int64) *NodeInfo {
+	mine.lock.RLock()
 	for i := 0; i < len(mine.nodes); i += 1 {
 		if mine.nodes[i].ID == id {
-			return mine.nodes[i]
+			node := mine.nodes[i]
+			mine.lock.RUnlock()
+			return node
 		}
 	}
+	mine.lock.RUnlock()
 	tmp, _ := proxy.GetNodeByID(id)
 	if tmp != nil {
 		node := new(NodeInfo)
@@ -305,6 +401,8 @@ func (mine *GraphInfo) GetNodeByID(id int64) *NodeInfo {
 }
 
 func (mine *GraphInfo) GetNodeByName(name string) *NodeInfo {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
 	for i := 0; i < len(mine.nodes); i += 1 {
 		if mine.nodes[i].Name == name {
 			return mine.nodes[i]
@@ -314,6 +412,8 @@ func (mine *GraphInfo) GetNodeByName(name string) *NodeInfo {
 }
 
 func (mine *GraphInfo) GetRelationByEntity(node string) *LinkInfo {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].HadNode(node) {
 			return mine.links[i]
@@ -323,6 +423,8 @@ func (mine *GraphInfo) GetRelationByEntity(node string) *LinkInfo {
 }
 
 func (mine *GraphInfo) GetRelation(id int64) *LinkInfo {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].ID == id {
 			return mine.links[i]
@@ -332,11 +434,14 @@ func (mine *GraphInfo) GetRelation(id int64) *LinkInfo {
 }
 
 func (mine *GraphInfo) HadRelation(from, to string, name string) bool {
+	mine.lock.RLock()
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].Name == name && mine.links[i].HadAll(from, to) {
+			mine.lock.RUnlock()
 			return true
 		}
 	}
+	mine.lock.RUnlock()
 	link, _ := proxy.GetLink(from, to)
 	if link != nil && link.Name == name {
 		return true
@@ -345,11 +450,15 @@ func (mine *GraphInfo) HadRelation(from, to string, name string) bool {
 }
 
 func (mine *GraphInfo) GetRelationBy(from, to string) *LinkInfo {
+	mine.lock.RLock()
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].HadAll(from, to) {
-			return mine.links[i]
+			link := mine.links[i]
+			mine.lock.RUnlock()
+			return link
 		}
 	}
+	mine.lock.RUnlock()
 	link, _ := proxy.GetLink(from, to)
 	if link != nil {
 		info := new(LinkInfo)
@@ -360,6 +469,8 @@ func (mine *GraphInfo) GetRelationBy(from, to string) *LinkInfo {
 }
 
 func (mine *GraphInfo) HadLinkNode(uid string) bool {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].HadNode(uid) {
 			return true
@@ -369,6 +480,12 @@ func (mine *GraphInfo) HadLinkNode(uid string) bool {
 }
 
 func (mine *GraphInfo) HadLink(from, to string) bool {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	return mine.hadLink(from, to)
+}
+
+func (mine *GraphInfo) hadLink(from, to string) bool {
 	for i := 0; i < len(mine.links); i += 1 {
 		if mine.links[i].From == from && mine.links[i].To == to {
 			return true
@@ -378,6 +495,12 @@ func (mine *GraphInfo) HadLink(from, to string) bool {
 }
 
 func (mine *GraphInfo) HadNode(uid string) bool {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	return mine.hadNode(uid)
+}
+
+func (mine *GraphInfo) hadNode(uid string) bool {
 	for i := 0; i < len(mine.nodes); i += 1 {
 		if mine.nodes[i].Entity == uid {
 			return true
@@ -391,7 +514,25 @@ func (mine *GraphInfo) UpdateNodeCover(uid string, cover string) error {
 	if node == nil {
 		return errors.New("not found the node in graph")
 	}
-	return node.UpdateCover(cover)
+	mine.updateNodeInfo(uid, node.Name, cover)
+	return nil
+}
+
+/**
+更新缓存节点的名称和封面，替换为新的节点，避免修改其他协程正在读取的节点
+*/
+func (mine *GraphInfo) updateNodeInfo(uid, name, cover string) {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	for i, node := range mine.nodes {
+		if node.Entity == uid {
+			tmp := *node
+			tmp.Name = name
+			tmp.Cover = cover
+			mine.nodes[i] = &tmp
+			return
+		}
+	}
 }
 
 func (mine *GraphInfo) CreateNodeByEntity(entity *EntityInfo) (*NodeInfo, error) {
@@ -466,9 +607,11 @@ func (mine *GraphInfo) CreateLink(from, to *NodeInfo, kind LinkType, name, relat
 func (mine *GraphInfo) RemoveLink(id int64) error {
 	err := proxy.RemoveLink(id)
 	if err == nil {
+		mine.lock.Lock()
+		defer mine.lock.Unlock()
 		for i := 0; i < len(mine.links); i += 1 {
 			if mine.links[i].ID == id {
-				mine.links = append(mine.links[:i], mine.links[i:]...)
+				mine.links = append(mine.links[:i], mine.links[i+1:]...)
 				break
 			}
 		}
@@ -479,9 +622,12 @@ func (mine *GraphInfo) RemoveLink(id int64) error {
 func (mine *GraphInfo) RemoveNode(id int64, label string) error {
 	err := proxy.RemoveNode(id, label)
 	if err == nil {
+		mine.lock.Lock()
+		defer mine.lock.Unlock()
 		for i := 0; i < len(mine.nodes); i += 1 {
 			if mine.nodes[i].ID == id {
-				mine.nodes = append(mine.nodes[:i], mine.nodes[i:]...)
+				mine.removeNodeLinks(mine.nodes[i].Entity)
+				mine.nodes = append(mine.nodes[:i], mine.nodes[i+1:]...)
 				break
 			}
 		}
@@ -489,17 +635,57 @@ func (mine *GraphInfo) RemoveNode(id int64, label string) error {
 	return err
 }
 
+/**
+删除节点时图数据库会一起删除节点的关系，同步清理缓存的关系
+*/
+func (mine *GraphInfo) removeNodeLinks(uid string) {
+	links := make([]*LinkInfo, 0, len(mine.links))
+	for _, link := range mine.links {
+		if !link.HadNode(uid) {
+			links = append(links, link)
+		}
+	}
+	mine.links = links
+}
+
 func (mine *GraphInfo) AppendNode(node *NodeInfo) {
-	if node == nil || mine.HadNode(node.Entity) {
+	if node == nil {
+		return
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	if mine.hadNode(node.Entity) {
 		return
 	}
 	mine.nodes = append(mine.nodes, node)
 }
 
 func (mine *GraphInfo) AppendEdge(link *LinkInfo) {
-	if link == nil || mine.HadLink(link.From, link.To) {
+	if link == nil {
 		return
 	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	if mine.hadLink(link.From, link.To) {
+		return
+	}
+	mine.links = append(mine.links, link)
+}
+
+/**
+按照关系的ID去重，同一对节点之间的多个关系都会保留
+*/
+func (mine *GraphInfo) AppendLink(link *LinkInfo) {
+	if link == nil {
+		return
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	for _, item := range mine.links {
+		if item.ID == link.ID {
+			return
+		}
+	}
 	mine.links = append(mine.links, link)
 }
 
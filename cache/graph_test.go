@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"fmt"
+	"omo.msa.vocabulary/proxy"
+	"sync"
+	"testing"
+)
+
+func TestGraphConcurrentAccess(t *testing.T) {
+	g := new(GraphInfo)
+	g.construct()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i += 1 {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 200; j += 1 {
+				uid := fmt.Sprintf("node-%d-%d", n, j)
+				g.AppendNode(&NodeInfo{ID: int64(n*1000 + j), Entity: uid})
+				g.AppendEdge(&LinkInfo{From: uid, To: "center"})
+				g.updateNodeInfo(uid, "name", "cover")
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j += 1 {
+				for _, node := range g.Nodes() {
+					_ = node.Name + node.Cover
+				}
+				_ = g.HadLinkNode("center")
+				_ = g.Links()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(g.Nodes()) != 800 || len(g.Links()) != 800 {
+		t.Errorf("nodes = %d, links = %d; want 800", len(g.Nodes()), len(g.Links()))
+	}
+	g.AppendNode(&NodeInfo{Entity: "node-0-0"})
+	if len(g.Nodes()) != 800 {
+		t.Error("append a existed node")
+	}
+}
+
+func TestNeighborhoodKeepLinks(t *testing.T) {
+	ctx := initTestContext(t)
+	a, _ := proxy.CreateNode("a", "Person", "a")
+	b, _ := proxy.CreateNode("b", "Person", "b")
+	if _, err := proxy.CreateLink(a.ID, b.ID, "Relation", "friend", "r1", 0, 1); err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if _, err := proxy.CreateLink(a.ID, b.ID, "Relation", "partner", "r2", 0, 1); err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	g, err := ctx.Graph().GetNeighborhood("a", 1, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("get neighborhood: %v", err)
+	}
+	if num := len(g.Links()); num != 2 {
+		t.Errorf("the neighborhood links = %d, want 2", num)
+	}
+}
@@ -0,0 +1,800 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportEntity    = "entity"
+	ImportEvent     = "event"
+	ImportAttribute = "attribute"
+	ImportConcept   = "concept"
+)
+
+const (
+	ImportActionInsert = "insert"
+	ImportActionUpdate = "update"
+	ImportActionSkip   = "skip"
+)
+
+const (
+	ImportMatchMark     = "mark"     //按照采集来源（Mark）匹配
+	ImportMatchName     = "name"     //按照名称和消歧义匹配
+	ImportMatchExternal = "external" //按照外部ID匹配，外部ID保存在Key对应的属性中
+)
+
+const (
+	ImportJobCommitted  uint8 = 0
+	ImportJobRolledBack uint8 = 1
+	ImportJobRunning    uint8 = 2 //正在写入，中断时已经写入的条目也可以回滚
+)
+
+/**
+实体导入时匹配已有实体的方式，Policy为匹配到已有实体时的默认处理（update或者skip），
+每一行也可以通过action列单独指定insert、update或者skip
+*/
+type ImportOption struct {
+	Match  string
+	Key    string
+	Policy string
+}
+
+type importTask func() (string, error)
+
+/**
+导入校验发现的问题，Row为文件中的行号（表格包含表头行）
+*/
+type ImportIssue struct {
+	Row   int
+	Field string
+	Error string
+}
+
+/**
+导入的结果，存在问题时不会写入任何数据
+*/
+type ImportReport struct {
+	Kind      string
+	Total     uint32
+	Valid     uint32
+	Created   uint32
+	Updated   uint32
+	Skipped   uint32
+	Committed bool
+	Job       string //提交后记录的导入任务
+	Issues    []*ImportIssue
+}
+
+func (mine *ImportReport) fail(row int, field, format string, args ...interface{}) {
+	mine.Issues = append(mine.Issues, &ImportIssue{Row: row, Field: field, Error: fmt.Sprintf(format, args...)})
+}
+
+/**
+导入前的校验上下文，记录文件中已经出现的名称，用于发现文件内的重复
+*/
+type importContext struct {
+	report   *ImportReport
+	owner    string
+	operator string
+	names    map[string]int
+	option   *ImportOption
+	attr     *AttributeInfo //按照外部ID匹配时的属性
+	job      *nosql.ImportJob
+}
+
+func (mine *importContext) appendItem(item *nosql.ImportJobItem) {
+	if mine.job == nil {
+		return
+	}
+	mine.job.Items = append(mine.job.Items, item)
+	err := nosql.AppendImportJobItem(mine.job.UID.Hex(), item)
+	if err != nil {
+		logger.Warnf("append the import job item failed that job = %s; row = %d; err = %s", mine.job.UID.Hex(), item.Row, err.Error())
+	}
+}
+
+func (mine *importContext) repeated(row int, field, name string) bool {
+	if line, ok := mine.names[name]; ok {
+		mine.report.fail(row, field, "the %s is repeated with row %d", field, line)
+		return true
+	}
+	mine.names[name] = row
+	return false
+}
+
+/**
+批量导入实体、事件、属性或者概念，先校验全部数据，只有没有问题并且commit为真时才写入数据库，
+提交的实体导入会记录为导入任务，可以整体回滚
+*/
+func (mine *cacheContext) ImportData(kind, format, owner, operator string, body []byte, option *ImportOption, commit bool) (*ImportReport, error) {
+	rows, err := nosql.ParseImport(format, body)
+	if err != nil {
+		return nil, err
+	}
+	if option == nil {
+		option = new(ImportOption)
+	}
+	report := &ImportReport{Kind: kind, Total: uint32(len(rows)), Issues: make([]*ImportIssue, 0, 10)}
+	ctx := &importContext{report: report, owner: owner, operator: operator, names: make(map[string]int, len(rows)), option: option}
+	var check func(*importContext, *nosql.ImportRow) importTask
+	switch kind {
+	case ImportEntity:
+		err = mine.checkImportOption(ctx)
+		if err != nil {
+			return nil, err
+		}
+		check = mine.checkImportEntity
+	case ImportEvent:
+		check = mine.checkImportEvent
+	case ImportAttribute:
+		check = mine.checkImportAttribute
+	case ImportConcept:
+		check = mine.checkImportConcept
+	default:
+		return nil, fmt.Errorf("not support the import kind of %s", kind)
+	}
+	tasks := make([]importTask, 0, len(rows))
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		count := len(report.Issues)
+		task := check(ctx, lowerRow(row))
+		if task != nil && count == len(report.Issues) {
+			tasks = append(tasks, task)
+			lines = append(lines, row.Line)
+		}
+	}
+	report.Valid = uint32(len(tasks))
+	if !commit || len(report.Issues) > 0 {
+		return report, nil
+	}
+	if kind == ImportEntity {
+		// 先记录导入任务，每写入一行追加一个条目，中途失败时也能回滚已经写入的实体
+		ctx.job = &nosql.ImportJob{Kind: kind, Format: format, Owner: owner, Match: option.Match, Total: report.Total,
+			Status: ImportJobRunning, Items: make([]*nosql.ImportJobItem, 0, len(tasks))}
+		err = mine.createImportJob(ctx.job, operator)
+		if err != nil {
+			return nil, err
+		}
+		report.Job = ctx.job.UID.Hex()
+	}
+	report.Committed = true
+	for i, task := range tasks {
+		action, er := task()
+		if er != nil {
+			report.fail(lines[i], "", er.Error())
+			continue
+		}
+		if action == ImportActionUpdate {
+			report.Updated += 1
+		} else {
+			report.Created += 1
+		}
+	}
+	if ctx.job != nil {
+		err = nosql.UpdateImportJobStatus(report.Job, ImportJobCommitted, operator)
+		if err != nil {
+			logger.Warnf("update the import job status failed that job = %s; err = %s", report.Job, err.Error())
+		}
+	}
+	logger.Infof("import the %s done that total = %d; created = %d; updated = %d; skipped = %d; issues = %d",
+		kind, report.Total, report.Created, report.Updated, report.Skipped, len(report.Issues))
+	return report, nil
+}
+
+func (mine *cacheContext) checkImportOption(ctx *importContext) error {
+	switch ctx.option.Policy {
+	case "":
+		ctx.option.Policy = ImportActionSkip
+	case ImportActionUpdate, ImportActionSkip:
+	default:
+		return fmt.Errorf("not support the import policy of %s", ctx.option.Policy)
+	}
+	switch ctx.option.Match {
+	case "", ImportMatchMark, ImportMatchName:
+	case ImportMatchExternal:
+		ctx.option.Key = strings.ToLower(ctx.option.Key)
+		ctx.attr = mine.GetAttributeByKey(ctx.option.Key)
+		if ctx.attr == nil {
+			return fmt.Errorf("not found the attribute of external key %s", ctx.option.Key)
+		}
+	default:
+		return fmt.Errorf("not support the import match of %s", ctx.option.Match)
+	}
+	return nil
+}
+
+func (mine *cacheContext) createImportJob(job *nosql.ImportJob, operator string) error {
+	job.UID = primitive.NewObjectID()
+	job.ID = nosql.GetImportJobNextID()
+	job.CreatedTime = time.Now()
+	job.Created = time.Now().Unix()
+	job.Creator = operator
+	job.Operator = operator
+	return nosql.CreateImportJob(job)
+}
+
+/**
+表头不区分大小写
+*/
+func lowerRow(row *nosql.ImportRow) *nosql.ImportRow {
+	tmp := &nosql.ImportRow{Line: row.Line, Values: make(map[string]string, len(row.Values)),
+		Numbers: make(map[string]bool, len(row.Numbers))}
+	for key, value := range row.Values {
+		tmp.Values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	for key, value := range row.Numbers {
+		tmp.Numbers[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return tmp
+}
+
+func splitImportList(value string) []string {
+	list := make([]string, 0, 5)
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '；'
+	})
+	for _, item := range fields {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+/**
+日期支持 yyyy/mm/dd、xxxx年xx月和年份，Excel中数字类型的单元格为天数，统一转换为 yyyy/mm/dd
+*/
+func importDate(value string, numeric bool) (string, error) {
+	var year uint16
+	var month uint8
+	if numeric {
+		days, err := strconv.ParseFloat(value, 64)
+		if err == nil && days > 0 {
+			year, month = convertExcelDays(int64(days))
+		}
+	} else {
+		tmp := proxy.Date{}
+		if err := tmp.Parse(value); err == nil && tmp.Year > 0 {
+			return value, nil
+		}
+		if strings.Contains(value, "年") {
+			year, month = parseDate(value)
+		} else if num, err := strconv.ParseUint(value, 10, 16); err == nil {
+			year = uint16(num)
+		}
+	}
+	if year < 1 {
+		return "", fmt.Errorf("the date of %s is invalid", value)
+	}
+	if month < 1 {
+		month = 1
+	}
+	return fmt.Sprintf("%d/%d/1", year, month), nil
+}
+
+func (mine *cacheContext) findImportConcept(key string) *ConceptInfo {
+	if len(key) < 1 {
+		return nil
+	}
+	concept := mine.GetConcept(key)
+	if concept != nil {
+		return concept
+	}
+	return mine.GetConceptByName(key)
+}
+
+func (mine *cacheContext) findImportEntity(key string) *EntityInfo {
+	if len(key) < 1 {
+		return nil
+	}
+	entity := mine.GetEntity(key)
+	if entity != nil {
+		return entity
+	}
+	return mine.GetEntityByName(key, "")
+}
+
+var importEntityFields = []string{"name", "add", "concept", "description", "summary", "cover", "mark", "quote", "tags", "synonyms", "action"}
+
+/**
+实体的固定字段之外的列按照属性的Key转换为实体的属性，设置了匹配方式时，
+匹配到的已有实体按照action列或者Policy更新或者跳过，没有匹配到的新建
+*/
+func (mine *cacheContext) checkImportEntity(ctx *importContext, row *nosql.ImportRow) importTask {
+	info := new(EntityInfo)
+	info.Construct()
+	info.Name = row.Values["name"]
+	info.Add = row.Values["add"]
+	info.Description = row.Values["description"]
+	info.Summary = row.Values["summary"]
+	info.Cover = row.Values["cover"]
+	info.Mark = row.Values["mark"]
+	info.Quote = row.Values["quote"]
+	info.Owner = ctx.owner
+	info.Creator = ctx.operator
+	info.Operator = ctx.operator
+	info.Status = EntityStatusDraft
+	info.Tags = splitImportList(row.Values["tags"])
+	info.Synonyms = splitImportList(row.Values["synonyms"])
+	action := strings.ToLower(row.Values["action"])
+	switch action {
+	case "", ImportActionInsert, ImportActionUpdate, ImportActionSkip:
+	default:
+		ctx.report.fail(row.Line, "action", "not support the action of %s", action)
+		return nil
+	}
+	if action == ImportActionSkip {
+		ctx.report.Skipped += 1
+		return nil
+	}
+	matched, err := mine.matchImportEntity(ctx, row)
+	if err != nil {
+		ctx.report.fail(row.Line, ctx.option.Match, err.Error())
+		return nil
+	}
+	if len(action) < 1 {
+		action = ImportActionInsert
+		if matched != nil {
+			action = ctx.option.Policy
+		}
+	}
+	switch action {
+	case ImportActionSkip:
+		ctx.report.Skipped += 1
+		return nil
+	case ImportActionInsert:
+		if matched != nil {
+			ctx.report.fail(row.Line, ctx.option.Match, "the entity had existed that uid = %s", matched.UID)
+		} else if len(info.Name) < 1 {
+			ctx.report.fail(row.Line, "name", "the entity name is empty")
+		} else if !ctx.repeated(row.Line, "name", info.Name+"-"+info.Add) && mine.HadEntityByName(info.Name, info.Add, ctx.owner) {
+			ctx.report.fail(row.Line, "name", "the entity name is repeated")
+		}
+	default:
+		if matched == nil {
+			ctx.report.fail(row.Line, "action", "not found the entity to update")
+			return nil
+		}
+		if !ctx.repeated(row.Line, "entity", matched.UID) && matched.Status != EntityStatusDraft {
+			ctx.report.fail(row.Line, "action", "the entity is not draft so can not update")
+		}
+	}
+	if key := row.Values["concept"]; len(key) > 0 {
+		concept := mine.findImportConcept(key)
+		if concept == nil {
+			ctx.report.fail(row.Line, "concept", "not found the concept of %s", key)
+		} else {
+			info.Concept = concept.UID
+		}
+	}
+	keys := make([]string, 0, len(row.Values))
+	for key := range row.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := row.Values[key]
+		if len(value) < 1 || isImportField(key) {
+			continue
+		}
+		attr := mine.GetAttributeByKey(key)
+		if attr == nil {
+			ctx.report.fail(row.Line, key, "not found the attribute by key")
+			continue
+		}
+		words, err := mine.importWords(attr, value, row.Numbers[key])
+		if err != nil {
+			ctx.report.fail(row.Line, key, err.Error())
+			continue
+		}
+		info.Properties = append(info.Properties, &proxy.PropertyInfo{Key: attr.UID, Words: words})
+	}
+	if action == ImportActionUpdate {
+		return func() (string, error) {
+			snapshot, err := nosql.GetEntity(matched.table(), matched.UID)
+			if err != nil {
+				return action, err
+			}
+			err = mine.mergeImportEntity(matched, info, ctx.operator)
+			// 部分字段可能已经更新，失败时也记录快照用于回滚
+			ctx.appendItem(&nosql.ImportJobItem{Row: row.Line, Action: action, Entity: matched.UID,
+				Table: matched.table(), Snapshot: snapshot, Updated: importUpdated(matched.table(), matched.UID)})
+			return action, err
+		}
+	}
+	return func() (string, error) {
+		err := mine.CreateEntity(info, nil)
+		if err == nil {
+			ctx.appendItem(&nosql.ImportJobItem{Row: row.Line, Action: action, Entity: info.UID, Table: info.table(),
+				Updated: importUpdated(info.table(), info.UID)})
+		}
+		return action, err
+	}
+}
+
+/**
+新建的实体没有更新时间，使用创建时间
+*/
+func importUpdated(table, uid string) int64 {
+	db, err := nosql.GetEntity(table, uid)
+	if err != nil {
+		return 0
+	}
+	if db.Updated < db.Created {
+		return db.Created
+	}
+	return db.Updated
+}
+
+/**
+按照导入选项匹配当前单位下的已有实体，没有设置匹配方式或者没有匹配到时返回nil
+*/
+func (mine *cacheContext) matchImportEntity(ctx *importContext, row *nosql.ImportRow) (*EntityInfo, error) {
+	switch ctx.option.Match {
+	case ImportMatchMark:
+		mark := row.Values["mark"]
+		if len(mark) < 1 {
+			return nil, errors.New("the mark is empty")
+		}
+		entity := mine.GetEntityByMark(mark)
+		if entity != nil && entity.Owner == ctx.owner {
+			return entity, nil
+		}
+	case ImportMatchName:
+		name := row.Values["name"]
+		if len(name) < 1 {
+			return nil, errors.New("the entity name is empty")
+		}
+		entity := mine.GetEntityByName(name, row.Values["add"])
+		if entity != nil && entity.Owner == ctx.owner && entity.Add == row.Values["add"] {
+			return entity, nil
+		}
+	case ImportMatchExternal:
+		value := row.Values[ctx.option.Key]
+		if len(value) < 1 {
+			return nil, fmt.Errorf("the external id of %s is empty", ctx.option.Key)
+		}
+		var matched *EntityInfo
+		for _, entity := range mine.GetEntitiesByProp(ctx.attr.UID, value) {
+			if entity.Owner != ctx.owner {
+				continue
+			}
+			if matched != nil {
+				return nil, fmt.Errorf("the external id of %s matched more than one entity", value)
+			}
+			matched = entity
+		}
+		return matched, nil
+	}
+	return nil, nil
+}
+
+/**
+用导入的数据更新已有实体，空的字段保持不变，属性按照Key替换或者追加
+*/
+func (mine *cacheContext) mergeImportEntity(entity, info *EntityInfo, operator string) error {
+	pick := func(value, old string) string {
+		if len(value) < 1 {
+			return old
+		}
+		return value
+	}
+	err := entity.UpdateBase(info.Name, pick(info.Description, entity.Description), pick(info.Add, entity.Add), info.Concept,
+		pick(info.Cover, entity.Cover), info.Mark, info.Quote, pick(info.Summary, entity.Summary), operator)
+	if err != nil {
+		return err
+	}
+	if len(info.Tags) > 0 {
+		err = entity.UpdateTags(info.Tags, operator)
+		if err != nil {
+			return err
+		}
+	}
+	if len(info.Synonyms) > 0 {
+		err = entity.UpdateSynonyms(info.Synonyms, operator)
+		if err != nil {
+			return err
+		}
+	}
+	if len(info.Properties) < 1 {
+		return nil
+	}
+	props := make([]*proxy.PropertyInfo, 0, len(entity.Properties)+len(info.Properties))
+	props = append(props, entity.Properties...)
+	for _, prop := range info.Properties {
+		replaced := false
+		for i, item := range props {
+			if item.Key == prop.Key {
+				props[i] = prop
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			props = append(props, prop)
+		}
+	}
+	return entity.UpdateProperties(props, operator)
+}
+
+/**
+回滚导入任务：删除新建的实体，更新过的实体恢复到导入前的快照，每个条目回滚后单独记录，再次回滚时跳过；
+导入后又被修改过的实体不会回滚，新建的实体按照级联方式删除，同时清理收藏夹等对它的引用
+*/
+func (mine *cacheContext) RollbackImportJob(uid, operator string) error {
+	job, err := nosql.GetImportJob(uid)
+	if err != nil {
+		return err
+	}
+	if job.Status == ImportJobRolledBack {
+		return errors.New("the import job had rolled back")
+	}
+	failed := 0
+	changed := 0
+	for i := len(job.Items) - 1; i >= 0; i -= 1 {
+		item := job.Items[i]
+		if item.Done {
+			continue
+		}
+		db, er := nosql.GetEntity(item.Table, item.Entity)
+		if er != nil || db.Deleted > 0 {
+			// 实体已经不存在，没有需要回滚的数据
+			_ = nosql.UpdateImportJobItemDone(uid, i)
+			continue
+		}
+		if item.Updated > 0 && db.Updated > item.Updated {
+			changed += 1
+			logger.Warnf("the import item had changed after import that job = %s; row = %d; entity = %s",
+				uid, item.Row, item.Entity)
+			continue
+		}
+		if item.Action == ImportActionUpdate {
+			er = mine.rollbackImportUpdate(uid, item, operator)
+		} else {
+			_, er = mine.RemoveEntity(item.Entity, operator, RemoveCascade)
+		}
+		if er != nil {
+			failed += 1
+			logger.Warnf("rollback the import item failed that job = %s; row = %d; entity = %s; err = %s",
+				uid, item.Row, item.Entity, er.Error())
+			continue
+		}
+		er = nosql.UpdateImportJobItemDone(uid, i)
+		if er != nil {
+			logger.Warnf("update the import item failed that job = %s; row = %d; err = %s", uid, item.Row, er.Error())
+		}
+	}
+	if failed > 0 || changed > 0 {
+		return fmt.Errorf("rollback the import job failed that failed = %d; changed = %d", failed, changed)
+	}
+	return nosql.UpdateImportJobStatus(uid, ImportJobRolledBack, operator)
+}
+
+func (mine *cacheContext) rollbackImportUpdate(job string, item *nosql.ImportJobItem, operator string) error {
+	if item.Snapshot == nil {
+		return nil
+	}
+	before := loadRevisionState(item.Table, item.Entity)
+	err := nosql.RestoreEntity(item.Table, item.Snapshot, operator)
+	if err != nil {
+		return err
+	}
+	mine.addSyncUpdateNode(item.Entity)
+	if after := loadRevisionState(item.Table, item.Entity); before != nil && after != nil {
+		_ = mine.createRevision(item.Entity, "import_rollback", job, operator, before, after, diffRevision(before, after))
+	}
+	return nil
+}
+
+func (mine *cacheContext) GetImportJob(uid string) *nosql.ImportJob {
+	job, err := nosql.GetImportJob(uid)
+	if err != nil {
+		return nil
+	}
+	return job
+}
+
+func (mine *cacheContext) GetImportJobs(owner string, num int64) []*nosql.ImportJob {
+	list, err := nosql.GetImportJobsByOwner(owner, num)
+	if err != nil {
+		return make([]*nosql.ImportJob, 0, 1)
+	}
+	return list
+}
+
+func isImportField(key string) bool {
+	for _, item := range importEntityFields {
+		if item == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (mine *cacheContext) importWords(attr *AttributeInfo, value string, numeric bool) ([]proxy.WordInfo, error) {
+	words := make([]proxy.WordInfo, 0, 1)
+	switch attr.Kind {
+	case AttributeTypeDate:
+		date, err := importDate(value, numeric)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, proxy.WordInfo{Name: date})
+	case AttributeTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("the value of %s is not a number", value)
+		}
+		words = append(words, proxy.WordInfo{Name: value})
+	case AttributeTypeEntity:
+		for _, item := range splitImportList(value) {
+			word := proxy.WordInfo{Name: item}
+			if entity := mine.findImportEntity(item); entity != nil {
+				word.UID = entity.UID
+				word.Name = entity.Name
+			}
+			words = append(words, word)
+		}
+	default:
+		words = append(words, proxy.WordInfo{Name: value})
+	}
+	return words, nil
+}
+
+func (mine *cacheContext) checkImportEvent(ctx *importContext, row *nosql.ImportRow) importTask {
+	req := &pb.ReqEventAdd{Date: new(pb.DateInfo), Place: new(pb.PlaceInfo)}
+	req.Name = row.Values["name"]
+	req.Description = row.Values["description"]
+	req.Quote = row.Values["quote"]
+	req.Cover = row.Values["cover"]
+	req.Certify = row.Values["certify"]
+	req.Operator = ctx.operator
+	req.Owner = ctx.owner
+	if len(row.Values["owner"]) > 0 {
+		req.Owner = row.Values["owner"]
+	}
+	req.Tags = splitImportList(row.Values["tags"])
+	req.Assets = splitImportList(row.Values["assets"])
+	req.Place.Name = row.Values["place"]
+	req.Place.Location = row.Values["location"]
+	if len(req.Name) < 1 {
+		ctx.report.fail(row.Line, "name", "the event name is empty")
+	}
+	for _, key := range []string{"type", "sub", "access"} {
+		if value := row.Values[key]; len(value) > 0 {
+			num, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				ctx.report.fail(row.Line, key, "the %s is not a number", key)
+				continue
+			}
+			switch key {
+			case "type":
+				req.Type = uint32(num)
+			case "sub":
+				req.Sub = uint32(num)
+			default:
+				req.Access = uint32(num)
+			}
+		}
+	}
+	for _, key := range []string{"begin", "end"} {
+		if value := row.Values[key]; len(value) > 0 {
+			date, err := importDate(value, row.Numbers[key])
+			if err != nil {
+				ctx.report.fail(row.Line, key, err.Error())
+				continue
+			}
+			if key == "begin" {
+				req.Date.Begin = date
+				req.Date.Name = value
+			} else {
+				req.Date.End = date
+			}
+		}
+	}
+	entity := mine.findImportEntity(row.Values["entity"])
+	if entity == nil {
+		ctx.report.fail(row.Line, "entity", "not found the entity of %s", row.Values["entity"])
+		return nil
+	}
+	return func() (string, error) {
+		_, err := entity.AddEvent(req)
+		return ImportActionInsert, err
+	}
+}
+
+func (mine *cacheContext) checkImportAttribute(ctx *importContext, row *nosql.ImportRow) importTask {
+	info := new(AttributeInfo)
+	info.Key = strings.ToLower(row.Values["key"])
+	info.Name = row.Values["name"]
+	info.Remark = row.Values["remark"]
+	info.Begin = row.Values["begin"]
+	info.End = row.Values["end"]
+	info.Creator = ctx.operator
+	if value := row.Values["kind"]; len(value) > 0 {
+		kind, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			ctx.report.fail(row.Line, "kind", "the kind is not a number")
+		}
+		info.Kind = AttributeType(kind)
+	}
+	if len(info.Name) < 1 {
+		ctx.report.fail(row.Line, "name", "the attribute name is empty")
+	}
+	if len(info.Key) < 1 {
+		ctx.report.fail(row.Line, "key", "the attribute key is empty")
+	} else if !ctx.repeated(row.Line, "key", info.Key) && mine.HadAttributeByKey(info.Key) {
+		ctx.report.fail(row.Line, "key", "the attribute key is repeated")
+	}
+	return func() (string, error) {
+		return ImportActionInsert, mine.CreateAttribute(info)
+	}
+}
+
+/**
+上级概念可以是已有的概念，也可以是文件中前面行的概念
+*/
+func (mine *cacheContext) checkImportConcept(ctx *importContext, row *nosql.ImportRow) importTask {
+	info := new(ConceptInfo)
+	info.Name = row.Values["name"]
+	info.Table = row.Values["table"]
+	info.Remark = row.Values["remark"]
+	info.Cover = row.Values["cover"]
+	info.Creator = ctx.operator
+	for _, key := range []string{"type", "scene"} {
+		if value := row.Values[key]; len(value) > 0 {
+			num, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				ctx.report.fail(row.Line, key, "the %s is not a number", key)
+				continue
+			}
+			if key == "type" {
+				info.Type = uint8(num)
+			} else {
+				info.Scene = uint8(num)
+			}
+		}
+	}
+	parent := row.Values["parent"]
+	if len(info.Name) < 1 {
+		ctx.report.fail(row.Line, "name", "the concept name is empty")
+		return nil
+	}
+	if len(parent) > 0 {
+		if _, ok := ctx.names[parent]; !ok && mine.findImportConcept(parent) == nil {
+			ctx.report.fail(row.Line, "parent", "not found the parent concept of %s", parent)
+		}
+	}
+	if ctx.repeated(row.Line, "name", info.Name) {
+		return nil
+	}
+	top := ""
+	if tmp := mine.findImportConcept(parent); tmp != nil {
+		top = tmp.UID
+	}
+	if len(parent) < 1 || len(top) > 0 {
+		if mine.HadConceptByName(info.Name, top) {
+			ctx.report.fail(row.Line, "name", "the concept name is repeated")
+		}
+	}
+	return func() (string, error) {
+		if len(parent) < 1 {
+			return ImportActionInsert, mine.CreateTopConcept(info)
+		}
+		tmp := mine.findImportConcept(parent)
+		if tmp == nil {
+			return ImportActionInsert, fmt.Errorf("not found the parent concept of %s", parent)
+		}
+		return ImportActionInsert, tmp.CreateChild(info)
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+	"time"
+)
+
+func TestImportDate(t *testing.T) {
+	cases := []struct {
+		value   string
+		numeric bool
+		want    string
+	}{
+		{"1990/5/3", false, "1990/5/3"},
+		{"1990", false, "1990/1/1"},
+		{"1990年5月", false, "1990/5/1"},
+		{"32874", true, "1990/1/1"},
+		{"32874.5", true, "1990/1/1"},
+	}
+	for _, c := range cases {
+		got, err := importDate(c.value, c.numeric)
+		if err != nil || got != c.want {
+			t.Errorf("importDate(%q, %v) = %q, %v; want %q", c.value, c.numeric, got, err, c.want)
+		}
+	}
+	for _, value := range []string{"", "abc", "0"} {
+		if _, err := importDate(value, false); err == nil {
+			t.Errorf("importDate(%q) should fail", value)
+		}
+	}
+}
+
+func TestRollbackImportJob(t *testing.T) {
+	ctx := initTestContext(t)
+	old := newTestEntity(t, "old")
+	body := []byte("name,summary,action\nold,s1,update\nnew1,,\nnew2,,\n")
+	option := &ImportOption{Match: ImportMatchName}
+	report, err := ctx.ImportData(ImportEntity, "csv", "", "tester", body, option, true)
+	if err != nil || len(report.Issues) > 0 {
+		t.Fatalf("import entities: %v, %v", err, report)
+	}
+	job := ctx.GetImportJob(report.Job)
+	if job == nil || len(job.Items) != 3 {
+		t.Fatalf("the import job items = %v, want 3", job)
+	}
+	if now := ctx.GetEntity(old.UID); now.Summary != "s1" {
+		t.Fatalf("the imported summary = %s, want s1", now.Summary)
+	}
+	first := ctx.GetEntityByName("new1", "")
+	second := ctx.GetEntityByName("new2", "")
+	// 新建的实体已经放到收藏夹中，回滚时一起清理
+	box := &BoxInfo{}
+	box.Name = "box"
+	box.Creator = "tester"
+	if err = ctx.CreateBox(box); err != nil {
+		t.Fatalf("create box: %v", err)
+	}
+	if err = box.AppendKeywords([]string{first.UID}, "tester"); err != nil {
+		t.Fatalf("append keywords: %v", err)
+	}
+	// 导入后又被修改过的实体不回滚
+	later := bson.M{nosql.TimeUpdated: time.Now().Unix() + 10}
+	if err = nosql.UpdateEntityFields(second.table(), second.UID, "tester", later); err != nil {
+		t.Fatalf("update entity: %v", err)
+	}
+
+	if err = ctx.RollbackImportJob(report.Job, "tester"); err == nil {
+		t.Fatal("rollback should fail when the entity changed after import")
+	}
+	if ctx.GetEntity(first.UID) != nil || ctx.GetBox(box.UID).HadContent(first.UID) {
+		t.Error("the created entity and its box content should be removed")
+	}
+	if ctx.GetEntity(second.UID) == nil {
+		t.Error("the changed entity should be kept")
+	}
+	if now := ctx.GetEntity(old.UID); now.Summary != old.Summary {
+		t.Errorf("the updated entity summary = %s, want %s", now.Summary, old.Summary)
+	}
+	job = ctx.GetImportJob(report.Job)
+	for _, item := range job.Items {
+		if item.Done == (item.Entity == second.UID) {
+			t.Errorf("the item of row %d done = %v", item.Row, item.Done)
+		}
+	}
+
+	// 修改的实体删除后再次回滚，已经回滚的条目跳过
+	if _, err = ctx.RemoveEntity(second.UID, "tester", RemoveRefuse); err != nil {
+		t.Fatalf("remove entity: %v", err)
+	}
+	if err = ctx.RollbackImportJob(report.Job, "tester"); err != nil {
+		t.Fatalf("rollback again: %v", err)
+	}
+	if job = ctx.GetImportJob(report.Job); job.Status != ImportJobRolledBack {
+		t.Errorf("the job status = %d, want rolled back", job.Status)
+	}
+}
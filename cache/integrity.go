@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	IntegrityPropertyAttribute = "property_attribute" //实体属性对应的属性定义已经删除
+	IntegrityPropertyEntity    = "property_entity"    //实体属性值引用的实体已经删除
+	IntegrityEdgeRelation      = "edge_relation"      //关系边的关系类型已经删除
+	IntegrityEdgeCenter        = "edge_center"        //关系边的中心实体已经删除
+	IntegrityEdgeTarget        = "edge_target"        //关系边的目标实体已经删除
+	IntegrityConceptParent     = "concept_parent"     //概念的上级已经删除
+	IntegrityBoxContent        = "box_content"        //收藏夹内容中的实体已经删除
+	IntegrityEventEntity       = "event_entity"       //事件所属的实体已经删除
+)
+
+const integrityOperator = "integrity"
+
+/**
+一条引用错误，Fixable为真时可以自动修复且不会丢失有效数据，
+引用的实体在回收站中时还可以恢复，只报告不自动修复
+*/
+type IntegrityIssue struct {
+	Kind    string `json:"kind"`
+	Table   string `json:"table"`
+	UID     string `json:"uid"`
+	Ref     string `json:"ref"`     //缺失的引用
+	Trashed bool   `json:"trashed"` //引用的实体在回收站中
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed"`
+}
+
+type IntegrityReport struct {
+	Fix      bool              `json:"fix"`
+	Started  int64             `json:"started"`
+	Finished int64             `json:"finished"`
+	Scanned  uint32            `json:"scanned"`
+	Fixed    uint32            `json:"fixed"`
+	Issues   []*IntegrityIssue `json:"issues"`
+	Errors   []string          `json:"errors"`
+}
+
+type integrityState struct {
+	running int32
+	lock    sync.Mutex
+	last    *IntegrityReport
+}
+
+/**
+按照类型统计错误数量
+*/
+func (mine *IntegrityReport) Count(kind string) uint32 {
+	var count uint32 = 0
+	for _, item := range mine.Issues {
+		if item.Kind == kind {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (mine *IntegrityReport) add(kind, table, uid, ref string, fixable bool) {
+	mine.Issues = append(mine.Issues, &IntegrityIssue{Kind: kind, Table: table, UID: uid, Ref: ref, Fixable: fixable})
+}
+
+/**
+引用的实体已经删除，实体在回收站中时不能自动修复，否则恢复实体后引用已经丢失
+*/
+func (mine *IntegrityReport) addEntity(kind, table, uid, ref string, trashed map[string]bool) {
+	mine.Issues = append(mine.Issues, &IntegrityIssue{Kind: kind, Table: table, UID: uid, Ref: ref,
+		Trashed: trashed[ref], Fixable: !trashed[ref]})
+}
+
+func isObjectID(uid string) bool {
+	_, err := primitive.ObjectIDFromHex(uid)
+	return err == nil
+}
+
+func (mine *cacheContext) GetLastIntegrity() *IntegrityReport {
+	mine.integrity.lock.Lock()
+	defer mine.integrity.lock.Unlock()
+	return mine.integrity.last
+}
+
+/**
+检查全部数据的引用关系，fix为真时修复可以安全修复的错误
+*/
+func (mine *cacheContext) CheckIntegrity(fix bool) (*IntegrityReport, error) {
+	if !atomic.CompareAndSwapInt32(&mine.integrity.running, 0, 1) {
+		return nil, errors.New("the integrity check is running")
+	}
+	defer atomic.StoreInt32(&mine.integrity.running, 0)
+	report := &IntegrityReport{Fix: fix, Started: time.Now().Unix(), Issues: make([]*IntegrityIssue, 0, 10),
+		Errors: make([]string, 0, 1)}
+	entities := make(map[string]bool, 1000)
+	trashed := make(map[string]bool, 100)
+	for _, table := range mine.EntityTables() {
+		dbs, err := nosql.GetEntities(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, db := range dbs {
+			entities[db.UID.Hex()] = true
+		}
+		items, err := nosql.GetTrashItems(table, "", "", 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			trashed[item.UID.Hex()] = true
+		}
+	}
+	err := mine.checkIntegrityEntities(report, entities, trashed)
+	if err == nil {
+		err = mine.checkIntegrityEdges(report, entities, trashed)
+	}
+	if err == nil {
+		err = mine.checkIntegrityConcepts(report)
+	}
+	if err == nil {
+		err = mine.checkIntegrityBoxes(report, entities, trashed)
+	}
+	if err == nil {
+		err = mine.checkIntegrityEvents(report, entities, trashed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fix {
+		mine.fixIntegrity(report)
+	}
+	report.Finished = time.Now().Unix()
+	mine.integrity.lock.Lock()
+	mine.integrity.last = report
+	mine.integrity.lock.Unlock()
+	logger.Infof("check the integrity done that scanned = %d; issues = %d; fixed = %d", report.Scanned, len(report.Issues), report.Fixed)
+	return report, nil
+}
+
+func (mine *cacheContext) checkIntegrityEntities(report *IntegrityReport, entities, trashed map[string]bool) error {
+	attributes, err := nosql.GetAllAttributes()
+	if err != nil {
+		return err
+	}
+	attrs := make(map[string]bool, len(attributes))
+	for _, item := range attributes {
+		attrs[item.UID.Hex()] = true
+	}
+	for _, table := range mine.EntityTables() {
+		dbs, er := nosql.GetEntities(table)
+		if er != nil {
+			return er
+		}
+		for _, db := range dbs {
+			report.Scanned += 1
+			for _, prop := range db.Properties {
+				if !attrs[prop.Key] {
+					report.add(IntegrityPropertyAttribute, table, db.UID.Hex(), prop.Key, false)
+				}
+				for _, word := range prop.Words {
+					if len(word.UID) > 0 && !entities[word.UID] {
+						report.addEntity(IntegrityPropertyEntity, table, db.UID.Hex(), word.UID, trashed)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (mine *cacheContext) checkIntegrityEdges(report *IntegrityReport, entities, trashed map[string]bool) error {
+	relations, err := nosql.GetAllEnable[nosql.Relation](nosql.TableRelation)
+	if err != nil {
+		return err
+	}
+	kinds := make(map[string]bool, len(relations))
+	for _, item := range relations {
+		kinds[item.UID.Hex()] = true
+	}
+	edges, err := nosql.GetAllEnable[nosql.VEdge](nosql.TableEdge)
+	if err != nil {
+		return err
+	}
+	for _, edge := range edges {
+		report.Scanned += 1
+		uid := edge.UID.Hex()
+		if !entities[edge.Center] {
+			report.addEntity(IntegrityEdgeCenter, nosql.TableEdge, uid, edge.Center, trashed)
+			continue
+		}
+		if len(edge.Target.Entity) > 0 && !entities[edge.Target.Entity] {
+			report.addEntity(IntegrityEdgeTarget, nosql.TableEdge, uid, edge.Target.Entity, trashed)
+		}
+		// 关系类型也可能是关系的名称
+		if isObjectID(edge.Catalog) && !kinds[edge.Catalog] {
+			report.add(IntegrityEdgeRelation, nosql.TableEdge, uid, edge.Catalog, false)
+		}
+	}
+	return nil
+}
+
+func (mine *cacheContext) checkIntegrityConcepts(report *IntegrityReport) error {
+	concepts, err := nosql.GetAllEnable[nosql.Concept](nosql.TableConcept)
+	if err != nil {
+		return err
+	}
+	all := make(map[string]bool, len(concepts))
+	for _, item := range concepts {
+		all[item.UID.Hex()] = true
+	}
+	for _, item := range concepts {
+		report.Scanned += 1
+		if len(item.Parent) > 0 && !all[item.Parent] {
+			report.add(IntegrityConceptParent, nosql.TableConcept, item.UID.Hex(), item.Parent, false)
+		}
+	}
+	return nil
+}
+
+func (mine *cacheContext) checkIntegrityBoxes(report *IntegrityReport, entities, trashed map[string]bool) error {
+	boxes, err := nosql.GetAllEnable[nosql.Box](nosql.TableBox)
+	if err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		report.Scanned += 1
+		for _, item := range box.Contents {
+			// 没有实体的内容只有名称
+			if isObjectID(item.Keyword) && !entities[item.Keyword] {
+				report.addEntity(IntegrityBoxContent, nosql.TableBox, box.UID.Hex(), item.Keyword, trashed)
+			}
+		}
+	}
+	return nil
+}
+
+func (mine *cacheContext) checkIntegrityEvents(report *IntegrityReport, entities, trashed map[string]bool) error {
+	events, err := nosql.GetAllEnable[nosql.Event](nosql.TableEvent)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		report.Scanned += 1
+		if len(event.Entity) > 0 && !entities[event.Entity] {
+			report.addEntity(IntegrityEventEntity, nosql.TableEvent, event.UID.Hex(), event.Entity, trashed)
+		}
+	}
+	return nil
+}
+
+/**
+修复可以安全修复的错误，删除的事件和关系可以从回收站恢复
+*/
+func (mine *cacheContext) fixIntegrity(report *IntegrityReport) {
+	for _, item := range report.Issues {
+		if !item.Fixable {
+			continue
+		}
+		var err error
+		switch item.Kind {
+		case IntegrityPropertyEntity:
+			entity := mine.GetEntity(item.UID)
+			if entity == nil {
+				err = errors.New("not found the entity")
+			} else {
+				err = entity.removeEntityReference(item.Ref, integrityOperator)
+			}
+		case IntegrityEdgeCenter, IntegrityEdgeTarget:
+			err = mine.RemoveVEdge(item.UID, integrityOperator)
+		case IntegrityBoxContent:
+			box := mine.GetBox(item.UID)
+			if box == nil {
+				err = errors.New("not found the box")
+			} else {
+				err = box.RemoveKeywords([]string{item.Ref}, integrityOperator)
+			}
+		case IntegrityEventEntity:
+			err = nosql.RemoveEvent(item.UID, integrityOperator)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %s", item.Kind, item.UID, err.Error()))
+		} else {
+			item.Fixed = true
+			report.Fixed += 1
+		}
+	}
+}
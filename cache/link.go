@@ -20,6 +20,13 @@ const (
 type LinkType string
 type DirectionType uint8
 
+/**
+图数据库允许使用的关系类型
+*/
+func linkKinds() []string {
+	return []string{string(LinkTypeEmpty), string(LinkTypePersons), string(LinkTypeEvents), string(LinkTypeInhuman)}
+}
+
 type LinkInfo struct {
 	Direction DirectionType
 	ID        int64
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy/nosql"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MigrationPending = 0
+	MigrationDone    = 1
+	MigrationFailed  = 2
+)
+
+// 迁移锁的过期时间，持有锁的实例异常退出后其他实例可以在过期后继续执行
+const migrationExpire = 30 * 60
+
+// 执行迁移时刷新锁的间隔，单个迁移执行的时间超过过期时间时锁也不会被其他实例获取
+var migrationRefresh = time.Second * migrationExpire / 3
+
+/**
+数据迁移，版本号递增且发布后不能修改，每个迁移都要可以重复执行，
+fixup为针对早期数据的一次性修复，只有配置了fixups时才执行
+*/
+type migrationStep struct {
+	version uint32
+	name    string
+	fixup   bool
+	handle  func() error
+}
+
+/**
+迁移的状态
+*/
+type MigrationInfo struct {
+	Version  uint32 `json:"version"`
+	Name     string `json:"name"`
+	Status   uint8  `json:"status"`
+	Owner    string `json:"owner"`
+	Started  int64  `json:"started"`
+	Finished int64  `json:"finished"`
+	Error    string `json:"error"`
+}
+
+func migrationSteps() []*migrationStep {
+	list := make([]*migrationStep, 0, 10)
+	list = append(list, &migrationStep{version: 1, name: "switch_attributes", fixup: true, handle: switchAttributes})
+	list = append(list, &migrationStep{version: 2, name: "repeated_attributes", fixup: true, handle: CheckRepeatedAttribute})
+	list = append(list, &migrationStep{version: 3, name: "entity_letters", fixup: true, handle: checkEntityLetters})
+	list = append(list, &migrationStep{version: 4, name: "item_times", fixup: true, handle: nosql.CheckTimes})
+	list = append(list, &migrationStep{version: 5, name: "sequences", handle: CheckSequence})
+	list = append(list, &migrationStep{version: 6, name: "concept_types", handle: CheckConcepts})
+	list = append(list, &migrationStep{version: 7, name: "archived_format", handle: migrateArchivedFormat})
+	return list
+}
+
+func migrateArchivedFormat() error {
+	result, err := cacheCtx.MigrateArchives(false)
+	if err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("migrate the archives failed that count = %d", len(result.Failed))
+	}
+	return nil
+}
+
+func migrationOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+/**
+返回全部迁移以及执行的状态
+*/
+func (mine *cacheContext) GetMigrations() ([]*MigrationInfo, error) {
+	dbs, err := nosql.GetMigrations()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*MigrationInfo, 0, len(dbs))
+	for _, step := range migrationSteps() {
+		info := &MigrationInfo{Version: step.version, Name: step.name, Status: MigrationPending}
+		for _, db := range dbs {
+			if db.Version == step.version {
+				info.Status = db.Status
+				info.Owner = db.Owner
+				info.Started = db.Started
+				info.Finished = db.Finished
+				info.Error = db.Error
+				break
+			}
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+/**
+按照版本顺序执行还没有成功的迁移，多个实例同时启动时只有获取到锁的实例执行，
+没有配置fixups时跳过一次性修复，某个迁移失败后停止执行后面的迁移，下次启动时重试，
+返回本次执行成功的数量
+*/
+func (mine *cacheContext) RunMigrations() (uint32, error) {
+	if !atomic.CompareAndSwapInt32(&mine.migrating, 0, 1) {
+		return 0, errors.New("the migrations is running")
+	}
+	defer atomic.StoreInt32(&mine.migrating, 0)
+	owner := migrationOwner()
+	locked, err := nosql.LockMigration(owner, migrationExpire)
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		logger.Info("the migrations is running by other instance")
+		return 0, nil
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		// 等待刷新锁的协程退出后再释放锁
+		close(done)
+		<-stopped
+		if er := nosql.UnlockMigration(owner); er != nil {
+			logger.Warn("unlock the migrations failed that " + er.Error())
+		}
+	}()
+	go func() {
+		keepMigrationLock(owner, done)
+		close(stopped)
+	}()
+	list, err := mine.GetMigrations()
+	if err != nil {
+		return 0, err
+	}
+	steps := migrationSteps()
+	var count uint32 = 0
+	for i, info := range list {
+		if info.Status == MigrationDone {
+			continue
+		}
+		step := steps[i]
+		if step.fixup && !config.Schema.Basic.Fixups {
+			continue
+		}
+		held, er := nosql.RefreshMigration(owner)
+		if er != nil {
+			return count, er
+		}
+		if !held {
+			return count, errors.New("the migrations lock is lost")
+		}
+		started := time.Now().Unix()
+		logger.Infof("run the migration that version = %d; name = %s", step.version, step.name)
+		er = step.handle()
+		if er != nil {
+			logger.Warnf("run the migration failed that version = %d; name = %s; err = %s", step.version, step.name, er.Error())
+			_ = nosql.UpdateMigration(step.version, step.name, owner, MigrationFailed, started, er.Error())
+			return count, er
+		}
+		err = nosql.UpdateMigration(step.version, step.name, owner, MigrationDone, started, "")
+		if err != nil {
+			return count, err
+		}
+		count += 1
+		logger.Infof("run the migration done that version = %d; name = %s; seconds = %d", step.version, step.name, time.Now().Unix()-started)
+	}
+	return count, nil
+}
+
+/**
+迁移执行期间定时刷新锁，直到done关闭
+*/
+func keepMigrationLock(owner string, done chan struct{}) {
+	ticker := time.NewTicker(migrationRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			held, err := nosql.RefreshMigration(owner)
+			if err != nil {
+				logger.Warn("refresh the migrations lock failed that " + err.Error())
+			} else if !held {
+				logger.Warn("the migrations lock is held by other instance")
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+	"time"
+)
+
+func TestRunMigrationsSkipFixups(t *testing.T) {
+	ctx := initTestContext(t)
+	config.Schema.Basic.Fixups = false
+	count, err := ctx.RunMigrations()
+	if err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	list, _ := ctx.GetMigrations()
+	steps := migrationSteps()
+	var want uint32 = 0
+	for i, step := range steps {
+		if step.fixup {
+			if list[i].Status != MigrationPending {
+				t.Errorf("the fixup %s should not run, status = %d", step.name, list[i].Status)
+			}
+			continue
+		}
+		want += 1
+		if list[i].Status != MigrationDone {
+			t.Errorf("the migration %s status = %d, want done", step.name, list[i].Status)
+		}
+	}
+	if count != want {
+		t.Errorf("run migrations count = %d, want %d", count, want)
+	}
+	if count, _ = ctx.RunMigrations(); count != 0 {
+		t.Errorf("run the done migrations again count = %d, want 0", count)
+	}
+}
+
+func TestRunMigrationsLock(t *testing.T) {
+	ctx := initTestContext(t)
+	// 执行完成后释放锁
+	if _, err := ctx.RunMigrations(); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	locked, err := nosql.LockMigration("other", migrationExpire)
+	if err != nil || !locked {
+		t.Fatalf("the lock is not released after run: %v", err)
+	}
+	initTestContext(t)
+	if locked, _ = nosql.LockMigration("other", migrationExpire); !locked {
+		t.Fatal("lock migration failed")
+	}
+	count, err := Context().RunMigrations()
+	if err != nil || count != 0 {
+		t.Errorf("run migrations with the lock of other = %d, %v; want 0", count, err)
+	}
+	if list, _ := Context().GetMigrations(); list[len(list)-1].Status != MigrationPending {
+		t.Error("the migration runs without the lock")
+	}
+}
+
+func TestKeepMigrationLock(t *testing.T) {
+	initTestContext(t)
+	old := migrationRefresh
+	migrationRefresh = 20 * time.Millisecond
+	defer func() { migrationRefresh = old }()
+	if locked, _ := nosql.LockMigration("owner", migrationExpire); !locked {
+		t.Fatal("lock migration failed")
+	}
+	before, _ := nosql.GetMigrationLock()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		keepMigrationLock("owner", done)
+		close(finished)
+	}()
+	time.Sleep(1100 * time.Millisecond)
+	close(done)
+	<-finished
+	after, err := nosql.GetMigrationLock()
+	if err != nil || after.Owner != "owner" || after.Locked <= before.Locked {
+		t.Errorf("the lock is not refreshed that before = %d; after = %+v; err = %v", before.Locked, after, err)
+	}
+}
+
+func TestCheckSequenceKeepUnknown(t *testing.T) {
+	initTestContext(t)
+	school := DefaultEntityTable + "_school"
+	for i := 0; i < 3; i += 1 {
+		nosql.GetEntityNextID(school)
+	}
+	if err := CheckSequence(); err != nil {
+		t.Fatalf("check sequence: %v", err)
+	}
+	if num := nosql.GetEntityNextID(school); num != 4 {
+		t.Errorf("the next id of %s = %d, want 4", school, num)
+	}
+}
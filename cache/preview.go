@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"errors"
+	"omo.msa.vocabulary/proxy"
+	"strings"
+)
+
+var examineLabels = map[string]string{
+	ExamineBaseName:     "名称",
+	ExamineBaseAvatar:   "封面",
+	ExamineBaseDesc:     "描述",
+	ExamineBaseSummary:  "简介",
+	ExamineBaseTags:     "标签",
+	ExamineBaseSynonyms: "同义词",
+}
+
+/**
+预览中的一个值，值关联实体时返回实体的名称
+*/
+type ExamineValue struct {
+	Value  string `json:"value"`
+	Entity string `json:"entity,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+/**
+修改建议的预览，对比实体当前的值和建议的值
+*/
+type ExaminePreview struct {
+	UID      string          `json:"uid"`
+	Type     uint8           `json:"type"`
+	Key      string          `json:"key"`
+	Label    string          `json:"label"` //字段名称或者属性名称
+	Target   string          `json:"target"`
+	Name     string          `json:"name"` //实体名称
+	Status   uint8           `json:"status"`
+	Conflict bool            `json:"conflict"` //提交后实体已经被修改
+	Before   []*ExamineValue `json:"before"`
+	After    []*ExamineValue `json:"after"`
+	Added    []*ExamineValue `json:"added"`
+	Removed  []*ExamineValue `json:"removed"`
+}
+
+func (mine *cacheContext) GetExaminePreview(uid string) (*ExaminePreview, error) {
+	info := mine.GetExamine(uid)
+	if info == nil {
+		return nil, errors.New("not found the examine by uid")
+	}
+	return info.Preview()
+}
+
+func (mine *ExamineInfo) Preview() (*ExaminePreview, error) {
+	data := mine.Data
+	tp := ExamineType(data.Kind)
+	preview := &ExaminePreview{UID: mine.UID, Type: data.Kind, Key: data.Key, Target: data.Target, Status: data.Status}
+	if tp == ExamineTypeEvent {
+		event := cacheCtx.GetEvent(data.Value)
+		if event == nil {
+			return nil, errors.New("not found the event of examine")
+		}
+		preview.Label = event.Name
+		preview.Before = make([]*ExamineValue, 0, 1)
+		preview.After = []*ExamineValue{eventValue(event.Name, event.Date, event.Place)}
+		if entity := cacheCtx.GetEntity(event.Entity); entity != nil {
+			preview.Name = entity.Name
+		}
+		preview.diff()
+		return preview, nil
+	}
+	entity := cacheCtx.GetEntity(data.Target)
+	if entity == nil {
+		return nil, errors.New("not found the entity of examine")
+	}
+	preview.Name = entity.Name
+	preview.Conflict = data.Status == ExamineStatusIdle && data.Base > 0 && entity.Updated != data.Base
+	preview.Label = examineLabels[data.Key]
+	var err error
+	switch tp {
+	case ExamineTypeBase:
+		err = preview.baseValues(entity, data.Key, data.Value)
+	case ExamineTypeAttribute, ExamineTypeProperty:
+		att := cacheCtx.GetAttributeByKey(data.Key)
+		if att == nil {
+			return nil, errors.New("not found the attribute of examine")
+		}
+		preview.Label = att.Name
+		var words []proxy.WordInfo
+		if prop := entity.GetProperty(att.UID); prop != nil {
+			words = prop.Words
+		}
+		preview.Before = wordValues(words)
+		if tp == ExamineTypeProperty {
+			words, err = decodeExamineWords(data.Value)
+		} else {
+			words = replaceFirstWord(words, data.Value)
+		}
+		preview.After = wordValues(words)
+	case ExamineTypeRelation:
+		preview.Label = "关系"
+		err = preview.relationValues(entity, data.Value)
+	case ExamineTypeStatic:
+		preview.Label = "静态事件"
+		preview.Before = make([]*ExamineValue, 0, len(entity.StaticEvents))
+		for _, item := range entity.StaticEvents {
+			preview.Before = append(preview.Before, eventValue(item.Name, item.Date, item.Place))
+		}
+		list, er := decodeExamineEvents(data.Value)
+		err = er
+		preview.After = make([]*ExamineValue, 0, len(list))
+		for _, item := range list {
+			preview.After = append(preview.After, eventValue(item.Name, item.Date, item.Place))
+		}
+	default:
+		err = errors.New("the examine type is not supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+	preview.diff()
+	return preview, nil
+}
+
+func (mine *ExaminePreview) baseValues(entity *EntityInfo, key, val string) error {
+	var before []string
+	var after []string
+	switch key {
+	case ExamineBaseName:
+		before = []string{entity.Name}
+	case ExamineBaseAvatar:
+		before = []string{entity.Cover}
+	case ExamineBaseDesc:
+		before = []string{entity.Description}
+	case ExamineBaseSummary:
+		before = []string{entity.Summary}
+	case ExamineBaseTags, ExamineBaseSynonyms:
+		list, err := decodeExamineStrings(val)
+		if err != nil {
+			return err
+		}
+		before = entity.Tags
+		if key == ExamineBaseSynonyms {
+			before = entity.Synonyms
+		}
+		after = list
+	default:
+		return errors.New(ErrorExamineKey)
+	}
+	if after == nil {
+		after = []string{val}
+	}
+	mine.Before = stringValues(before)
+	mine.After = stringValues(after)
+	return nil
+}
+
+/**
+没有UID的关系为新增，有UID的关系替换已有的关系
+*/
+func (mine *ExaminePreview) relationValues(entity *EntityInfo, val string) error {
+	list, err := decodeExamineRelations(val)
+	if err != nil {
+		return err
+	}
+	edges := cacheCtx.GetVEdgesByCenter(entity.UID)
+	mine.Before = make([]*ExamineValue, 0, len(edges))
+	mine.After = make([]*ExamineValue, 0, len(edges)+len(list))
+	for _, edge := range edges {
+		tmp := edgeValue(edge.Name, edge.Target.Name, edge.Target.Entity)
+		mine.Before = append(mine.Before, tmp)
+		replaced := false
+		for _, item := range list {
+			if item.Uid == edge.UID {
+				mine.After = append(mine.After, edgeValue(item.Name, item.Target.Name, item.Target.Entity))
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			mine.After = append(mine.After, tmp)
+		}
+	}
+	for _, item := range list {
+		if item.Uid == "" {
+			mine.After = append(mine.After, edgeValue(item.Name, item.Target.Name, item.Target.Entity))
+		}
+	}
+	return nil
+}
+
+func (mine *ExaminePreview) diff() {
+	mine.Added = subtractValues(mine.After, mine.Before)
+	mine.Removed = subtractValues(mine.Before, mine.After)
+}
+
+func subtractValues(from, other []*ExamineValue) []*ExamineValue {
+	list := make([]*ExamineValue, 0, len(from))
+	for _, item := range from {
+		had := false
+		for _, tmp := range other {
+			if tmp.Value == item.Value && tmp.Entity == item.Entity {
+				had = true
+				break
+			}
+		}
+		if !had {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func stringValues(arr []string) []*ExamineValue {
+	list := make([]*ExamineValue, 0, len(arr))
+	for _, item := range arr {
+		list = append(list, &ExamineValue{Value: item})
+	}
+	return list
+}
+
+func wordValues(words []proxy.WordInfo) []*ExamineValue {
+	list := make([]*ExamineValue, 0, len(words))
+	for _, word := range words {
+		list = append(list, &ExamineValue{Value: word.Name, Entity: word.UID, Name: entityName(word.UID)})
+	}
+	return list
+}
+
+/**
+与updateProperty一致，单个值的建议只替换第一个值
+*/
+func replaceFirstWord(words []proxy.WordInfo, val string) []proxy.WordInfo {
+	list := make([]proxy.WordInfo, 0, len(words)+1)
+	list = append(list, words...)
+	if len(list) > 0 {
+		list[0].Name = val
+	} else {
+		list = append(list, proxy.WordInfo{Name: val})
+	}
+	return list
+}
+
+func edgeValue(relation, target, entity string) *ExamineValue {
+	return &ExamineValue{Value: relation + ":" + target, Entity: entity, Name: entityName(entity)}
+}
+
+func eventValue(name string, date proxy.DateInfo, place proxy.PlaceInfo) *ExamineValue {
+	arr := make([]string, 0, 3)
+	for _, item := range []string{name, date.Name, place.Name} {
+		if item != "" {
+			arr = append(arr, item)
+		}
+	}
+	return &ExamineValue{Value: strings.Join(arr, " "), Entity: place.UID, Name: entityName(place.UID)}
+}
+
+func entityName(uid string) string {
+	if uid == "" {
+		return ""
+	}
+	entity := cacheCtx.GetEntity(uid)
+	if entity == nil {
+		return ""
+	}
+	return entity.Name
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"omo.msa.vocabulary/tool"
+	"sync/atomic"
+)
+
+/**
+图数据库与实体数据对齐的结果，DryRun为真时只统计差异不修改图数据库
+*/
+type ReconcileReport struct {
+	DryRun       bool
+	Nodes        uint32 //应该存在的节点数量
+	Links        uint32 //应该存在的关系数量
+	CreatedNodes uint32
+	UpdatedNodes uint32
+	RemovedNodes uint32
+	CreatedLinks uint32
+	UpdatedLinks uint32
+	RemovedLinks uint32
+	Errors       []string
+}
+
+type reconcileNode struct {
+	uid   string
+	name  string
+	label string
+	cover string
+}
+
+type reconcileLink struct {
+	from      string
+	to        string
+	name      string
+	kind      LinkType
+	relation  string
+	direction uint8
+	weight    uint32
+}
+
+func (mine *reconcileLink) key() string {
+	return mine.from + "|" + mine.to + "|" + mine.name
+}
+
+func (mine *ReconcileReport) Changes() uint32 {
+	return mine.CreatedNodes + mine.UpdatedNodes + mine.RemovedNodes + mine.CreatedLinks + mine.UpdatedLinks + mine.RemovedLinks
+}
+
+func (mine *ReconcileReport) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Warn("reconcile graph failed that " + msg)
+	mine.Errors = append(mine.Errors, msg)
+}
+
+/**
+以实体、关系边和事件关系为准，修复图数据库中的节点和关系，修改图数据库时与同步任务互斥
+*/
+func (mine *cacheContext) ReconcileGraph(dry bool) (*ReconcileReport, error) {
+	if !dry {
+		if !atomic.CompareAndSwapInt32(&mine.syncing, 0, 1) {
+			return nil, errors.New("the graph sync task is running")
+		}
+		defer atomic.StoreInt32(&mine.syncing, 0)
+	}
+	report := &ReconcileReport{DryRun: dry, Errors: make([]string, 0, 5)}
+	nodes, links, err := mine.expectedGraph()
+	if err != nil {
+		return nil, err
+	}
+	report.Nodes = uint32(len(nodes))
+	report.Links = uint32(len(links))
+	ids, err := mine.reconcileNodes(report, nodes)
+	if err != nil {
+		return nil, err
+	}
+	err = mine.reconcileLinks(report, ids, links)
+	if err != nil {
+		return nil, err
+	}
+	if !dry {
+		// 图数据库已经变化，清空缓存的节点和关系
+		mine.graph.construct()
+	}
+	logger.Infof("reconcile graph done that dry = %v; changes = %d; errors = %d", dry, report.Changes(), len(report.Errors))
+	return report, nil
+}
+
+func (mine *cacheContext) expectedGraph() (map[string]*reconcileNode, map[string]*reconcileLink, error) {
+	nodes := make(map[string]*reconcileNode, 1000)
+	for _, entity := range mine.AllEntities() {
+		var name = entity.Name
+		if entity.Add != "" {
+			name = entity.Name + "-" + entity.Add
+		}
+		nodes[entity.UID] = &reconcileNode{uid: entity.UID, name: name, label: switchEntityLabel(entity.Concept), cover: entity.Cover}
+	}
+	links := make(map[string]*reconcileLink, 1000)
+	relations := make(map[string]*RelationshipInfo, 20)
+	appendLink := func(from, to, name, category string, direction uint8, weight uint32) {
+		if from == to || nodes[from] == nil || nodes[to] == nil {
+			return
+		}
+		relation, ok := relations[category]
+		if !ok {
+			relation = mine.GetRelation(category)
+			relations[category] = relation
+		}
+		kind := LinkTypeEmpty
+		if relation != nil {
+			kind = switchRelationToLink(relation.Kind)
+		}
+		link := &reconcileLink{from: from, to: to, name: name, kind: kind, relation: category,
+			direction: direction, weight: weight}
+		links[link.key()] = link
+	}
+	edges, err := nosql.GetAllVEdges()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, edge := range edges {
+		if edge.Deleted > 0 {
+			continue
+		}
+		appendLink(edge.Source, edge.Target.Entity, edge.Name, edge.Catalog, edge.Direction, edge.Weight)
+	}
+	events, err := nosql.GetAllEvents()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, event := range events {
+		for _, item := range event.Relations {
+			appendLink(event.Entity, item.Entity, item.Name, item.Category, item.Direction, item.Weight)
+		}
+	}
+	return nodes, links, nil
+}
+
+/**
+对齐节点，返回实体UID与图节点ID的对应关系
+*/
+func (mine *cacheContext) reconcileNodes(report *ReconcileReport, expected map[string]*reconcileNode) (map[string]int64, error) {
+	actual, err := proxy.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int64, len(actual))
+	for _, node := range actual {
+		want := expected[node.UID]
+		_, had := ids[node.UID]
+		if want == nil || had {
+			// 实体已经不存在或者重复的节点
+			report.RemovedNodes += 1
+			if !report.DryRun {
+				if er := node.Delete(); er != nil {
+					report.fail("remove node %d; %s", node.ID, er.Error())
+				}
+			}
+			continue
+		}
+		ids[node.UID] = node.ID
+		if node.Name == want.name && node.Cover == want.cover && tool.HasItem(node.Labels, want.label) {
+			continue
+		}
+		report.UpdatedNodes += 1
+		if report.DryRun {
+			continue
+		}
+		if node.Name != want.name || node.Cover != want.cover {
+			if _, er := node.Update(want.name, want.cover); er != nil {
+				report.fail("update node %s; %s", node.UID, er.Error())
+				continue
+			}
+		}
+		if !tool.HasItem(node.Labels, want.label) {
+			if _, er := node.AddLabel(want.label); er != nil {
+				report.fail("label node %s; %s", node.UID, er.Error())
+			}
+		}
+	}
+	for uid, want := range expected {
+		if _, had := ids[uid]; had {
+			continue
+		}
+		report.CreatedNodes += 1
+		if report.DryRun {
+			continue
+		}
+		node, er := proxy.CreateNode(want.name, want.label, want.uid)
+		if er != nil || node == nil {
+			report.fail("create node %s; %v", uid, er)
+			continue
+		}
+		if len(want.cover) > 0 {
+			_, _ = node.Update(want.name, want.cover)
+		}
+		ids[uid] = node.ID
+	}
+	return ids, nil
+}
+
+func (mine *cacheContext) reconcileLinks(report *ReconcileReport, ids map[string]int64, expected map[string]*reconcileLink) error {
+	actual, err := proxy.GetAllLinks()
+	if err != nil {
+		return err
+	}
+	uids := make(map[int64]string, len(ids))
+	for uid, id := range ids {
+		uids[id] = uid
+	}
+	done := make(map[string]bool, len(actual))
+	changed := make(map[string]bool, 10)
+	for _, link := range actual {
+		tmp := &reconcileLink{from: uids[link.From], to: uids[link.To], name: link.Name}
+		want := expected[tmp.key()]
+		if want != nil && !done[tmp.key()] && want.relation == link.Relation && want.direction == link.Direction &&
+			string(want.kind) == link.Label && want.weight == link.Weight {
+			done[tmp.key()] = true
+			continue
+		}
+		if want != nil && !done[tmp.key()] {
+			// 关系属性发生了变化，删除后重新创建
+			changed[tmp.key()] = true
+		} else {
+			report.RemovedLinks += 1
+		}
+		if !report.DryRun {
+			if er := proxy.RemoveLink(link.ID); er != nil {
+				report.fail("remove link %d; %s", link.ID, er.Error())
+			}
+		}
+	}
+	for key, want := range expected {
+		if done[key] {
+			continue
+		}
+		if changed[key] {
+			report.UpdatedLinks += 1
+		} else {
+			report.CreatedLinks += 1
+		}
+		if report.DryRun {
+			continue
+		}
+		from, ok1 := ids[want.from]
+		to, ok2 := ids[want.to]
+		if !ok1 || !ok2 {
+			report.fail("create link %s; the node not found", key)
+			continue
+		}
+		_, er := proxy.CreateLink(from, to, string(want.kind), want.name, want.relation, want.direction, want.weight)
+		if er != nil {
+			report.fail("create link %s; %s", key, er.Error())
+		}
+	}
+	return nil
+}
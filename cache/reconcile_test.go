@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestReconcileGraphWithSyncing(t *testing.T) {
+	ctx := initTestContext(t)
+	atomic.StoreInt32(&ctx.syncing, 1)
+	defer atomic.StoreInt32(&ctx.syncing, 0)
+	if _, err := ctx.ReconcileGraph(false); err == nil {
+		t.Fatal("reconcile graph should fail when the sync task is running")
+	}
+	report, err := ctx.ReconcileGraph(true)
+	if err != nil {
+		t.Fatalf("dry reconcile graph: %v", err)
+	}
+	if !report.DryRun {
+		t.Error("the report should be dry run")
+	}
+}
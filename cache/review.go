@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"errors"
+	"github.com/micro/go-micro/v2/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ReviewKindEntity  = 1 //待审核的实体
+	ReviewKindExamine = 2 //待审核的修改建议
+)
+
+const (
+	ReviewStatusWaiting = 0 //等待认领，没有审核人时在公共队列中
+	ReviewStatusClaimed = 1 //审核人已经认领
+	ReviewStatusDone    = 2 //已经审核
+)
+
+const (
+	ReviewStrategyRound = "round"
+	ReviewStrategyLoad  = "load"
+)
+
+const (
+	ErrorReviewClaimed  = "the review had been claimed by other reviewer"
+	ErrorReviewReleased = "the review is not claimed by the reviewer"
+	ErrorReviewReviewer = "the operator is not the reviewer of box"
+)
+
+/**
+审核任务，Age为创建到现在(或者完成时)的秒数
+*/
+type ReviewInfo struct {
+	UID      string `json:"uid"`
+	Kind     uint8  `json:"kind"`
+	Target   string `json:"target"`
+	Entity   string `json:"entity"`
+	Box      string `json:"box"`
+	Reviewer string `json:"reviewer"`
+	Status   uint8  `json:"status"`
+	Created  int64  `json:"created"`
+	Assigned int64  `json:"assigned"`
+	Claimed  int64  `json:"claimed"`
+	Finished int64  `json:"finished"`
+	Overdue  bool   `json:"overdue"`
+	Age      int64  `json:"age"`
+}
+
+/**
+同步审核队列的结果
+*/
+type ReviewSyncResult struct {
+	Created  uint32 `json:"created"`
+	Assigned uint32 `json:"assigned"`
+	Finished uint32 `json:"finished"`
+	Overdue  uint32 `json:"overdue"`
+}
+
+/**
+审核人的待办统计
+*/
+type ReviewerLoad struct {
+	Reviewer string `json:"reviewer"`
+	Waiting  uint32 `json:"waiting"`
+	Claimed  uint32 `json:"claimed"`
+	Overdue  uint32 `json:"overdue"`
+	Done     uint32 `json:"done"`
+	Oldest   int64  `json:"oldest"` //最早的待办的秒数
+}
+
+type ReviewerDashboard struct {
+	ReviewerLoad
+	Items []*ReviewInfo `json:"items"`
+}
+
+type BoxReviewDashboard struct {
+	Box        string          `json:"box"`
+	Name       string          `json:"name"`
+	Unassigned uint32          `json:"unassigned"`
+	Waiting    uint32          `json:"waiting"`
+	Claimed    uint32          `json:"claimed"`
+	Overdue    uint32          `json:"overdue"`
+	Done       uint32          `json:"done"`
+	Oldest     int64           `json:"oldest"`
+	Reviewers  []*ReviewerLoad `json:"reviewers"`
+	Items      []*ReviewInfo   `json:"items"`
+}
+
+type reviewState struct {
+	running int32
+	lock    sync.Mutex
+	rounds  map[string]int //收藏夹轮流分配的位置
+}
+
+/**
+待审核的数据
+*/
+type reviewItem struct {
+	kind   uint8
+	target string
+	entity string
+	box    *BoxInfo
+}
+
+func (mine *ReviewInfo) initInfo(db *nosql.Review) {
+	mine.UID = db.UID.Hex()
+	mine.Kind = db.Kind
+	mine.Target = db.Target
+	mine.Entity = db.Entity
+	mine.Box = db.Box
+	mine.Reviewer = db.Reviewer
+	mine.Status = db.Status
+	mine.Created = db.Created
+	mine.Assigned = db.Assigned
+	mine.Claimed = db.Claimed
+	mine.Finished = db.Finished
+	mine.Overdue = db.Overdue
+	if db.Finished > 0 {
+		mine.Age = db.Finished - db.Created
+	} else {
+		mine.Age = time.Now().Unix() - db.Created
+	}
+}
+
+func reviewKey(kind uint8, target string) string {
+	if kind == ReviewKindExamine {
+		return "examine-" + target
+	}
+	return "entity-" + target
+}
+
+func (mine *cacheContext) GetReview(uid string) *ReviewInfo {
+	if uid == "" {
+		return nil
+	}
+	db, err := nosql.GetReview(uid)
+	if err != nil {
+		return nil
+	}
+	info := new(ReviewInfo)
+	info.initInfo(db)
+	return info
+}
+
+/**
+收集收藏夹中待审核的实体以及修改建议，只有设置了审核人的收藏夹参与分配
+*/
+func (mine *cacheContext) getReviewItems() (map[string]*reviewItem, error) {
+	items := make(map[string]*reviewItem, 100)
+	boxes := make(map[string]*BoxInfo, 100)
+	for _, box := range mine.GetAllBoxes() {
+		if len(box.Reviewers) < 1 {
+			continue
+		}
+		for _, content := range box.Contents {
+			if _, ok := boxes[content.Keyword]; !ok {
+				boxes[content.Keyword] = box
+			}
+			if EntityStatus(content.Status) != EntityStatusPending {
+				continue
+			}
+			key := reviewKey(ReviewKindEntity, content.Keyword)
+			if _, ok := items[key]; !ok {
+				items[key] = &reviewItem{kind: ReviewKindEntity, target: content.Keyword, entity: content.Keyword, box: box}
+			}
+		}
+	}
+	dbs, err := nosql.GetExaminesByState(ExamineStatusIdle)
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		box, ok := boxes[db.Target]
+		if !ok {
+			continue
+		}
+		uid := db.UID.Hex()
+		items[reviewKey(ReviewKindExamine, uid)] = &reviewItem{kind: ReviewKindExamine, target: uid, entity: db.Target, box: box}
+	}
+	return items, nil
+}
+
+/**
+按照配置的方式从收藏夹的审核人中选择一个，exclude为不参与分配的审核人
+*/
+func (mine *cacheContext) pickReviewer(box *BoxInfo, loads map[string]uint32, exclude string) string {
+	list := make([]string, 0, len(box.Reviewers))
+	for _, item := range box.Reviewers {
+		if item != exclude {
+			list = append(list, item)
+		}
+	}
+	if len(list) < 1 {
+		return ""
+	}
+	if config.Schema.Review.Strategy == ReviewStrategyLoad {
+		picked := list[0]
+		for _, item := range list[1:] {
+			if loads[item] < loads[picked] {
+				picked = item
+			}
+		}
+		return picked
+	}
+	mine.review.lock.Lock()
+	defer mine.review.lock.Unlock()
+	if mine.review.rounds == nil {
+		mine.review.rounds = make(map[string]int, 10)
+	}
+	index := mine.review.rounds[box.UID] % len(list)
+	mine.review.rounds[box.UID] = index + 1
+	return list[index]
+}
+
+/**
+同步审核队列：为新的待审核数据创建任务并分配审核人，完成已经审核的任务，标记超过期限的任务，
+审核人被移除时重新分配任务，已经认领的任务也回到等待认领的状态
+*/
+func (mine *cacheContext) SyncReviews() (*ReviewSyncResult, error) {
+	if !atomic.CompareAndSwapInt32(&mine.review.running, 0, 1) {
+		return nil, errors.New("the review sync is running")
+	}
+	defer atomic.StoreInt32(&mine.review.running, 0)
+	items, err := mine.getReviewItems()
+	if err != nil {
+		return nil, err
+	}
+	dbs, err := nosql.GetOpenReviews(ReviewStatusDone)
+	if err != nil {
+		return nil, err
+	}
+	result := new(ReviewSyncResult)
+	loads := make(map[string]uint32, 10)
+	opens := make(map[string]*nosql.Review, len(dbs))
+	for _, db := range dbs {
+		key := reviewKey(db.Kind, db.Target)
+		if _, ok := items[key]; !ok {
+			if er := nosql.UpdateReviewFinish(db.UID.Hex(), ReviewStatusDone); er == nil {
+				result.Finished += 1
+			}
+			continue
+		}
+		opens[key] = db
+		if db.Reviewer != "" {
+			loads[db.Reviewer] += 1
+		}
+	}
+	now := time.Now().Unix()
+	sla := config.Schema.Review.SLA
+	for key, item := range items {
+		db, ok := opens[key]
+		if !ok {
+			db = &nosql.Review{UID: primitive.NewObjectID(), Created: now, Kind: item.kind, Target: item.target,
+				Entity: item.entity, Box: item.box.UID, Status: ReviewStatusWaiting}
+			if er := nosql.CreateReview(db); er != nil {
+				logger.Warnf("create the review failed that target = %s; err = %s", item.target, er.Error())
+				continue
+			}
+			result.Created += 1
+		}
+		removed := db.Reviewer != "" && !item.box.HadReviewer(db.Reviewer)
+		if (db.Status == ReviewStatusWaiting && db.Reviewer == "") || removed {
+			if removed && loads[db.Reviewer] > 0 {
+				loads[db.Reviewer] -= 1
+			}
+			// 没有可以分配的审核人时，被移除的审核人的任务回到公共队列
+			reviewer := mine.pickReviewer(item.box, loads, "")
+			if reviewer != "" || removed {
+				ok, er := nosql.UpdateReviewAssign(db.UID.Hex(), db.Reviewer, reviewer, db.Status, ReviewStatusWaiting)
+				if ok && er == nil && reviewer != "" {
+					loads[reviewer] += 1
+					result.Assigned += 1
+				}
+			}
+		}
+		if sla > 0 && !db.Overdue && now-db.Created > sla {
+			if nosql.UpdateReviewOverdue(db.UID.Hex()) == nil {
+				result.Overdue += 1
+				logger.Warnf("the review is overdue that target = %s; box = %s; reviewer = %s; age = %d",
+					db.Target, db.Box, db.Reviewer, now-db.Created)
+			}
+		}
+	}
+	logger.Infof("sync the reviews done that created = %d; assigned = %d; finished = %d; overdue = %d",
+		result.Created, result.Assigned, result.Finished, result.Overdue)
+	return result, nil
+}
+
+/**
+数据审核后立即完成对应的任务，不需要等待下次同步
+*/
+func (mine *cacheContext) finishReview(kind uint8, target string) {
+	db, _ := nosql.GetOpenReviewBy(kind, target, ReviewStatusDone)
+	if db != nil {
+		_ = nosql.UpdateReviewFinish(db.UID.Hex(), ReviewStatusDone)
+	}
+}
+
+/**
+审核人认领任务，已经分配给其他人的任务不能认领
+*/
+func (mine *ReviewInfo) Claim(reviewer string) error {
+	box := cacheCtx.GetBox(mine.Box)
+	if box == nil || !box.HadReviewer(reviewer) {
+		return errors.New(ErrorReviewReviewer)
+	}
+	ok, err := nosql.UpdateReviewClaim(mine.UID, reviewer, ReviewStatusWaiting, ReviewStatusClaimed)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(ErrorReviewClaimed)
+	}
+	mine.Reviewer = reviewer
+	mine.Status = ReviewStatusClaimed
+	mine.Claimed = time.Now().Unix()
+	return nil
+}
+
+/**
+审核人放弃认领的任务，任务重新分配给其他审核人
+*/
+func (mine *ReviewInfo) Release(reviewer string) error {
+	ok, err := nosql.UpdateReviewRelease(mine.UID, reviewer, ReviewStatusClaimed, ReviewStatusWaiting)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(ErrorReviewReleased)
+	}
+	mine.Reviewer = ""
+	mine.Status = ReviewStatusWaiting
+	mine.Claimed = 0
+	box := cacheCtx.GetBox(mine.Box)
+	if box == nil {
+		return nil
+	}
+	loads := make(map[string]uint32, len(box.Reviewers))
+	for _, item := range box.Reviewers {
+		loads[item] = nosql.GetReviewCount("", item, ReviewStatusWaiting) + nosql.GetReviewCount("", item, ReviewStatusClaimed)
+	}
+	next := cacheCtx.pickReviewer(box, loads, reviewer)
+	if next == "" {
+		return nil
+	}
+	// 释放后其他审核人可能已经认领
+	ok, err = nosql.UpdateReviewAssign(mine.UID, "", next, ReviewStatusWaiting, ReviewStatusWaiting)
+	if ok && err == nil {
+		mine.Reviewer = next
+	}
+	return err
+}
+
+func switchReviews(dbs []*nosql.Review) []*ReviewInfo {
+	list := make([]*ReviewInfo, 0, len(dbs))
+	for _, db := range dbs {
+		info := new(ReviewInfo)
+		info.initInfo(db)
+		list = append(list, info)
+	}
+	return list
+}
+
+func (mine *ReviewerLoad) add(info *ReviewInfo) {
+	if info.Status == ReviewStatusClaimed {
+		mine.Claimed += 1
+	} else {
+		mine.Waiting += 1
+	}
+	if info.Overdue {
+		mine.Overdue += 1
+	}
+	if info.Age > mine.Oldest {
+		mine.Oldest = info.Age
+	}
+}
+
+/**
+审核人的待办任务，按照创建时间排序
+*/
+func (mine *cacheContext) GetReviewerDashboard(reviewer string) (*ReviewerDashboard, error) {
+	dbs, err := nosql.GetOpenReviewsByReviewer(reviewer, ReviewStatusDone)
+	if err != nil {
+		return nil, err
+	}
+	info := &ReviewerDashboard{Items: switchReviews(dbs)}
+	info.Reviewer = reviewer
+	for _, item := range info.Items {
+		info.add(item)
+	}
+	info.Done = nosql.GetReviewCount("", reviewer, ReviewStatusDone)
+	return info, nil
+}
+
+/**
+收藏夹的审核进度以及每个审核人的待办数量
+*/
+func (mine *cacheContext) GetBoxReviewDashboard(uid string) (*BoxReviewDashboard, error) {
+	box := mine.GetBox(uid)
+	if box == nil {
+		return nil, errors.New("not found the box by uid")
+	}
+	dbs, err := nosql.GetOpenReviewsByBox(uid, ReviewStatusDone)
+	if err != nil {
+		return nil, err
+	}
+	info := &BoxReviewDashboard{Box: uid, Name: box.Name, Items: switchReviews(dbs)}
+	info.Reviewers = make([]*ReviewerLoad, 0, len(box.Reviewers))
+	loads := make(map[string]*ReviewerLoad, len(box.Reviewers))
+	for _, reviewer := range box.Reviewers {
+		load := &ReviewerLoad{Reviewer: reviewer, Done: nosql.GetReviewCount(uid, reviewer, ReviewStatusDone)}
+		loads[reviewer] = load
+		info.Reviewers = append(info.Reviewers, load)
+	}
+	for _, item := range info.Items {
+		if item.Reviewer == "" {
+			info.Unassigned += 1
+		} else if load, ok := loads[item.Reviewer]; ok {
+			load.add(item)
+		}
+		if item.Status == ReviewStatusClaimed {
+			info.Claimed += 1
+		} else {
+			info.Waiting += 1
+		}
+		if item.Overdue {
+			info.Overdue += 1
+		}
+		if item.Age > info.Oldest {
+			info.Oldest = item.Age
+		}
+	}
+	info.Done = nosql.GetReviewCount(uid, "", ReviewStatusDone)
+	return info, nil
+}
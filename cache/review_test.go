@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+)
+
+func TestSyncReviewsReassign(t *testing.T) {
+	ctx := initTestContext(t)
+	config.Schema.Review.Strategy = ReviewStrategyLoad
+	entity := newTestEntity(t, "a")
+	box := &BoxInfo{Contents: []*proxy.ContentInfo{{Keyword: entity.UID, Status: uint8(EntityStatusPending)}}}
+	box.Name = "box"
+	box.Creator = "tester"
+	if err := ctx.CreateBox(box); err != nil {
+		t.Fatalf("create box: %v", err)
+	}
+	if err := box.AppendUsers([]string{"r1", "r2"}, "tester", true); err != nil {
+		t.Fatalf("append reviewers: %v", err)
+	}
+	result, err := ctx.SyncReviews()
+	if err != nil {
+		t.Fatalf("sync reviews: %v", err)
+	}
+	if result.Created != 1 || result.Assigned != 1 {
+		t.Fatalf("the first sync created = %d; assigned = %d, want 1 and 1", result.Created, result.Assigned)
+	}
+	db, _ := nosql.GetOpenReviewBy(ReviewKindEntity, entity.UID, ReviewStatusDone)
+	if db == nil || db.Reviewer != "r1" {
+		t.Fatalf("the review should be assigned to r1, got %v", db)
+	}
+	info := ctx.GetReview(db.UID.Hex())
+	if err = info.Claim("r1"); err != nil {
+		t.Fatalf("claim review: %v", err)
+	}
+
+	// 认领的审核人被移除后，任务回到等待认领的状态并分配给其他审核人
+	if err = box.RemoveUsers([]string{"r1"}, "tester", true); err != nil {
+		t.Fatalf("remove reviewer: %v", err)
+	}
+	result, err = ctx.SyncReviews()
+	if err != nil {
+		t.Fatalf("sync reviews: %v", err)
+	}
+	if result.Created != 0 || result.Assigned != 1 {
+		t.Errorf("the second sync created = %d; assigned = %d, want 0 and 1", result.Created, result.Assigned)
+	}
+	info = ctx.GetReview(db.UID.Hex())
+	if info.Reviewer != "r2" || info.Status != ReviewStatusWaiting {
+		t.Errorf("the review reviewer = %s; status = %d, want r2 and waiting", info.Reviewer, info.Status)
+	}
+	if result, _ = ctx.SyncReviews(); result.Assigned != 0 {
+		t.Errorf("sync again assigned = %d, want 0", result.Assigned)
+	}
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sort"
+	"time"
+)
+
+const (
+	RevisionCreate   = "create"
+	RevisionBaseline = "baseline" //首次修改之前的数据
+	RevisionRestore  = "restore"
+)
+
+const revisionEdges = "edges" //以实体为中心的关系
+
+const revisionRetries = 5 //版本号冲突时的重试次数
+
+/**
+不参与比较的字段，以及恢复时保持不变的字段
+*/
+var revisionIgnores = []string{"UID", "id", "createdAt", "updatedAt", "deleteAt", "created", "updated", "deleted",
+	"creator", "operator", "pushed", "scene", "letters"}
+var revisionKeeps = []string{"status"}
+
+/**
+某一时刻实体的完整数据
+*/
+type entityRevision struct {
+	snapshot  *nosql.Entity
+	relations []*nosql.VEdge
+}
+
+/**
+比较时使用的关系数据，忽略时间和操作者
+*/
+type revisionEdge struct {
+	UID       string      `json:"uid"`
+	Name      string      `json:"name"`
+	Relation  string      `json:"relation"`
+	Remark    string      `json:"remark"`
+	Direction uint8       `json:"direction"`
+	Weight    uint32      `json:"weight"`
+	Type      uint32      `json:"type"`
+	Source    string      `json:"source"`
+	Target    proxy.VNode `json:"target"`
+}
+
+func loadRevisionState(table, uid string) *entityRevision {
+	db, err := nosql.GetEntity(table, uid)
+	if err != nil {
+		return nil
+	}
+	edges, _ := nosql.GetVEdgesByCenter(uid)
+	return &entityRevision{snapshot: db, relations: edges}
+}
+
+func (mine *entityRevision) fields() map[string]string {
+	if mine == nil || mine.snapshot == nil {
+		return make(map[string]string, 1)
+	}
+	bts, _ := json.Marshal(mine.snapshot)
+	tmp := jsonFields(bts, revisionIgnores)
+	edges := make([]*revisionEdge, 0, len(mine.relations))
+	for _, item := range mine.relations {
+		edges = append(edges, &revisionEdge{UID: item.UID.Hex(), Name: item.Name, Relation: item.Catalog, Remark: item.Remark,
+			Direction: item.Direction, Weight: item.Weight, Type: item.Type, Source: item.Source, Target: item.Target})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].UID < edges[j].UID
+	})
+	bts, _ = json.Marshal(edges)
+	tmp[revisionEdges] = string(bts)
+	return tmp
+}
+
+/**
+JSON对象的第一层字段，值为JSON格式
+*/
+func jsonFields(bts []byte, ignores []string) map[string]string {
+	tmp := make(map[string]string, 30)
+	values := make(map[string]json.RawMessage, 30)
+	_ = json.Unmarshal(bts, &values)
+	for key, value := range values {
+		if hadItem(ignores, key) {
+			continue
+		}
+		if string(value) == "null" {
+			// 数据库中的空数组可能为null
+			value = json.RawMessage("[]")
+		}
+		tmp[key] = string(value)
+	}
+	return tmp
+}
+
+func diffRevision(from, to *entityRevision) []*nosql.RevisionField {
+	return diffFields(from.fields(), to.fields())
+}
+
+/**
+比较两组字段，按照字段名排序
+*/
+func diffFields(before, after map[string]string) []*nosql.RevisionField {
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	list := make([]*nosql.RevisionField, 0, 5)
+	for _, key := range keys {
+		if before[key] != after[key] {
+			list = append(list, &nosql.RevisionField{Field: key, Before: before[key], After: after[key]})
+		}
+	}
+	return list
+}
+
+/**
+开始一次修改，返回修改前的数据，修改前的数据由调用方传给commitRevision，
+一次修改中的其他步骤使用不记录修订的方法（saveXXX、setXXX），只有最外层记录修订
+*/
+func (mine *EntityInfo) beginRevision() *entityRevision {
+	if len(mine.UID) < 1 {
+		return nil
+	}
+	return loadRevisionState(mine.table(), mine.UID)
+}
+
+/**
+结束修改，数据发生变化时写入修订记录，需要通过defer调用
+*/
+func (mine *EntityInfo) commitRevision(begin *entityRevision, action, operator string) {
+	if begin == nil {
+		return
+	}
+	after := loadRevisionState(mine.table(), mine.UID)
+	if after == nil {
+		return
+	}
+	fields := diffRevision(begin, after)
+	if len(fields) < 1 {
+		return
+	}
+	err := cacheCtx.createRevision(mine.UID, action, "", operator, begin, after, fields)
+	if err != nil {
+		logger.Warnf("create the revision failed that entity = %s; action = %s; err = %s", mine.UID, action, err.Error())
+	}
+}
+
+/**
+版本号由实体和版本的唯一索引保证不重复，同时修改时版本号冲突则重新读取最新的版本号
+*/
+func (mine *cacheContext) createRevision(entity, action, remark, operator string, before, after *entityRevision, fields []*nosql.RevisionField) error {
+	var err error
+	for i := 0; i < revisionRetries; i += 1 {
+		version := nosql.GetRevisionLastVersion(entity)
+		if version < 1 && action != RevisionCreate && before != nil {
+			// 没有修订记录的实体先记录修改前的数据，保证可以恢复到修改之前
+			err = mine.insertRevision(entity, 1, RevisionBaseline, "", operator, before, nil)
+			if nosql.IsDuplicateKey(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			version = 1
+		}
+		err = mine.insertRevision(entity, version+1, action, remark, operator, after, fields)
+		if !nosql.IsDuplicateKey(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (mine *cacheContext) insertRevision(entity string, version uint32, action, remark, operator string, state *entityRevision, fields []*nosql.RevisionField) error {
+	db := new(nosql.Revision)
+	db.UID = primitive.NewObjectID()
+	db.ID = nosql.GetRevisionNextID()
+	db.CreatedTime = time.Now()
+	db.Created = time.Now().Unix()
+	db.Creator = operator
+	db.Operator = operator
+	db.Entity = entity
+	db.Version = version
+	db.Action = action
+	db.Remark = remark
+	db.Fields = fields
+	if db.Fields == nil {
+		db.Fields = make([]*nosql.RevisionField, 0, 1)
+	}
+	db.Snapshot = state.snapshot
+	db.Relations = state.relations
+	if db.Relations == nil {
+		db.Relations = make([]*nosql.VEdge, 0, 1)
+	}
+	return nosql.CreateRevision(db)
+}
+
+/**
+按照版本从新到旧返回实体的修订记录，num为0时返回全部
+*/
+func (mine *cacheContext) GetRevisions(entity string, num int64) []*nosql.Revision {
+	list, err := nosql.GetRevisionsByEntity(entity, num)
+	if err != nil {
+		return make([]*nosql.Revision, 0, 1)
+	}
+	return list
+}
+
+func (mine *cacheContext) GetRevision(entity string, version uint32) *nosql.Revision {
+	db, err := nosql.GetRevisionByVersion(entity, version)
+	if err != nil {
+		return nil
+	}
+	return db
+}
+
+/**
+比较实体的两个修订版本，Before为from版本的值，After为to版本的值
+*/
+func (mine *cacheContext) DiffRevisions(entity string, from, to uint32) ([]*nosql.RevisionField, error) {
+	a := mine.GetRevision(entity, from)
+	if a == nil {
+		return nil, fmt.Errorf("not found the revision of version %d", from)
+	}
+	b := mine.GetRevision(entity, to)
+	if b == nil {
+		return nil, fmt.Errorf("not found the revision of version %d", to)
+	}
+	return diffRevision(&entityRevision{snapshot: a.Snapshot, relations: a.Relations},
+		&entityRevision{snapshot: b.Snapshot, relations: b.Relations}), nil
+}
+
+/**
+将实体恢复到指定的修订版本，状态保持不变，恢复本身也会记录为新的修订
+*/
+func (mine *EntityInfo) RestoreRevision(version uint32, operator string) error {
+	if mine.Status != EntityStatusDraft {
+		return errors.New("the entity is not draft so can not update")
+	}
+	target := cacheCtx.GetRevision(mine.UID, version)
+	if target == nil || target.Snapshot == nil {
+		return fmt.Errorf("not found the revision of version %d", version)
+	}
+	before := loadRevisionState(mine.table(), mine.UID)
+	if before == nil {
+		return errors.New("not found the entity when restore")
+	}
+	state := &entityRevision{snapshot: target.Snapshot, relations: target.Relations}
+	bts, err := bson.Marshal(target.Snapshot)
+	if err != nil {
+		return err
+	}
+	doc := bson.M{}
+	err = bson.Unmarshal(bts, &doc)
+	if err != nil {
+		return err
+	}
+	values := bson.M{}
+	restoreEdges := false
+	for _, field := range diffRevision(before, state) {
+		if field.Field == revisionEdges {
+			restoreEdges = true
+		} else if !hadItem(revisionKeeps, field.Field) {
+			values[field.Field] = doc[field.Field]
+		}
+	}
+	if name, ok := values["name"]; ok {
+		values["letters"] = firstLetter(fmt.Sprint(name))
+	}
+	if len(values) > 0 {
+		err = nosql.UpdateEntityFields(mine.table(), mine.UID, operator, values)
+		if err != nil {
+			return err
+		}
+	}
+	if restoreEdges {
+		err = mine.restoreRevisionEdges(before.relations, target.Relations, operator)
+		if err != nil {
+			return err
+		}
+	}
+	after := loadRevisionState(mine.table(), mine.UID)
+	if after == nil {
+		return errors.New("not found the entity after restore")
+	}
+	mine.initInfo(after.snapshot)
+	cacheCtx.addSyncUpdateNode(mine.UID)
+	fields := diffRevision(before, after)
+	return cacheCtx.createRevision(mine.UID, RevisionRestore, fmt.Sprintf("restore to version %d", version), operator, before, after, fields)
+}
+
+/**
+恢复以实体为中心的关系：修订中的关系恢复原值，修订之后新增的关系删除
+*/
+func (mine *EntityInfo) restoreRevisionEdges(current, target []*nosql.VEdge, operator string) error {
+	keeps := make(map[string]bool, len(target))
+	for _, item := range target {
+		keeps[item.UID.Hex()] = true
+		_, err := nosql.GetVEdge(item.UID.Hex())
+		if err != nil {
+			tmp := *item
+			tmp.Operator = operator
+			tmp.Deleted = 0
+			err = nosql.CreateVEdge(&tmp)
+		} else {
+			err = nosql.RestoreVEdge(item, operator)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range current {
+		if !keeps[item.UID.Hex()] {
+			err := cacheCtx.RemoveVEdge(item.UID.Hex(), operator)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"testing"
+)
+
+func newTestRelation(target string) []*pb.VEdgeInfo {
+	return []*pb.VEdgeInfo{{Name: "friend", Category: "r1", Direction: uint32(DirectionTypeFromTo), Target: &pb.VNode{Name: target}}}
+}
+
+func TestRevisionCreate(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	list := Context().GetRevisions(entity.UID, 0)
+	if len(list) != 1 {
+		t.Fatalf("the revisions = %d, want 1", len(list))
+	}
+	if list[0].Version != 1 || list[0].Action != RevisionCreate || list[0].Snapshot == nil || list[0].Snapshot.Name != "a" {
+		t.Errorf("the create revision = %+v", list[0])
+	}
+}
+
+func TestRevisionDiff(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	if err := entity.UpdateRemark("desc", "sum", "tester"); err != nil {
+		t.Fatalf("update remark: %v", err)
+	}
+	if err := entity.UpdateName("b", "tester"); err != nil {
+		t.Fatalf("update name: %v", err)
+	}
+	list := Context().GetRevisions(entity.UID, 0)
+	if len(list) != 3 || list[0].Action != "name" || list[1].Action != "remark" {
+		t.Fatalf("the revisions = %d, want create, remark and name", len(list))
+	}
+	if len(list[0].Fields) != 1 || list[0].Fields[0].Field != "name" {
+		t.Errorf("the fields of name revision = %+v", list[0].Fields)
+	}
+	fields, err := Context().DiffRevisions(entity.UID, 1, 3)
+	if err != nil {
+		t.Fatalf("diff revisions: %v", err)
+	}
+	keys := make([]string, 0, len(fields))
+	for _, item := range fields {
+		keys = append(keys, item.Field)
+	}
+	if len(keys) != 3 || keys[0] != "desc" || keys[1] != "name" || keys[2] != "summary" {
+		t.Errorf("the diff fields = %v, want [desc name summary]", keys)
+	}
+	if _, err = Context().DiffRevisions(entity.UID, 1, 9); err == nil {
+		t.Error("diff with a missing version should fail")
+	}
+}
+
+func TestRevisionNested(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	info := &EntityInfo{Cover: "cover", Summary: "sum"}
+	info.Name = "b"
+	info.Operator = "tester"
+	if err := entity.UpdateStatic(info, newTestRelation("t1")); err != nil {
+		t.Fatalf("update static: %v", err)
+	}
+	list := Context().GetRevisions(entity.UID, 0)
+	if len(list) != 2 || list[0].Action != "static" {
+		t.Fatalf("the revisions = %d, want only one static revision after create", len(list))
+	}
+	if len(list[0].Relations) != 1 {
+		t.Errorf("the relations of static revision = %d, want 1", len(list[0].Relations))
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	if err := entity.UpdateName("b", "tester"); err != nil {
+		t.Fatalf("update name: %v", err)
+	}
+	if err := entity.RestoreRevision(1, "restorer"); err != nil {
+		t.Fatalf("restore revision: %v", err)
+	}
+	if entity.Name != "a" || entity.Status != EntityStatusDraft {
+		t.Errorf("the entity name = %s; status = %d, want a and draft", entity.Name, entity.Status)
+	}
+	if db := Context().GetEntity(entity.UID); db == nil || db.Name != "a" {
+		t.Error("the name in database should be restored")
+	}
+	list := Context().GetRevisions(entity.UID, 0)
+	if len(list) != 3 || list[0].Action != RevisionRestore || list[0].Operator != "restorer" {
+		t.Fatalf("the latest revision should be the restore")
+	}
+	if err := entity.RestoreRevision(9, "restorer"); err == nil {
+		t.Error("restore a missing version should fail")
+	}
+}
+
+func TestRestoreRevisionEdges(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	if err := entity.UpdateStaticRelations("tester", newTestRelation("t1")); err != nil {
+		t.Fatalf("add relation: %v", err)
+	}
+	edges := Context().GetVEdgesByCenter(entity.UID)
+	if len(edges) != 1 {
+		t.Fatalf("the relations = %d, want 1", len(edges))
+	}
+	first := edges[0].UID
+	if err := entity.UpdateStaticRelations("tester", newTestRelation("t2")); err != nil {
+		t.Fatalf("add relation: %v", err)
+	}
+	if err := Context().RemoveVEdge(first, "tester"); err != nil {
+		t.Fatalf("remove relation: %v", err)
+	}
+	// 版本2只有第一个关系，恢复后第一个关系重新出现，之后新增的关系删除
+	if err := entity.RestoreRevision(2, "restorer"); err != nil {
+		t.Fatalf("restore revision: %v", err)
+	}
+	edges = Context().GetVEdgesByCenter(entity.UID)
+	if len(edges) != 1 || edges[0].UID != first {
+		t.Fatalf("the relations after restore = %d, want only the first", len(edges))
+	}
+	if err := entity.RestoreRevision(1, "restorer"); err != nil {
+		t.Fatalf("restore revision: %v", err)
+	}
+	if edges = Context().GetVEdgesByCenter(entity.UID); len(edges) != 0 {
+		t.Errorf("the relations after restore to create = %d, want 0", len(edges))
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"fmt"
+)
+
+type EntityErrorKind uint8
+
+const (
+	EntityErrorTransition EntityErrorKind = 1 //非法的状态转换
+	EntityErrorRemark     EntityErrorKind = 2 //拒绝时没有填写原因
+	EntityErrorPublished  EntityErrorKind = 3 //已经发布不能修改
+	EntityErrorNotDraft   EntityErrorKind = 4 //不是草稿不能修改
+	EntityErrorRefused    EntityErrorKind = 5 //工作流不允许
+	EntityErrorReferenced EntityErrorKind = 6 //存在其他数据的引用
+	EntityErrorConflict   EntityErrorKind = 7 //提交修改后实体已经被修改
+)
+
+const (
+	ErrorNotDraft      = "the entity is not draft so can not update"
+	ErrorRemarkEmpty   = "the remark is empty when refuse"
+	ErrorTransitionFmt = "the entity status can not change from %d to %d"
+)
+
+/**
+实体操作被拒绝的错误，grpc根据Kind返回对应的状态码
+*/
+type EntityError struct {
+	Kind EntityErrorKind
+	Msg  string
+}
+
+func (mine *EntityError) Error() string {
+	return mine.Msg
+}
+
+func newEntityError(kind EntityErrorKind, msg string) error {
+	return &EntityError{Kind: kind, Msg: msg}
+}
+
+/**
+实体状态允许的转换，审核通过前必须经过待审核，驳回后只能回到草稿重新提交
+*/
+var entityTransitions = map[EntityStatus][]EntityStatus{
+	EntityStatusDraft:   {EntityStatusFirst, EntityStatusPending},
+	EntityStatusFirst:   {EntityStatusPending, EntityStatusFailed, EntityStatusDraft},
+	EntityStatusPending: {EntityStatusUsable, EntityStatusSpecial, EntityStatusFailed, EntityStatusDraft},
+	EntityStatusSpecial: {EntityStatusUsable, EntityStatusPending, EntityStatusFailed},
+	EntityStatusUsable:  {EntityStatusDraft},
+	EntityStatusFailed:  {EntityStatusDraft},
+}
+
+/**
+返回状态可以转换到的状态
+*/
+func GetStatusTransitions(from EntityStatus) []EntityStatus {
+	return entityTransitions[from]
+}
+
+func checkStatusTransition(from, to EntityStatus) error {
+	for _, item := range entityTransitions[from] {
+		if item == to {
+			return nil
+		}
+	}
+	return newEntityError(EntityErrorTransition, fmt.Sprintf(ErrorTransitionFmt, from, to))
+}
+
+/**
+驳回以及退回到之前的状态为拒绝，驳回后重新编辑不算拒绝
+*/
+func statusOption(from, to EntityStatus) OptionType {
+	if to == EntityStatusFailed || (from != EntityStatusFailed && to < from) {
+		return OptionRefuse
+	}
+	return OptionAgree
+}
+
+/**
+已经发布的实体不能直接修改，需要先退回到草稿或者通过审核(examine)修改
+*/
+func (mine *EntityInfo) checkEditable() error {
+	if mine.Status == EntityStatusUsable {
+		return newEntityError(EntityErrorPublished, ErrorHadPublished)
+	}
+	return nil
+}
+
+/**
+只有草稿可以修改的数据
+*/
+func (mine *EntityInfo) checkDraft() error {
+	if err := mine.checkEditable(); err != nil {
+		return err
+	}
+	if mine.Status != EntityStatusDraft {
+		return newEntityError(EntityErrorNotDraft, ErrorNotDraft)
+	}
+	return nil
+}
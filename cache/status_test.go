@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+)
+
+func TestUpdateStatusRevertOnActionFailed(t *testing.T) {
+	initTestContext(t)
+	entity := newTestEntity(t, "a")
+	if err := entity.UpdateStatus(EntityStatusPending, "tester", ""); err != nil {
+		t.Fatalf("submit entity: %v", err)
+	}
+	// 无法解析的存档让发布时的存档动作失败
+	db := new(nosql.Archived)
+	db.UID = primitive.NewObjectID()
+	db.Entity = entity.UID
+	db.Format = ArchivedFormatLatest + 1
+	if err := nosql.CreateArchived(db); err != nil {
+		t.Fatalf("create archived: %v", err)
+	}
+	records, _ := nosql.GetRecords(entity.UID)
+	if err := entity.UpdateStatus(EntityStatusUsable, "reviewer", ""); err == nil {
+		t.Fatal("publish entity should fail when the archive failed")
+	}
+	if entity.Status != EntityStatusPending || entity.Published {
+		t.Errorf("the entity status = %d; published = %v, want pending", entity.Status, entity.Published)
+	}
+	if db, _ := nosql.GetEntity(entity.table(), entity.UID); db == nil || EntityStatus(db.Status) != EntityStatusPending {
+		t.Error("the status in database should be reverted")
+	}
+	if list, _ := nosql.GetRecords(entity.UID); len(list) != len(records) {
+		t.Errorf("the records = %d, want %d", len(list), len(records))
+	}
+}
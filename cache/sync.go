@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"errors"
+	"github.com/micro/go-micro/v2/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	GraphTaskNode       GraphTaskType = 1 //创建节点
+	GraphTaskLink       GraphTaskType = 2 //创建关系
+	GraphTaskRemoveNode GraphTaskType = 3 //删除节点
+	GraphTaskRemoveLink GraphTaskType = 4 //删除关系
+	GraphTaskUpdateNode GraphTaskType = 5 //更新节点名称和封面
+)
+
+const (
+	GraphTaskPending uint8 = 0
+	GraphTaskDead    uint8 = 1 //多次重试仍然失败，不再执行
+)
+
+const (
+	graphTaskBatch    = 100
+	graphTaskRetries  = 8
+	graphTaskBackoff  = 5    //首次重试的间隔秒数，之后每次翻倍
+	graphTaskMaxWait  = 3600 //重试的最大间隔秒数
+	graphTaskFailures = 20   //统计中返回的最近失败任务的数量
+)
+
+type GraphTaskType uint8
+
+/**
+图数据库同步队列的状态
+*/
+type GraphTaskStatistic struct {
+	Pending  uint32
+	Retry    uint32
+	Dead     uint32
+	Failures []*GraphTaskFailure
+}
+
+/**
+失败的同步任务，Target为节点的实体或者关系的两端，Error为最后一次失败的原因
+*/
+type GraphTaskFailure struct {
+	UID     string `json:"uid"`
+	Type    uint8  `json:"type"`
+	Dead    bool   `json:"dead"`
+	Target  string `json:"target"`
+	Retries uint32 `json:"retries"`
+	Error   string `json:"error"`
+	Updated int64  `json:"updated"`
+}
+
+func (mine *cacheContext) addGraphTask(info *nosql.GraphTask) {
+	info.UID = primitive.NewObjectID()
+	info.CreatedTime = time.Now()
+	info.Created = time.Now().Unix()
+	info.Status = GraphTaskPending
+	info.Next = info.Created
+	err := nosql.CreateGraphTask(info)
+	if err != nil {
+		logger.Warnf("add graph task failed that type = %d; entity = %s; err = %s", info.Type, info.Entity, err.Error())
+	}
+}
+
+func (mine *cacheContext) addSyncNode(uid, name, concept, cover string) {
+	mine.addGraphTask(&nosql.GraphTask{Type: uint8(GraphTaskNode), Entity: uid, Name: name, Concept: concept, Cover: cover})
+}
+
+func (mine *cacheContext) addSyncLink(from, to, relation, name string, kind LinkType, dir uint8, weight uint32) {
+	mine.addGraphTask(&nosql.GraphTask{Type: uint8(GraphTaskLink), From: from, To: to, Relation: relation,
+		Name: name, Kind: string(kind), Direction: dir, Weight: weight})
+}
+
+func (mine *cacheContext) addSyncRemoveNode(uid string) {
+	mine.addGraphTask(&nosql.GraphTask{Type: uint8(GraphTaskRemoveNode), Entity: uid})
+}
+
+func (mine *cacheContext) addSyncRemoveLink(from, to string) {
+	mine.addGraphTask(&nosql.GraphTask{Type: uint8(GraphTaskRemoveLink), From: from, To: to})
+}
+
+func (mine *cacheContext) addSyncUpdateNode(uid string) {
+	mine.addGraphTask(&nosql.GraphTask{Type: uint8(GraphTaskUpdateNode), Entity: uid})
+}
+
+/**
+执行到期的同步任务，失败的任务按指数退避重试，超过次数后进入死信状态
+*/
+func (mine *cacheContext) CheckGraphTasks() {
+	if !atomic.CompareAndSwapInt32(&mine.syncing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&mine.syncing, 0)
+	now := time.Now().Unix()
+	list, err := nosql.GetGraphTasksByNext(GraphTaskPending, now, graphTaskBatch)
+	if err != nil {
+		logger.Warn("get the graph tasks failed that " + err.Error())
+		return
+	}
+	for _, item := range list {
+		er := mine.runGraphTask(item)
+		if er == nil {
+			_ = nosql.DeleteGraphTask(item.UID.Hex())
+			continue
+		}
+		retries := item.Retries + 1
+		if retries >= graphTaskRetries {
+			logger.Warnf("the graph task is dead that uid = %s; err = %s", item.UID.Hex(), er.Error())
+			_ = nosql.UpdateGraphTaskStatus(item.UID.Hex(), GraphTaskDead, er.Error())
+			continue
+		}
+		wait := int64(graphTaskBackoff) << (retries - 1)
+		if wait > graphTaskMaxWait {
+			wait = graphTaskMaxWait
+		}
+		_ = nosql.UpdateGraphTaskRetry(item.UID.Hex(), retries, now+wait, er.Error())
+	}
+}
+
+func (mine *cacheContext) GetGraphTaskStatistic() *GraphTaskStatistic {
+	info := new(GraphTaskStatistic)
+	info.Pending = nosql.GetGraphTaskCount(GraphTaskPending)
+	info.Retry = nosql.GetGraphTaskRetryCount(GraphTaskPending)
+	info.Dead = nosql.GetGraphTaskCount(GraphTaskDead)
+	list, err := nosql.GetGraphTasksByFailed(graphTaskFailures)
+	if err != nil {
+		logger.Warn("get the failed graph tasks failed that " + err.Error())
+	}
+	info.Failures = make([]*GraphTaskFailure, 0, len(list))
+	for _, item := range list {
+		info.Failures = append(info.Failures, &GraphTaskFailure{UID: item.UID.Hex(), Type: item.Type, Dead: item.Status == GraphTaskDead,
+			Target: graphTaskTarget(item), Retries: item.Retries, Error: item.Error, Updated: item.Updated})
+	}
+	return info
+}
+
+func graphTaskTarget(info *nosql.GraphTask) string {
+	if len(info.Entity) > 0 {
+		return info.Entity
+	}
+	return info.From + "->" + info.To
+}
+
+func (mine *cacheContext) runGraphTask(info *nosql.GraphTask) error {
+	switch GraphTaskType(info.Type) {
+	case GraphTaskNode:
+		return mine.syncNode(info.Entity, info.Name, info.Concept, info.Cover)
+	case GraphTaskLink:
+		return mine.createLink(info.From, info.To, LinkType(info.Kind), info.Relation, info.Name, info.Direction, info.Weight)
+	case GraphTaskRemoveNode:
+		return mine.removeNode(info.Entity)
+	case GraphTaskRemoveLink:
+		return mine.removeLink(info.From, info.To)
+	case GraphTaskUpdateNode:
+		return mine.updateNode(info.Entity)
+	default:
+		return errors.New("not support the graph task type")
+	}
+}
+
+func (mine *cacheContext) syncNode(uid, name, concept, cover string) error {
+	node, err := proxy.GetNode(uid)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		node, err = proxy.CreateNode(name, switchEntityLabel(concept), uid)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return errors.New("the node create failed")
+		}
+	}
+	if len(cover) > 0 && node.Cover != cover {
+		node, err = node.Update(name, cover)
+		if err != nil {
+			return err
+		}
+	}
+	info := new(NodeInfo)
+	info.initInfo(node)
+	mine.graph.AppendNode(info)
+	return nil
+}
+
+func (mine *cacheContext) createLink(from, to string, kind LinkType, relationUID, name string, dire uint8, weight uint32) error {
+	if mine.graph.HadRelation(from, to, name) {
+		return nil
+	}
+	fromNode := mine.GetGraphNode(from)
+	toNode := mine.GetGraphNode(to)
+	_, err := mine.graph.CreateLink(fromNode, toNode, kind, name, relationUID, DirectionType(dire), weight)
+	return err
+}
+
+func (mine *cacheContext) removeNode(uid string) error {
+	node, err := proxy.GetNode(uid)
+	if err != nil || node == nil {
+		return err
+	}
+	if len(node.Labels) < 1 {
+		return errors.New("the node label is empty")
+	}
+	return mine.graph.RemoveNode(node.ID, node.Labels[0])
+}
+
+func (mine *cacheContext) removeLink(from, to string) error {
+	link, err := proxy.GetLink(from, to)
+	if err != nil || link == nil {
+		return err
+	}
+	return mine.graph.RemoveLink(link.ID)
+}
+
+func (mine *cacheContext) updateNode(uid string) error {
+	entity := mine.GetEntity(uid)
+	if entity == nil {
+		return nil
+	}
+	node, err := proxy.GetNode(uid)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return errors.New("not found the node in graph")
+	}
+	var name = entity.Name
+	if entity.Add != "" {
+		name = entity.Name + "-" + entity.Add
+	}
+	_, err = node.Update(name, entity.Cover)
+	if err != nil {
+		return err
+	}
+	mine.graph.updateNodeInfo(uid, name, entity.Cover)
+	return nil
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+)
+
+func TestGraphTaskFailures(t *testing.T) {
+	ctx := initTestContext(t)
+	// 不支持的任务类型总是执行失败
+	ctx.addGraphTask(&nosql.GraphTask{Type: 99, Entity: "e1"})
+	ctx.addGraphTask(&nosql.GraphTask{Type: 99, From: "a", To: "b"})
+	ctx.CheckGraphTasks()
+	info := ctx.GetGraphTaskStatistic()
+	if info.Pending != 2 || info.Retry != 2 || info.Dead != 0 {
+		t.Fatalf("the statistic = %+v, want 2 pending and retry", info)
+	}
+	if len(info.Failures) != 2 {
+		t.Fatalf("the failures = %d, want 2", len(info.Failures))
+	}
+	targets := map[string]bool{}
+	for _, item := range info.Failures {
+		if item.Error != "not support the graph task type" || item.Retries != 1 || item.Dead {
+			t.Errorf("the failure = %+v", item)
+		}
+		targets[item.Target] = true
+	}
+	if !targets["e1"] || !targets["a->b"] {
+		t.Errorf("the failure targets = %v, want e1 and a->b", targets)
+	}
+}
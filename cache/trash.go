@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy/nosql"
+	"time"
+)
+
+const (
+	TrashEntity  = "entity"
+	TrashEvent   = "event"
+	TrashConcept = "concept"
+	TrashBox     = "box"
+)
+
+/**
+回收站中的数据
+*/
+type TrashInfo struct {
+	UID      string `json:"uid"`
+	Kind     string `json:"kind"`
+	Table    string `json:"table"`
+	Name     string `json:"name"`
+	Operator string `json:"operator"`
+	Deleted  int64  `json:"deleted"`
+}
+
+/**
+数据类型对应的表以及场景字段
+*/
+func (mine *cacheContext) trashTables(kind string) ([]string, string, error) {
+	switch kind {
+	case TrashEntity:
+		return mine.EntityTables(), "scene", nil
+	case TrashEvent:
+		return []string{nosql.TableEvent}, "owner", nil
+	case TrashConcept:
+		return []string{nosql.TableConcept}, "", nil
+	case TrashBox:
+		return []string{nosql.TableBox}, "owner", nil
+	default:
+		return nil, "", fmt.Errorf("not support the trash kind of %s", kind)
+	}
+}
+
+/**
+按照删除时间从新到旧返回回收站中的数据，scene为空时返回全部场景
+*/
+func (mine *cacheContext) GetTrash(kind, scene string, num int64) ([]*TrashInfo, error) {
+	tables, key, err := mine.trashTables(kind)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*TrashInfo, 0, 20)
+	for _, table := range tables {
+		dbs, er := nosql.GetTrashItems(table, key, scene, num)
+		if er != nil {
+			continue
+		}
+		for _, db := range dbs {
+			list = append(list, &TrashInfo{UID: db.UID.Hex(), Kind: kind, Table: table, Name: db.Name,
+				Operator: db.Operator, Deleted: db.Deleted})
+		}
+	}
+	return list, nil
+}
+
+func (mine *cacheContext) getTrashItem(kind, uid string) (string, *nosql.TrashItem, error) {
+	tables, _, err := mine.trashTables(kind)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, table := range tables {
+		db, er := nosql.GetTrashItem(table, uid)
+		if er == nil {
+			return table, db, nil
+		}
+	}
+	return "", nil, errors.New("not found the item in trash")
+}
+
+/**
+从回收站恢复数据，上级数据已经删除时不能恢复
+*/
+func (mine *cacheContext) RestoreTrash(kind, uid, operator string) error {
+	table, db, err := mine.getTrashItem(kind, uid)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case TrashEvent:
+		if mine.GetEntity(db.Entity) == nil {
+			return errors.New("the entity of event had deleted")
+		}
+	case TrashConcept:
+		if len(db.Parent) > 0 && mine.GetConcept(db.Parent) == nil {
+			return errors.New("the parent of concept had deleted")
+		}
+		if mine.HadConceptByName(db.Name, db.Parent) {
+			return errors.New("the concept name repeated")
+		}
+	case TrashBox:
+		if mine.HadBoxByName(db.Name) {
+			return errors.New("the box name repeated")
+		}
+	}
+	err = nosql.RecoverTrashItem(table, uid, operator)
+	if err != nil {
+		return err
+	}
+	if kind == TrashEntity {
+		mine.restoreTrashEntity(uid, operator, db.Deleted)
+	}
+	return nil
+}
+
+/**
+恢复实体删除时一起删除的存档、事件、关系和审核以及关系图中的节点，
+只恢复删除时间不早于实体删除时间的数据，实体删除前单独删除的数据仍然留在回收站中，
+收藏夹和属性值中被清理的引用不会恢复
+*/
+func (mine *cacheContext) restoreTrashEntity(uid, operator string, deleted int64) {
+	info := mine.GetEntity(uid)
+	if info == nil {
+		return
+	}
+	if mine.GetArchivedByEntity(uid) == nil {
+		err := nosql.RecoverArchivedByEntity(uid, operator)
+		if err != nil {
+			logger.Warnf("restore the archived failed that entity = %s; err = %s", uid, err.Error())
+		}
+	}
+	events, err := nosql.RecoverTrashItemsBy(nosql.TableEvent, bson.M{"entity": uid}, deleted, operator)
+	mine.checkRestored("event", uid, err)
+	_, err = nosql.RecoverTrashItemsBy(nosql.TableEdge, bson.M{"center": uid}, deleted, operator)
+	mine.checkRestored("edge", uid, err)
+	references, err := nosql.RecoverTrashItemsBy(nosql.TableEdge, bson.M{"$or": bson.A{bson.M{"source": uid},
+		bson.M{"target.entity": uid}}}, deleted, operator)
+	mine.checkRestored("edge", uid, err)
+	_, err = nosql.RecoverTrashItemsBy(nosql.TableExamine, bson.M{"target": uid}, deleted, operator)
+	mine.checkRestored("examine", uid, err)
+
+	mine.syncGraphNode(info)
+	mine.checkRelations(info)
+	for _, item := range references {
+		if edge, er := mine.GetVEdge(item.UID.Hex()); er == nil {
+			mine.syncEdgeLink(edge)
+		}
+	}
+	for _, item := range events {
+		event := mine.GetEvent(item.UID.Hex())
+		if event == nil {
+			continue
+		}
+		for _, relation := range event.Relations {
+			if tmp := mine.GetRelation(relation.Category); tmp != nil {
+				mine.addSyncLink(event.Entity, relation.Entity, tmp.UID, relation.Name, switchRelationToLink(tmp.Kind), relation.Direction, relation.Weight)
+			}
+		}
+	}
+}
+
+func (mine *cacheContext) checkRestored(kind, entity string, err error) {
+	if err != nil {
+		logger.Warnf("restore the dependency of entity failed that kind = %s; entity = %s; err = %s", kind, entity, err.Error())
+	}
+}
+
+/**
+彻底删除超过保留天数的数据，包括随实体一起删除的存档、关系和审核，
+存档版本仍然引用的GridFS文件不删除
+*/
+func (mine *cacheContext) PurgeTrash() (uint32, error) {
+	days := config.Schema.Basic.Retention
+	if days < 1 {
+		return 0, nil
+	}
+	before := time.Now().Unix() - int64(days)*24*3600
+	tables := make([]string, 0, 8)
+	tables = append(tables, mine.EntityTables()...)
+	tables = append(tables, nosql.TableEvent, nosql.TableConcept, nosql.TableBox, nosql.TableArchived,
+		nosql.TableEdge, nosql.TableExamine)
+	var count uint32 = 0
+	for _, table := range tables {
+		list, err := nosql.PurgeTrashItems(table, before)
+		count += uint32(len(list))
+		for _, item := range list {
+			if len(item.Blob) > 0 && !nosql.HadArchivedVersionBlob(item.Blob) {
+				_ = nosql.DeleteBlob(nosql.BucketArchived, item.Blob)
+			}
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	if count > 0 {
+		logger.Infof("purge the trash that retention = %d days; count = %d", days, count)
+	}
+	return count, nil
+}
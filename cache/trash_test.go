@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy"
+	"omo.msa.vocabulary/proxy/nosql"
+	"testing"
+	"time"
+)
+
+func TestRestoreTrashEntity(t *testing.T) {
+	ctx := initTestContext(t)
+	entity := newTestEntity(t, "a")
+	other := newTestEntity(t, "b")
+	event := new(nosql.Event)
+	event.UID = primitive.NewObjectID()
+	event.ID = nosql.GetEventNextID()
+	event.Entity = entity.UID
+	event.Name = "event"
+	if err := nosql.CreateEvent(event); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	edge, err := ctx.CreateVEdge(entity.UID, entity.UID, "r", "", "", "tester", 0, 0, 0, proxy.VNode{Name: "x"})
+	if err != nil {
+		t.Fatalf("create edge: %v", err)
+	}
+	reference, err := ctx.CreateVEdge(other.UID, other.UID, "r", "", "", "tester", 0, 0, 0,
+		proxy.VNode{Name: "a", Entity: entity.UID})
+	if err != nil {
+		t.Fatalf("create reference: %v", err)
+	}
+	// 实体删除前已经单独删除的关系
+	early := new(nosql.VEdge)
+	early.UID = primitive.NewObjectID()
+	early.Center = entity.UID
+	early.Name = "early"
+	early.Deleted = 1
+	if err = nosql.CreateVEdge(early); err != nil {
+		t.Fatalf("create edge: %v", err)
+	}
+	examine, err := ctx.SubmitExamine("u1", entity.UID, ExamineBaseName, "a2", uint8(ExamineTypeBase))
+	if err != nil {
+		t.Fatalf("submit examine: %v", err)
+	}
+
+	if _, err = ctx.RemoveEntity(entity.UID, "tester", RemoveCascade); err != nil {
+		t.Fatalf("remove entity: %v", err)
+	}
+	if len(ctx.GetVEdgesByCenter(entity.UID)) != 0 || ctx.GetExamine(examine.UID).Data.Deleted == 0 {
+		t.Fatal("the dependencies of entity should be removed")
+	}
+	if err = ctx.RestoreTrash(TrashEntity, entity.UID, "tester"); err != nil {
+		t.Fatalf("restore entity: %v", err)
+	}
+	if ctx.GetEntity(entity.UID) == nil {
+		t.Fatal("the entity is not restored")
+	}
+	if num := nosql.GetEventCountByEntity(entity.UID); num != 1 {
+		t.Errorf("the restored events = %d, want 1", num)
+	}
+	edges := ctx.GetVEdgesByCenter(entity.UID)
+	if len(edges) != 1 || edges[0].UID != edge.UID {
+		t.Errorf("the restored edges = %d, want only the cascaded edge", len(edges))
+	}
+	if got, er := ctx.GetVEdge(reference.UID); er != nil || got.Center != other.UID {
+		t.Errorf("the reference is not restored: %v", er)
+	}
+	if ctx.GetExamine(examine.UID).Data.Deleted != 0 {
+		t.Error("the examine is not restored")
+	}
+}
+
+func TestPurgeTrashKeepsVersionBlob(t *testing.T) {
+	initTestContext(t)
+	config.Schema.Basic.Retention = 1
+	defer func() { config.Schema.Basic.Retention = 0 }()
+	old := time.Now().Unix() - 3*24*3600
+	shared, _ := nosql.UploadBlob(nosql.BucketArchived, "shared", []byte("shared"))
+	single, _ := nosql.UploadBlob(nosql.BucketArchived, "single", []byte("single"))
+	for _, blob := range []string{shared, single} {
+		db := new(nosql.Archived)
+		db.UID = primitive.NewObjectID()
+		db.Entity = primitive.NewObjectID().Hex()
+		db.Blob = blob
+		db.Deleted = old
+		if err := nosql.CreateArchived(db); err != nil {
+			t.Fatalf("create archived: %v", err)
+		}
+	}
+	version := new(nosql.ArchivedVersion)
+	version.UID = primitive.NewObjectID()
+	version.Blob = shared
+	if err := nosql.CreateArchivedVersion(version); err != nil {
+		t.Fatalf("create archived version: %v", err)
+	}
+	edge := new(nosql.VEdge)
+	edge.UID = primitive.NewObjectID()
+	edge.Deleted = old
+	_ = nosql.CreateVEdge(edge)
+
+	count, err := Context().PurgeTrash()
+	if err != nil || count != 3 {
+		t.Fatalf("purge trash = %d, %v; want 3", count, err)
+	}
+	if _, err = nosql.DownloadBlob(nosql.BucketArchived, shared); err != nil {
+		t.Errorf("the blob referenced by version is deleted: %v", err)
+	}
+	if _, err = nosql.DownloadBlob(nosql.BucketArchived, single); err == nil {
+		t.Error("the blob of purged archived should be deleted")
+	}
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/micro/go-micro/v2/logger"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/tool"
+)
+
+const (
+	WorkflowRoleUser     = "user"     //收藏夹的采集人
+	WorkflowRoleReviewer = "reviewer" //收藏夹的审核人
+	WorkflowRoleAdmin    = "admin"    //工作流的管理员或者收藏夹的创建者
+)
+
+const (
+	WorkflowActionArchive = "archive" //发布存档
+	WorkflowActionNotify  = "notify"  //发送通知
+	WorkflowActionGraph   = "graph"   //同步到关系图
+)
+
+const ErrorWorkflowRefused = "the status transition is not allowed by the workflow"
+
+/**
+实体进入工作流中的状态时发送的通知
+*/
+type WorkflowNotice struct {
+	Workflow string       `json:"workflow"`
+	Box      string       `json:"box"`
+	Entity   string       `json:"entity"`
+	Name     string       `json:"name"`
+	From     EntityStatus `json:"from"`
+	To       EntityStatus `json:"to"`
+	Operator string       `json:"operator"`
+	Remark   string       `json:"remark"`
+}
+
+type WorkflowNotifier func(notice *WorkflowNotice)
+
+/**
+实体所在收藏夹的工作流
+*/
+type boxWorkflow struct {
+	box  *BoxInfo
+	flow *config.WorkflowConfig
+}
+
+func (mine *cacheContext) GetWorkflows() []*config.WorkflowConfig {
+	return config.Schema.Workflows
+}
+
+/**
+设置工作流的通知方式，默认只输出日志
+*/
+func (mine *cacheContext) SetWorkflowNotifier(notifier WorkflowNotifier) {
+	mine.notifier = notifier
+}
+
+func (mine *cacheContext) notifyWorkflow(notice *WorkflowNotice) {
+	if mine.notifier != nil {
+		mine.notifier(notice)
+		return
+	}
+	logger.Infof("workflow notice that workflow = %s; box = %s; entity = %s; from = %d; to = %d; operator = %s",
+		notice.Workflow, notice.Box, notice.Entity, notice.From, notice.To, notice.Operator)
+}
+
+/**
+包含实体并且设置了工作流的收藏夹
+*/
+func (mine *cacheContext) getEntityWorkflows(entity string) ([]*boxWorkflow, error) {
+	list := make([]*boxWorkflow, 0, 2)
+	for _, box := range mine.GetBoxesByKeyword(entity) {
+		if len(box.Workflow) < 1 {
+			continue
+		}
+		flow := config.Schema.GetWorkflow(box.Workflow)
+		if flow == nil {
+			return nil, fmt.Errorf("not found the workflow of %s in box %s", box.Workflow, box.UID)
+		}
+		list = append(list, &boxWorkflow{box: box, flow: flow})
+	}
+	return list, nil
+}
+
+/**
+操作者在收藏夹中的角色
+*/
+func (mine *boxWorkflow) roles(operator string) []string {
+	list := make([]string, 0, 3)
+	if mine.box.HadUser(operator) {
+		list = append(list, WorkflowRoleUser)
+	}
+	if mine.box.HadReviewer(operator) {
+		list = append(list, WorkflowRoleReviewer)
+	}
+	if mine.box.Creator == operator || tool.HasItem(mine.flow.Admins, operator) {
+		list = append(list, WorkflowRoleAdmin)
+	}
+	return list
+}
+
+func (mine *boxWorkflow) allowed(from, to EntityStatus, operator string) bool {
+	trans := mine.flow.GetTransition(uint8(from), uint8(to))
+	if trans == nil {
+		return false
+	}
+	for _, role := range mine.roles(operator) {
+		if tool.HasItem(trans.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+检查实体所在的全部工作流是否都允许操作者执行状态转换，返回需要执行动作的工作流
+*/
+func (mine *cacheContext) checkWorkflows(entity *EntityInfo, to EntityStatus, operator string) ([]*boxWorkflow, error) {
+	list, err := mine.getEntityWorkflows(entity.UID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list {
+		if !item.allowed(entity.Status, to, operator) {
+			logger.Warnf("the workflow refused that workflow = %s; box = %s; entity = %s; from = %d; to = %d; operator = %s",
+				item.flow.ID, item.box.UID, entity.UID, entity.Status, to, operator)
+			return nil, newEntityError(EntityErrorRefused, ErrorWorkflowRefused)
+		}
+	}
+	return list, nil
+}
+
+/**
+进入状态时需要执行的动作，没有工作流时只在发布时存档
+*/
+func workflowActions(list []*boxWorkflow, to EntityStatus) []string {
+	actions := make([]string, 0, 3)
+	if len(list) < 1 {
+		if to == EntityStatusUsable {
+			actions = append(actions, WorkflowActionArchive)
+		}
+		return actions
+	}
+	for _, item := range list {
+		state := item.flow.GetState(uint8(to))
+		if state == nil {
+			continue
+		}
+		for _, action := range state.Actions {
+			if !tool.HasItem(actions, action) {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions
+}
+
+/**
+操作者可以将实体转换到的状态，实体不在任何工作流中时返回nil
+*/
+func (mine *cacheContext) GetWorkflowTransitions(entity *EntityInfo, operator string) ([]EntityStatus, error) {
+	list, err := mine.getEntityWorkflows(entity.UID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) < 1 {
+		return nil, nil
+	}
+	arr := make([]EntityStatus, 0, 3)
+	for _, trans := range list[0].flow.Transitions {
+		to := EntityStatus(trans.To)
+		if trans.From != uint8(entity.Status) {
+			continue
+		}
+		ok := true
+		for _, item := range list {
+			if !item.allowed(entity.Status, to, operator) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			arr = append(arr, to)
+		}
+	}
+	return arr, nil
+}
+
+/**
+执行进入状态时的动作，存档失败时返回错误
+*/
+func (mine *EntityInfo) runWorkflowActions(list []*boxWorkflow, actions []string, from, to EntityStatus, operator, remark string) error {
+	if tool.HasItem(actions, WorkflowActionArchive) {
+		err := mine.publishArchived(operator, remark)
+		if err != nil {
+			return err
+		}
+	}
+	if tool.HasItem(actions, WorkflowActionGraph) {
+		cacheCtx.syncGraphNode(mine)
+		cacheCtx.checkRelations(mine)
+	}
+	if tool.HasItem(actions, WorkflowActionNotify) {
+		for _, item := range list {
+			state := item.flow.GetState(uint8(to))
+			if state == nil || !tool.HasItem(state.Actions, WorkflowActionNotify) {
+				continue
+			}
+			cacheCtx.notifyWorkflow(&WorkflowNotice{Workflow: item.flow.ID, Box: item.box.UID, Entity: mine.UID, Name: mine.Name,
+				From: from, To: to, Operator: operator, Remark: remark})
+		}
+	}
+	return nil
+}
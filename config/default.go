@@ -24,11 +24,15 @@ const defaultJson string = `{
 		"user": "neo4j",
 		"password": "yumei2020",
 		"ip": "127.0.0.1",
-		"port": "11005"
+		"port": "11005",
+		"type": "neo4j"
 	},
 	"basic": {
 		"tags": 6,
 		"synonyms": 5,
+		"retention": 30,
+		"admins": [],
+		"fixups": false,
 		"kinds":[
 			{
 				"type":1,
@@ -47,6 +51,37 @@ const defaultJson string = `{
 				"name":"事件"
 			}
 		]
-	}
+	},
+	"review": {
+		"strategy": "load",
+		"sla": 86400
+	},
+	"workflows": [
+		{
+			"id": "review",
+			"name": "采集审核",
+			"admins": [],
+			"states": [
+				{"status": 0, "name": "草稿", "actions": []},
+				{"status": 1, "name": "初审", "actions": []},
+				{"status": 2, "name": "待审核", "actions": ["notify"]},
+				{"status": 3, "name": "特殊", "actions": ["notify"]},
+				{"status": 4, "name": "已发布", "actions": ["archive", "graph", "notify"]},
+				{"status": 10, "name": "驳回", "actions": ["notify"]}
+			],
+			"transitions": [
+				{"from": 0, "to": 1, "roles": ["user", "admin"]},
+				{"from": 1, "to": 2, "roles": ["user", "reviewer", "admin"]},
+				{"from": 1, "to": 10, "roles": ["reviewer", "admin"]},
+				{"from": 2, "to": 4, "roles": ["reviewer", "admin"]},
+				{"from": 2, "to": 3, "roles": ["reviewer", "admin"]},
+				{"from": 2, "to": 10, "roles": ["reviewer", "admin"]},
+				{"from": 3, "to": 4, "roles": ["admin"]},
+				{"from": 3, "to": 10, "roles": ["admin"]},
+				{"from": 10, "to": 0, "roles": ["user", "admin"]},
+				{"from": 4, "to": 0, "roles": ["admin"]}
+			]
+		}
+	]
 }
 `
@@ -22,6 +22,7 @@ type DBConfig struct {
 }
 
 type GraphConfig struct {
+	Type     string `json:"type"`
 	Password string `json:"password"`
 	IP       string `json:"ip"`
 	Port     string `json:"port"`
@@ -38,14 +39,54 @@ type BasicConfig struct {
 	SynonymMax int32        `json:"synonyms"`
 	TagMax     int32        `json:"tags"`
 	Kinds      []*GraphType `json:"kinds"`
+	Retention  int32        `json:"retention"` //回收站保留的天数，为0时不清理
+	Admins     []string     `json:"admins"`    //可以执行管理操作的用户，为空时禁止管理操作
+	Fixups     bool         `json:"fixups"`    //是否执行针对早期数据的一次性修复，默认不执行
+}
+
+/**
+工作流中的状态，Actions为进入状态时执行的动作
+*/
+type WorkflowState struct {
+	Status  uint8    `json:"status"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+/**
+允许的状态转换，Roles为可以触发转换的角色
+*/
+type WorkflowTransition struct {
+	From  uint8    `json:"from"`
+	To    uint8    `json:"to"`
+	Roles []string `json:"roles"`
+}
+
+type WorkflowConfig struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Admins      []string              `json:"admins"`
+	States      []*WorkflowState      `json:"states"`
+	Transitions []*WorkflowTransition `json:"transitions"`
+}
+
+/**
+审核队列，Strategy为分配方式(round为轮流分配，load为分配给待审核最少的人)，
+SLA为审核的期限(秒)，为0时不检查超时
+*/
+type ReviewConfig struct {
+	Strategy string `json:"strategy"`
+	SLA      int64  `json:"sla"`
 }
 
 type SchemaConfig struct {
-	Service  ServiceConfig `json:"service"`
-	Logger   LoggerConfig  `json:"logger"`
-	Database DBConfig      `json:"database"`
-	Graph    GraphConfig   `json:"graph"`
-	Basic    BasicConfig   `json:"basic"`
+	Service   ServiceConfig     `json:"service"`
+	Logger    LoggerConfig      `json:"logger"`
+	Database  DBConfig          `json:"database"`
+	Graph     GraphConfig       `json:"graph"`
+	Basic     BasicConfig       `json:"basic"`
+	Workflows []*WorkflowConfig `json:"workflows"`
+	Review    ReviewConfig      `json:"review"`
 }
 
 func (mine *BasicConfig) GetName(tp uint8) string {
@@ -56,3 +97,30 @@ func (mine *BasicConfig) GetName(tp uint8) string {
 	}
 	return ""
 }
+
+func (mine *SchemaConfig) GetWorkflow(uid string) *WorkflowConfig {
+	for _, item := range mine.Workflows {
+		if item.ID == uid {
+			return item
+		}
+	}
+	return nil
+}
+
+func (mine *WorkflowConfig) GetState(st uint8) *WorkflowState {
+	for _, item := range mine.States {
+		if item.Status == st {
+			return item
+		}
+	}
+	return nil
+}
+
+func (mine *WorkflowConfig) GetTransition(from, to uint8) *WorkflowTransition {
+	for _, item := range mine.Transitions {
+		if item.From == from && item.To == to {
+			return item
+		}
+	}
+	return nil
+}
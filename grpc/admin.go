@@ -0,0 +1,184 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"omo.msa.vocabulary/cache"
+)
+
+/**
+管理服务，修改数据的维护操作只允许配置的管理员执行，proto中没有定义，通过反射注册，
+接口为AdminService.FixIntegrity等
+*/
+type AdminService struct{}
+
+/**
+检查全部数据的引用关系并修复可以安全修复的错误，key为错误类型，msg为检查报告
+*/
+func (mine *AdminService) FixIntegrity(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "admin.fixIntegrity"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	report, err := cache.Context().CheckIntegrity(true)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	out.List = switchIntegrityCounts(report)
+	out.Count = uint32(len(report.Issues))
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(report)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+/**
+执行还没有成功的迁移，key为迁移名称，count为状态，msg为迁移的状态
+*/
+func (mine *AdminService) RunMigrations(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "admin.runMigrations"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	_, err := cache.Context().RunMigrations()
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	list, err := cache.Context().GetMigrations()
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	switchMigrations(list, out)
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(list)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+/**
+将存档重新编码为最新的格式，msg为校验失败和迁移失败的存档
+*/
+func (mine *AdminService) MigrateArchives(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "admin.migrateArchives"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	result, err := cache.Context().MigrateArchives(false)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	out.List = switchArchivedMigration(result)
+	out.Count = result.Total
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(result)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+/**
+以实体数据为准修复图数据库中的节点和关系，同步任务执行时返回失败，msg为对齐报告
+*/
+func (mine *AdminService) ReconcileGraph(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "admin.reconcileGraph"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	report, err := cache.Context().ReconcileGraph(false)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	out.List = switchReconcileReport(report)
+	out.Count = report.Changes()
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(report)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+/**
+立即同步审核队列，不需要等待定时任务，count为创建和分配的任务数量，msg为同步结果
+*/
+func (mine *AdminService) SyncReviews(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "admin.syncReviews"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	result, err := cache.Context().SyncReviews()
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_Prohibition)
+		return nil
+	}
+	out.Count = result.Created + result.Assigned
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(result)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+func switchReconcileReport(report *cache.ReconcileReport) []*pb.StatisticInfo {
+	list := make([]*pb.StatisticInfo, 0, 9)
+	list = append(list, &pb.StatisticInfo{Key: "nodes", Count: report.Nodes})
+	list = append(list, &pb.StatisticInfo{Key: "links", Count: report.Links})
+	list = append(list, &pb.StatisticInfo{Key: "createdNodes", Count: report.CreatedNodes})
+	list = append(list, &pb.StatisticInfo{Key: "updatedNodes", Count: report.UpdatedNodes})
+	list = append(list, &pb.StatisticInfo{Key: "removedNodes", Count: report.RemovedNodes})
+	list = append(list, &pb.StatisticInfo{Key: "createdLinks", Count: report.CreatedLinks})
+	list = append(list, &pb.StatisticInfo{Key: "updatedLinks", Count: report.UpdatedLinks})
+	list = append(list, &pb.StatisticInfo{Key: "removedLinks", Count: report.RemovedLinks})
+	list = append(list, &pb.StatisticInfo{Key: "errors", Count: uint32(len(report.Errors))})
+	return list
+}
+
+func switchArchivedMigration(result *cache.ArchivedMigration) []*pb.StatisticInfo {
+	list := make([]*pb.StatisticInfo, 0, 5)
+	list = append(list, &pb.StatisticInfo{Key: "total", Count: result.Total})
+	list = append(list, &pb.StatisticInfo{Key: "migrated", Count: result.Migrated})
+	list = append(list, &pb.StatisticInfo{Key: "latest", Count: result.Latest})
+	list = append(list, &pb.StatisticInfo{Key: "mismatch", Count: uint32(len(result.Mismatch))})
+	list = append(list, &pb.StatisticInfo{Key: "failed", Count: uint32(len(result.Failed))})
+	return list
+}
+
+func switchMigrations(list []*cache.MigrationInfo, out *pb.ReplyStatistic) {
+	out.List = make([]*pb.StatisticInfo, 0, len(list))
+	for _, item := range list {
+		out.List = append(out.List, &pb.StatisticInfo{Key: item.Name, Count: uint32(item.Status)})
+		if item.Status == cache.MigrationDone {
+			out.Count += 1
+		}
+	}
+}
+
+func switchIntegrityCounts(report *cache.IntegrityReport) []*pb.StatisticInfo {
+	kinds := []string{cache.IntegrityPropertyAttribute, cache.IntegrityPropertyEntity, cache.IntegrityEdgeRelation,
+		cache.IntegrityEdgeCenter, cache.IntegrityEdgeTarget, cache.IntegrityConceptParent, cache.IntegrityBoxContent,
+		cache.IntegrityEventEntity}
+	list := make([]*pb.StatisticInfo, 0, len(kinds)+1)
+	for _, kind := range kinds {
+		list = append(list, &pb.StatisticInfo{Key: kind, Count: report.Count(kind)})
+	}
+	list = append(list, &pb.StatisticInfo{Key: "fixed", Count: report.Fixed})
+	return list
+}
@@ -2,9 +2,11 @@ package grpc
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/micro/go-micro/v2/logger"
 	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
 	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"omo.msa.vocabulary/cache"
 )
 
 func inLog(name, data interface{}) {
@@ -22,6 +24,35 @@ func outError(name, msg string, code pbstaus.ResultStatus) *pb.ReplyStatus {
 	return tmp
 }
 
+/**
+实体操作被拒绝时按照错误类型返回状态码，其他错误为数据库异常
+*/
+func outEntityError(name string, err error) *pb.ReplyStatus {
+	code := pbstaus.ResultStatus_DBException
+	var tmp *cache.EntityError
+	if errors.As(err, &tmp) {
+		switch tmp.Kind {
+		case cache.EntityErrorTransition, cache.EntityErrorConflict:
+			code = pbstaus.ResultStatus_NotMatch
+		case cache.EntityErrorRemark:
+			code = pbstaus.ResultStatus_Empty
+		default:
+			code = pbstaus.ResultStatus_Prohibition
+		}
+	}
+	return outError(name, err.Error(), code)
+}
+
+/**
+操作者不是管理员时返回拒绝的状态
+*/
+func checkAdmin(name, operator string) *pb.ReplyStatus {
+	if cache.Context().IsAdmin(operator) {
+		return nil
+	}
+	return outError(name, "the operator is not the admin", pbstaus.ResultStatus_Prohibition)
+}
+
 func outLog(name, data interface{}) *pb.ReplyStatus {
 	bytes, _ := json.Marshal(data)
 	msg := ByteString(bytes)
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
@@ -185,6 +186,41 @@ func (mine *BoxService) GetByFilter(ctx context.Context, in *pb.RequestFilter, o
 func (mine *BoxService) GetStatistic(ctx context.Context, in *pb.RequestFilter, out *pb.ReplyStatistic) error {
 	path := "box.getStatistic"
 	inLog(path, in)
+	if in.Key == "workflows" {
+		// key为工作流的ID，count为状态的数量，msg为工作流的定义
+		list := cache.Context().GetWorkflows()
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for _, item := range list {
+			out.List = append(out.List, &pb.StatisticInfo{Key: item.ID, Count: uint32(len(item.States))})
+		}
+		out.Count = uint32(len(list))
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(list)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "transitions" {
+		// value为实体，parent为操作者，key为可以转换到的状态
+		entity := cache.Context().GetEntity(in.Value)
+		if entity == nil {
+			out.Status = outError(path, "not found the entity by uid", pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		list, err := cache.Context().GetWorkflowTransitions(entity, in.Parent)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for _, item := range list {
+			out.List = append(out.List, &pb.StatisticInfo{Key: fmt.Sprintf("%d", item), Count: uint32(item)})
+		}
+		out.Count = uint32(len(list))
+		out.Key = in.Key
+		out.Owner = in.Value
+		out.Status = outLog(path, out)
+		return nil
+	}
 	out.Status = outError(path, "param is empty", pbstaus.ResultStatus_Empty)
 	return nil
 }
@@ -327,6 +363,8 @@ func (mine *BoxService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdateFilt
 		err = box.UpdateUsers(in.Values, in.Operator, true)
 	} else if in.Key == "concept" {
 		err = box.UpdateConcept(in.Value, in.Operator)
+	} else if in.Key == "workflow" {
+		err = box.UpdateWorkflow(in.Value, in.Operator)
 	} else if in.Key == "fill" {
 		if len(in.Values) == 2 {
 			err = box.FillContent(in.Values[0], in.Values[1], in.Operator)
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
@@ -15,6 +16,15 @@ import (
 
 type EntityService struct{}
 
+type revisionSummary struct {
+	Version  uint32   `json:"version"`
+	Action   string   `json:"action"`
+	Operator string   `json:"operator"`
+	Created  int64    `json:"created"`
+	Remark   string   `json:"remark"`
+	Fields   []string `json:"fields"`
+}
+
 func switchEntity(info *cache.EntityInfo, all bool) *pb.EntityInfo {
 	tmp := new(pb.EntityInfo)
 	tmp.Brief = switchEntityBrief(info)
@@ -325,13 +335,26 @@ func (mine *EntityService) GetByMark(ctx context.Context, in *pb.RequestInfo, ou
 func (mine *EntityService) RemoveOne(ctx context.Context, in *pb.RequestInfo, out *pb.ReplyInfo) error {
 	path := "entity.removeOne"
 	inLog(path, in)
-	err := cache.Context().RemoveEntity(in.Uid, in.Operator)
+	// key为cascade时同时清理其他数据对实体的引用，否则存在引用时拒绝删除，msg为受影响的数据
+	mode := cache.RemoveRefuse
+	if in.Key == "cascade" {
+		mode = cache.RemoveCascade
+	}
+	info, err := cache.Context().RemoveEntity(in.Uid, in.Operator, mode)
 	out.Uid = in.Uid
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
+		if info != nil {
+			bytes, _ := json.Marshal(info)
+			out.Status.Msg = string(bytes)
+		}
 		return nil
 	}
 	out.Status = outLog(path, out)
+	if info != nil {
+		bytes, _ := json.Marshal(info)
+		out.Status.Msg = string(bytes)
+	}
 	return nil
 }
 
@@ -552,6 +575,220 @@ func (mine *EntityService) GetStatistic(ctx context.Context, in *pb.RequestFilte
 		for _, item := range arr {
 			out.List = append(out.List, &pb.StatisticInfo{Key: item})
 		}
+	} else if in.Key == "import" {
+		// 只校验导入的数据，写入数据库需要调用ImportService.Commit；
+		// value为导入类型，values为文件格式、base64编码的文件内容以及可选的匹配选项，parent为所属单位
+		body, option, err := parseImportValues(in.Values)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
+			return nil
+		}
+		report, err := cache.Context().ImportData(in.Value, in.Values[0], in.Parent, "", body, option, false)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
+			return nil
+		}
+		switchImportReport(report, out)
+		out.Key = in.Key
+		out.Owner = in.Parent
+		out.Status = outLog(path, out)
+		return nil
+	} else if in.Key == "revisions" {
+		// parent为实体，number为数量，key为 版本:操作:操作者:时间，msg为修订的详细信息
+		list := cache.Context().GetRevisions(in.Parent, int64(in.Number))
+		items := make([]*revisionSummary, 0, len(list))
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for _, item := range list {
+			key := fmt.Sprintf("%d:%s:%s:%d", item.Version, item.Action, item.Operator, item.Created)
+			out.List = append(out.List, &pb.StatisticInfo{Key: key, Count: item.Version})
+			fields := make([]string, 0, len(item.Fields))
+			for _, field := range item.Fields {
+				fields = append(fields, field.Field)
+			}
+			items = append(items, &revisionSummary{Version: item.Version, Action: item.Action, Operator: item.Operator,
+				Created: item.Created, Remark: item.Remark, Fields: fields})
+		}
+		out.Count = uint32(len(list))
+		out.Key = in.Key
+		out.Owner = in.Parent
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(items)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "revision_diff" {
+		// parent为实体，values为比较的两个版本，msg为修改的字段以及前后的值
+		if len(in.Values) < 2 {
+			out.Status = outError(path, "the versions of diff is empty", pbstaus.ResultStatus_Empty)
+			return nil
+		}
+		from := cache.StringToUint32(in.Values[0])
+		to := cache.StringToUint32(in.Values[1])
+		fields, err := cache.Context().DiffRevisions(in.Parent, from, to)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		out.List = make([]*pb.StatisticInfo, 0, len(fields))
+		for _, field := range fields {
+			out.List = append(out.List, &pb.StatisticInfo{Key: field.Field, Count: to})
+		}
+		out.Count = uint32(len(fields))
+		out.Key = in.Key
+		out.Owner = in.Parent
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(fields)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "archived_versions" {
+		// parent为实体，number为数量，key为 版本:发布者:时间:说明，owner为当前公开的版本
+		list := cache.Context().GetArchivedVersions(in.Parent, int64(in.Number))
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for _, item := range list {
+			key := fmt.Sprintf("%d:%s:%d:%s", item.Version, item.Operator, item.Created, item.Note)
+			out.List = append(out.List, &pb.StatisticInfo{Key: key, Count: item.Size})
+		}
+		out.Count = uint32(len(list))
+		out.Key = in.Key
+		out.Owner = in.Parent
+		if archived := cache.Context().GetArchivedByEntity(in.Parent); archived != nil {
+			out.Owner = fmt.Sprintf("%d", archived.Version)
+		}
+		out.Status = outLog(path, out)
+		return nil
+	} else if in.Key == "archived_diff" {
+		// parent为实体，values为比较的两个版本，msg为修改的字段以及前后的值
+		if len(in.Values) < 2 {
+			out.Status = outError(path, "the versions of diff is empty", pbstaus.ResultStatus_Empty)
+			return nil
+		}
+		from := cache.StringToUint32(in.Values[0])
+		to := cache.StringToUint32(in.Values[1])
+		fields, err := cache.Context().DiffArchivedVersions(in.Parent, from, to)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		out.List = make([]*pb.StatisticInfo, 0, len(fields))
+		for _, field := range fields {
+			out.List = append(out.List, &pb.StatisticInfo{Key: field.Field, Count: to})
+		}
+		out.Count = uint32(len(fields))
+		out.Key = in.Key
+		out.Owner = in.Parent
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(fields)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "archived_migrate" {
+		// 只校验存档，重新编码通过AdminService.MigrateArchives，msg为校验失败的存档
+		result, err := cache.Context().MigrateArchives(true)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.List = switchArchivedMigration(result)
+		out.Count = result.Total
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(result)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "migrations" {
+		// 执行迁移通过AdminService.RunMigrations，key为迁移名称，count为状态，msg为迁移的状态
+		list, err := cache.Context().GetMigrations()
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		switchMigrations(list, out)
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(list)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "indexes" {
+		// 返回启动时同步索引的结果，msg为创建、多余和失败的索引
+		report := cache.Context().GetIndexReport()
+		if report == nil {
+			out.Status = outError(path, "not found the index report", pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		out.List = make([]*pb.StatisticInfo, 0, 3)
+		out.List = append(out.List, &pb.StatisticInfo{Key: "created", Count: uint32(len(report.Created))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "extra", Count: uint32(len(report.Extra))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "failed", Count: uint32(len(report.Failed))})
+		out.Count = uint32(len(report.Failed))
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(report)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "dependencies" {
+		// value为实体，key为依赖数据的类型，count为1时存在其他数据的引用，msg为依赖数据的UID
+		info := cache.Context().GetEntityDependency(in.Value)
+		out.List = make([]*pb.StatisticInfo, 0, 7)
+		out.List = append(out.List, &pb.StatisticInfo{Key: "events", Count: uint32(len(info.Events))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "edges", Count: uint32(len(info.Edges))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "references", Count: uint32(len(info.References))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "boxes", Count: uint32(len(info.Boxes))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "properties", Count: uint32(len(info.Properties))})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "examines", Count: uint32(len(info.Examines))})
+		if len(info.Archived) > 0 {
+			out.List = append(out.List, &pb.StatisticInfo{Key: "archived", Count: 1})
+		}
+		if info.Referenced() {
+			out.Count = 1
+		}
+		out.Key = in.Key
+		out.Owner = in.Value
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(info)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "integrity" {
+		// 只检查不修复，修复通过AdminService.FixIntegrity，value为last时返回上一次的结果，key为错误类型，msg为检查报告
+		var report *cache.IntegrityReport
+		var err error
+		if in.Value == "last" {
+			report = cache.Context().GetLastIntegrity()
+			if report == nil {
+				out.Status = outError(path, "not found the integrity report", pbstaus.ResultStatus_NotExisted)
+				return nil
+			}
+		} else {
+			report, err = cache.Context().CheckIntegrity(false)
+			if err != nil {
+				out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+				return nil
+			}
+		}
+		out.List = switchIntegrityCounts(report)
+		out.Count = uint32(len(report.Issues))
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(report)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "trash" {
+		// 兼容旧的调用，与TrashService.GetList一致
+		return new(TrashService).GetList(ctx, in, out)
+	} else if in.Key == "import_jobs" {
+		// parent为所属单位，number为数量，key为 任务UID:状态:导入类型，count为处理的条目数
+		num := int64(in.Number)
+		if num < 1 {
+			num = 20
+		}
+		list := cache.Context().GetImportJobs(in.Parent, num)
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for _, job := range list {
+			key := fmt.Sprintf("%s:%d:%s", job.UID.Hex(), job.Status, job.Kind)
+			out.List = append(out.List, &pb.StatisticInfo{Key: key, Count: uint32(len(job.Items))})
+		}
+		out.Count = uint32(len(list))
+		out.Key = in.Key
+		out.Owner = in.Parent
+		out.Status = outLog(path, out)
+		return nil
 	}
 	out.Owner = in.Value
 	out.Key = in.Key
@@ -621,7 +858,7 @@ func (mine *EntityService) UpdateTags(ctx context.Context, in *pb.RequestList, o
 	}
 	err := info.UpdateTags(in.List, in.Operator)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Uid = info.UID
@@ -655,7 +892,7 @@ func (mine *EntityService) UpdateProperties(ctx context.Context, in *pb.ReqEntit
 	}
 	err := info.UpdateProperties(list, in.Operator)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Uid = info.UID
@@ -688,7 +925,7 @@ func (mine *EntityService) UpdateBase(ctx context.Context, in *pb.ReqEntityBase,
 	}
 	err := info.UpdateBase(in.Name, in.Desc, in.Add, in.Concept, in.Cover, in.Mark, in.Quote, in.Summary, in.Operator)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Updated = uint64(info.Updated)
@@ -716,7 +953,7 @@ func (mine *EntityService) UpdateCover(ctx context.Context, in *pb.RequestInfo,
 	}
 
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Uid = in.Uid
@@ -739,7 +976,7 @@ func (mine *EntityService) UpdateStatus(ctx context.Context, in *pb.ReqEntitySta
 	}
 	err := info.UpdateStatus(cache.EntityStatus(in.Status), in.Operator, in.Remark)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Uid = in.Uid
@@ -763,7 +1000,7 @@ func (mine *EntityService) UpdateSynonyms(ctx context.Context, in *pb.RequestLis
 	}
 	err := info.UpdateSynonyms(in.List, in.Operator)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Uid = info.UID
@@ -795,7 +1032,7 @@ func (mine *EntityService) AppendProperty(ctx context.Context, in *pb.ReqEntityP
 	}
 	err := info.AddProperty(in.Property.Uid, words)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Properties = make([]*pb.PropertyInfo, 0, len(info.Properties))
@@ -821,7 +1058,7 @@ func (mine *EntityService) SubtractProperty(ctx context.Context, in *pb.RequestI
 	}
 	err := info.RemoveProperty(in.Key)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Properties = make([]*pb.PropertyInfo, 0, len(info.Properties))
@@ -895,7 +1132,7 @@ func (mine *EntityService) UpdateStatic(ctx context.Context, in *pb.ReqEntitySta
 	}
 	err := entity.UpdateStatic(info, in.Relations)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Updated = uint64(info.Updated)
@@ -923,7 +1160,7 @@ func (mine *EntityService) UpdateRelations(ctx context.Context, in *pb.ReqEntity
 
 	err := entity.UpdateStaticRelations(in.Operator, in.Relations)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Updated = uint64(entity.Updated)
@@ -950,7 +1187,7 @@ func (mine *EntityService) UpdateEvents(ctx context.Context, in *pb.ReqEntityEve
 
 	err := entity.UpdateStaticEvents(in.Operator, events)
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Updated = uint64(entity.Updated)
@@ -965,6 +1202,24 @@ func (mine *EntityService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdateF
 		out.Status = outError(path, "the uid is empty", pbstaus.ResultStatus_Empty)
 		return nil
 	}
+	if in.Key == "import_rollback" {
+		// uid为导入任务
+		if len(in.Operator) < 1 {
+			out.Status = outError(path, "the operator is empty", pbstaus.ResultStatus_Empty)
+			return nil
+		}
+		err := cache.Context().RollbackImportJob(in.Uid, in.Operator)
+		if err != nil {
+			out.Status = outEntityError(path, err)
+			return nil
+		}
+		out.Status = outLog(path, out)
+		return nil
+	}
+	if in.Key == "trash_restore" {
+		// 兼容旧的调用，与TrashService.Restore一致
+		return new(TrashService).Restore(ctx, in, out)
+	}
 	entity := cache.Context().GetEntity(in.Uid)
 	if entity == nil {
 		out.Status = outError(path, "not found the entity", pbstaus.ResultStatus_NotExisted)
@@ -984,6 +1239,15 @@ func (mine *EntityService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdateF
 		err = entity.UpdateThumb(in.Value, in.Operator)
 	} else if in.Key == "mark" {
 		err = entity.UpdateMark(in.Value, in.Operator)
+	} else if in.Key == "revision_restore" {
+		err = entity.RestoreRevision(cache.StringToUint32(in.Value), in.Operator)
+	} else if in.Key == "archived_rollback" {
+		archived := cache.Context().GetArchivedByEntity(entity.UID)
+		if archived == nil {
+			err = errors.New("not found the archived of entity")
+		} else {
+			err = archived.Rollback(cache.StringToUint32(in.Value), in.Operator)
+		}
 	} else if in.Key == "quote" {
 		err = entity.UpdateQuote(in.Value, in.Operator)
 	} else if in.Key == "property" {
@@ -1002,7 +1266,7 @@ func (mine *EntityService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdateF
 		err = entity.UpdateScore(uint32(score)+entity.Score, in.Operator)
 	}
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Updated = uint64(entity.Updated)
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
 	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
@@ -20,6 +21,8 @@ func switchExamine(info *cache.ExamineInfo) *pb.ExamineInfo {
 	tmp.Id = info.Data.ID
 	tmp.Target = info.Data.Target
 	tmp.Value = info.Data.Value
+	tmp.Creator = info.Data.Creator
+	tmp.Operator = info.Data.Operator
 	tmp.Status = uint32(info.Data.Status)
 	tmp.Type = uint32(info.Data.Kind)
 	return tmp
@@ -28,17 +31,13 @@ func switchExamine(info *cache.ExamineInfo) *pb.ExamineInfo {
 func (mine *ExamineService) AddOne(ctx context.Context, in *pb.ReqExamineAdd, out *pb.ReplyExamineInfo) error {
 	path := "examine.addOne"
 	inLog(path, in)
-	var info *cache.ExamineInfo
-	var err error
-	info = cache.Context().GetIdleExamineByTarget(in.Target, in.Key, cache.ExamineType(in.Type))
-	if info != nil {
-		err = info.UpdateValue(in.Value, in.Operator)
-	} else {
-		info, err = cache.Context().CreateExamine(in.Operator, in.Target, in.Key, in.Value, uint8(in.Type))
+	if len(in.Target) < 1 || len(in.Operator) < 1 {
+		out.Status = outError(path, "the target or operator is empty", pbstaus.ResultStatus_Empty)
+		return nil
 	}
-
+	info, err := cache.Context().SubmitExamine(in.Operator, in.Target, in.Key, in.Value, uint8(in.Type))
 	if err != nil {
-		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
 		return nil
 	}
 	out.Info = switchExamine(info)
@@ -79,6 +78,50 @@ func (mine *ExamineService) GetStatistic(ctx context.Context, in *pb.RequestFilt
 			return nil
 		}
 		out.Count = cache.Context().GetExamineCountByStatus(in.Parent, uint8(tp))
+	} else if in.Key == "review_reviewer" {
+		// 审核人的待办，value为审核人
+		info, er := cache.Context().GetReviewerDashboard(in.Value)
+		if er != nil {
+			out.Status = outError(path, er.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.Count = uint32(len(info.Items))
+		out.Status = outLog(path, out)
+		bts, _ := json.Marshal(info)
+		out.Status.Msg = string(bts)
+		return nil
+	} else if in.Key == "review_box" {
+		// 收藏夹的审核进度，parent为收藏夹
+		info, er := cache.Context().GetBoxReviewDashboard(in.Parent)
+		if er != nil {
+			out.Status = outError(path, er.Error(), pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		out.Count = uint32(len(info.Items))
+		out.Status = outLog(path, out)
+		bts, _ := json.Marshal(info)
+		out.Status.Msg = string(bts)
+		return nil
+	} else if in.Key == "preview" {
+		// 对比实体当前的值和建议的值，value或者values为审核的uid
+		uids := in.Values
+		if len(in.Value) > 0 {
+			uids = append([]string{in.Value}, uids...)
+		}
+		list := make([]*cache.ExaminePreview, 0, len(uids))
+		for _, uid := range uids {
+			preview, er := cache.Context().GetExaminePreview(uid)
+			if er != nil {
+				out.Status = outError(path, er.Error(), pbstaus.ResultStatus_NotExisted)
+				return nil
+			}
+			list = append(list, preview)
+		}
+		out.Count = uint32(len(list))
+		out.Status = outLog(path, out)
+		bts, _ := json.Marshal(list)
+		out.Status.Msg = string(bts)
+		return nil
 	}
 	out.Status = outLog(path, out)
 	return nil
@@ -91,7 +134,16 @@ func (mine *ExamineService) RemoveOne(ctx context.Context, in *pb.RequestInfo, o
 		out.Status = outError(path, "the examine uid is empty", pbstaus.ResultStatus_Empty)
 		return nil
 	}
-
+	info := cache.Context().GetExamine(in.Uid)
+	if info == nil {
+		out.Status = outError(path, "not found the examine by uid", pbstaus.ResultStatus_NotExisted)
+		return nil
+	}
+	err := cache.Context().RemoveExamine(in.Uid, in.Operator)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
 	out.Uid = in.Uid
 	out.Status = outLog(path, out)
 	return nil
@@ -117,6 +169,29 @@ func (mine *ExamineService) GetListByFilter(ctx context.Context, in *pb.RequestF
 			return nil
 		}
 		list = cache.Context().GetExaminesByStatus(in.Parent, uint8(tp))
+	} else if in.Key == "key" {
+		// 同一个数据互相竞争的建议，parent为实体，value为数据的key，values[0]为类型
+		tp := 0
+		if len(in.Values) > 0 {
+			tp, _ = strconv.Atoi(in.Values[0])
+		}
+		list = cache.Context().GetIdleExaminesByKey(in.Parent, in.Value, cache.ExamineType(tp))
+	} else if in.Key == "queue" {
+		// 分配给审核人的修改建议，value为审核人
+		info, er := cache.Context().GetReviewerDashboard(in.Value)
+		if er != nil {
+			out.Status = outError(path, er.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		list = make([]*cache.ExamineInfo, 0, len(info.Items))
+		for _, item := range info.Items {
+			if item.Kind != cache.ReviewKindExamine {
+				continue
+			}
+			if tmp := cache.Context().GetExamine(item.Target); tmp != nil {
+				list = append(list, tmp)
+			}
+		}
 	} else if in.Key == "scene" {
 
 	}
@@ -132,6 +207,32 @@ func (mine *ExamineService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdate
 	path := "examine.updateByFilter"
 	inLog(path, in)
 	var err error
+	if in.Key == "review_claim" || in.Key == "review_release" {
+		// uid为审核任务
+		review := cache.Context().GetReview(in.Uid)
+		if review == nil {
+			out.Status = outError(path, "not found the review by uid", pbstaus.ResultStatus_NotExisted)
+			return nil
+		}
+		if in.Key == "review_claim" {
+			err = review.Claim(in.Operator)
+		} else {
+			err = review.Release(in.Operator)
+		}
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_Prohibition)
+			return nil
+		}
+		if review.Kind == cache.ReviewKindExamine {
+			if info := cache.Context().GetExamine(review.Target); info != nil {
+				out.Info = switchExamine(info)
+			}
+		}
+		out.Status = outLog(path, out)
+		bts, _ := json.Marshal(review)
+		out.Status.Msg = string(bts)
+		return nil
+	}
 	if len(in.Uid) < 1 {
 		if in.Key == "activity" {
 			events := cache.Context().GetEventsByQuote(in.Value)
@@ -139,7 +240,7 @@ func (mine *ExamineService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdate
 				arr := cache.Context().GetIdleExaminesByValue(event.UID, cache.ExamineTypeEvent)
 				if len(arr) > 0 {
 					for _, ex := range arr {
-						_ = ex.UpdateStatus(cache.ExamineStatusFree, in.Operator)
+						_ = ex.UpdateStatus(cache.ExamineStatusFree, in.Operator, "")
 					}
 				} else {
 					_ = event.UpdateAccess(in.Operator, cache.AccessPublic)
@@ -149,7 +250,7 @@ func (mine *ExamineService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdate
 			arr := cache.Context().GetIdleExaminesByValue(in.Value, cache.ExamineTypeEvent)
 			if len(arr) > 0 {
 				for _, ex := range arr {
-					_ = ex.UpdateStatus(cache.ExamineStatusFree, in.Operator)
+					_ = ex.UpdateStatus(cache.ExamineStatusFree, in.Operator, "")
 				}
 			} else {
 				event := cache.Context().GetEvent(in.Value)
@@ -157,7 +258,21 @@ func (mine *ExamineService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdate
 					_ = event.UpdateAccess(in.Operator, cache.AccessPublic)
 				}
 			}
-		} else {
+		} else if in.Key == "agree" || in.Key == "refuse" {
+			// 批量审核，values为审核的uid，value为审核意见，拒绝时必须填写
+			st := uint8(cache.ExamineStatusFree)
+			if in.Key == "refuse" {
+				st = cache.ExamineStatusRefuse
+				if len(in.Value) < 1 {
+					out.Status = outError(path, "the remark is empty when refuse", pbstaus.ResultStatus_Empty)
+					return nil
+				}
+			}
+			failed := cache.Context().UpdateExaminesStatus(in.Values, st, in.Operator, in.Value)
+			out.Status = outLog(path, failed)
+			bts, _ := json.Marshal(failed)
+			out.Status.Msg = string(bts)
+			return nil
 		}
 	} else {
 		info := cache.Context().GetExamine(in.Uid)
@@ -166,17 +281,30 @@ func (mine *ExamineService) UpdateByFilter(ctx context.Context, in *pb.ReqUpdate
 			return nil
 		}
 		if in.Key == "status" {
+			// 只能审核通过或者拒绝，撤回需要使用withdraw
 			st, er := strconv.Atoi(in.Value)
 			if er != nil {
 				out.Status = outLog(path, er.Error())
 				return nil
 			}
-			err = info.UpdateStatus(uint8(st), in.Operator)
+			if st != cache.ExamineStatusFree && st != cache.ExamineStatusRefuse {
+				out.Status = outError(path, "the examine status must be free or refuse", pbstaus.ResultStatus_FormatError)
+				return nil
+			}
+			remark := ""
+			if len(in.Values) > 0 {
+				remark = in.Values[0]
+			}
+			err = info.UpdateStatus(uint8(st), in.Operator, remark)
+		} else if in.Key == "withdraw" {
+			err = info.Withdraw(in.Operator)
+		} else if in.Key == "value" {
+			err = info.UpdateValue(in.Value, in.Operator)
 		}
 		out.Info = switchExamine(info)
 	}
 	if err != nil {
-		out.Status = outLog(path, err.Error())
+		out.Status = outEntityError(path, err)
 		return nil
 	}
 	out.Status = outLog(path, out)
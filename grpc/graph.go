@@ -2,9 +2,15 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
 	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"math"
+	"net/url"
 	"omo.msa.vocabulary/cache"
+	"strconv"
+	"strings"
 )
 
 type GraphService struct{}
@@ -127,7 +133,77 @@ func (mine *GraphService) GetLink(ctx context.Context, in *pb.RequestInfo, out *
 func (mine *GraphService) GetStatistic(ctx context.Context, in *pb.RequestFilter, out *pb.ReplyStatistic) error {
 	path := "graph.getStatistic"
 	inLog(path, in)
-	out.Status = outError(path, "param is empty", pbstaus.ResultStatus_Empty)
+	if in.Key == "sync" {
+		info := cache.Context().GetGraphTaskStatistic()
+		out.Count = info.Pending
+		out.List = make([]*pb.StatisticInfo, 0, 3)
+		out.List = append(out.List, &pb.StatisticInfo{Key: "pending", Count: info.Pending})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "retry", Count: info.Retry})
+		out.List = append(out.List, &pb.StatisticInfo{Key: "dead", Count: info.Dead})
+		// 最近失败的任务以JSON数组放在状态的msg中，包括任务的目标和最后一次失败的原因
+		out.Key = in.Key
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(info.Failures)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if in.Key == "reconcile" {
+		// 只返回差异，修改图数据库需要调用AdminService.ReconcileGraph
+		report, err := cache.Context().ReconcileGraph(true)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.Count = report.Changes()
+		out.List = switchReconcileReport(report)
+	} else if in.Key == "export" {
+		// value为导出格式，values[0]为关系图类型（center、owner、path），parent为中心实体、所属单位或者路径起点；
+		// 返回的key为建议的文件名，导出的内容放在状态的msg中，服务端不保存文件
+		name, content, count, err := exportGraph(in.Parent, in.Value, in.Values)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.Key = in.Key
+		out.Count = count
+		out.List = make([]*pb.StatisticInfo, 0, 1)
+		out.List = append(out.List, &pb.StatisticInfo{Key: name, Count: count})
+		out.Status = outLog(path, out)
+		out.Status.Msg = string(content)
+		return nil
+	} else if in.Key == "paths" {
+		// parent和value为起点和终点，number为路径数量，brief为真时按照关系的权重计算距离，
+		// values[0]为允许的关系类型，values[1]为不能经过的实体，都用逗号分隔；
+		// 返回的key为路径的序号，count为路径中关系的数量，路径的关系图以JSON数组放在状态的msg中
+		list, err := findPaths(in)
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.Key = in.Key
+		out.Count = uint32(len(list))
+		out.List = make([]*pb.StatisticInfo, 0, len(list))
+		for i, item := range list {
+			out.List = append(out.List, &pb.StatisticInfo{Key: strconv.Itoa(i), Count: uint32(len(item.Graph.Links))})
+		}
+		out.Status = outLog(path, out)
+		bytes, _ := json.Marshal(list)
+		out.Status.Msg = string(bytes)
+		return nil
+	} else if isAnalyticsKey(in.Key) {
+		// parent为场景，为空时统计全部实体；value为refresh时在后台重新计算，计算完成前返回之前的结果
+		info, err := cache.Context().GetGraphAnalytics(in.Parent, in.Value == "refresh")
+		if err != nil {
+			out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+			return nil
+		}
+		out.Owner = in.Parent
+		out.Count, out.List = switchAnalytics(info, in.Key, in.Values, int(in.Number))
+	} else {
+		out.Status = outError(path, "not defined the key of statistic", pbstaus.ResultStatus_Empty)
+		return nil
+	}
+	out.Key = in.Key
+	out.Status = outLog(path, out)
 	return nil
 }
 
@@ -183,7 +259,14 @@ func (mine *GraphService) FindGraph(ctx context.Context, in *pb.RequestInfo, out
 		out.Status = outError(path, "the node uid is empty", pbstaus.ResultStatus_Empty)
 		return nil
 	}
-	graph, err := cache.Context().Graph().GetGraphByCenter(in.Uid)
+	var graph *cache.GraphInfo
+	var err error
+	if len(in.Key) > 0 {
+		// key为查询参数，例如：depth=2&relations=uid1,uid2&concepts=1,4&limit=100
+		graph, err = findNeighborhood(in.Uid, in.Key)
+	} else {
+		graph, err = cache.Context().Graph().GetGraphByCenter(in.Uid)
+	}
 	if err != nil {
 		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
 	} else {
@@ -205,3 +288,173 @@ func (mine *GraphService) FindNodes(ctx context.Context, in *pb.RequestInfo, out
 	out.Status = outLog(path, out)
 	return nil
 }
+
+func splitQuery(values url.Values, key string) []string {
+	return splitList(values.Get(key))
+}
+
+func splitList(value string) []string {
+	list := make([]string, 0, 5)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func findNeighborhood(center, query string) (*cache.GraphInfo, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	depth := 1
+	if values.Get("depth") != "" {
+		depth, err = strconv.Atoi(values.Get("depth"))
+		if err != nil {
+			return nil, errors.New("the depth is not a number")
+		}
+	}
+	limit := 0
+	if values.Get("limit") != "" {
+		limit, err = strconv.Atoi(values.Get("limit"))
+		if err != nil {
+			return nil, errors.New("the limit is not a number")
+		}
+	}
+	concepts := make([]uint8, 0, 5)
+	for _, item := range splitQuery(values, "concepts") {
+		tp, er := strconv.ParseUint(item, 10, 8)
+		if er != nil {
+			return nil, errors.New("the concept type is not a number")
+		}
+		concepts = append(concepts, uint8(tp))
+	}
+	return cache.Context().Graph().GetNeighborhood(center, depth, splitQuery(values, "relations"), concepts, limit)
+}
+
+type pathGraph struct {
+	Cost  float64       `json:"cost"`
+	Graph *pb.GraphInfo `json:"graph"`
+}
+
+/**
+查询两个实体之间距离最短的多条路径，每条路径是一个独立的关系图，按照距离从近到远排列，
+values[0]为允许的关系类型，values[1]为不能经过的实体，都用逗号分隔
+*/
+func findPaths(in *pb.RequestFilter) ([]*pathGraph, error) {
+	if len(in.Parent) < 1 || len(in.Value) < 1 {
+		return nil, errors.New("the from or to entity is empty")
+	}
+	count := int(in.Number)
+	if count < 1 {
+		count = 1
+	}
+	relations := make([]string, 0, 1)
+	excludes := make([]string, 0, 1)
+	if len(in.Values) > 0 {
+		relations = splitList(in.Values[0])
+	}
+	if len(in.Values) > 1 {
+		excludes = splitList(in.Values[1])
+	}
+	paths, err := cache.Context().Graph().GetPaths(in.Parent, in.Value, in.Brief, relations, excludes, count)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*pathGraph, 0, len(paths))
+	for _, item := range paths {
+		list = append(list, &pathGraph{Cost: item.Cost, Graph: switchGraph(item.Graph)})
+	}
+	return list, nil
+}
+
+func isAnalyticsKey(key string) bool {
+	switch key {
+	case cache.AnalyticsDegree, cache.AnalyticsPageRank, cache.AnalyticsBetweenness,
+		cache.AnalyticsComponents, cache.AnalyticsCommunities:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+排名按照分值从高到低返回前num个实体，pagerank和betweenness的分值放大一百万倍；
+分组返回每个分组的第一个实体和分组数量，values不为空时只返回这些实体所在分组的成员
+*/
+func switchAnalytics(info *cache.GraphAnalytics, key string, values []string, num int) (uint32, []*pb.StatisticInfo) {
+	if num < 1 {
+		num = 10
+	}
+	var scores map[string]float64
+	var groups [][]string
+	scale := 1.0
+	switch key {
+	case cache.AnalyticsDegree:
+		scores = info.DegreeScores()
+	case cache.AnalyticsPageRank:
+		scores = info.PageRank
+		scale = 1000000
+	case cache.AnalyticsBetweenness:
+		scores = info.Betweenness
+		scale = 1000000
+	case cache.AnalyticsComponents:
+		groups = info.Components
+	case cache.AnalyticsCommunities:
+		groups = info.Communities
+	}
+	list := make([]*pb.StatisticInfo, 0, num)
+	if scores != nil {
+		for _, pair := range info.Top(scores, num) {
+			list = append(list, &pb.StatisticInfo{Key: pair.Entity, Count: uint32(math.Round(pair.Score * scale))})
+		}
+		return uint32(len(info.Nodes)), list
+	}
+	if len(values) > 0 {
+		for _, uid := range values {
+			for _, item := range info.GroupOf(groups, uid) {
+				list = append(list, &pb.StatisticInfo{Key: item, Count: info.Degree[item]})
+			}
+		}
+		return uint32(len(list)), list
+	}
+	for i := 0; i < len(groups) && i < num; i += 1 {
+		list = append(list, &pb.StatisticInfo{Key: groups[i][0], Count: uint32(len(groups[i]))})
+	}
+	return uint32(len(groups)), list
+}
+
+func exportGraph(parent, format string, values []string) (string, []byte, uint32, error) {
+	if len(parent) < 1 {
+		return "", nil, 0, errors.New("the parent is empty")
+	}
+	kind := "center"
+	if len(values) > 0 {
+		kind = values[0]
+	}
+	var graph *cache.GraphInfo
+	var err error
+	switch kind {
+	case "center":
+		graph, err = cache.Context().Graph().GetGraphByCenter(parent)
+	case "owner":
+		graph = cache.Context().Graph().GetOwnerGraph(parent)
+	case "path":
+		if len(values) < 2 {
+			return "", nil, 0, errors.New("the to node of path is empty")
+		}
+		graph, err = cache.Context().Graph().GetPath(parent, values[1])
+	default:
+		return "", nil, 0, errors.New("not support the graph kind of " + kind)
+	}
+	if err != nil {
+		return "", nil, 0, err
+	}
+	name, content, err := cache.Context().ExportGraph(graph, format)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return name, content, uint32(len(graph.Nodes())), nil
+}
@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"net/url"
+	"omo.msa.vocabulary/cache"
+)
+
+/**
+导入服务，写入数据的导入必须有操作者，proto中没有定义，通过反射注册，接口为ImportService.Commit
+*/
+type ImportService struct{}
+
+/**
+校验通过后写入数据库，value为导入类型，owner为所属单位，values为文件格式、base64编码的文件内容以及可选的匹配选项
+(match=mark|name|external&key=外部ID的属性&policy=update|skip)
+*/
+func (mine *ImportService) Commit(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "import.commit"
+	inLog(path, in)
+	if len(in.Operator) < 1 {
+		out.Status = outError(path, "the operator is empty", pbstaus.ResultStatus_Empty)
+		return nil
+	}
+	body, option, err := parseImportValues(in.Values)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
+		return nil
+	}
+	report, err := cache.Context().ImportData(in.Value, in.Values[0], in.Owner, in.Operator, body, option, true)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
+		return nil
+	}
+	switchImportReport(report, out)
+	out.Key = in.Key
+	out.Owner = in.Owner
+	out.Status = outLog(path, out)
+	return nil
+}
+
+func parseImportValues(values []string) ([]byte, *cache.ImportOption, error) {
+	if len(values) < 2 {
+		return nil, nil, errors.New("the import file is empty")
+	}
+	body, err := base64.StdEncoding.DecodeString(values[1])
+	if err != nil {
+		return nil, nil, errors.New("the import file is not base64")
+	}
+	option := new(cache.ImportOption)
+	if len(values) > 2 {
+		query, er := url.ParseQuery(values[2])
+		if er != nil {
+			return nil, nil, errors.New("the import option is invalid")
+		}
+		option.Match = query.Get("match")
+		option.Key = query.Get("key")
+		option.Policy = query.Get("policy")
+	}
+	return body, option, nil
+}
+
+func switchImportReport(report *cache.ImportReport, out *pb.ReplyStatistic) {
+	out.Count = report.Created + report.Updated
+	out.List = make([]*pb.StatisticInfo, 0, len(report.Issues)+6)
+	out.List = append(out.List, &pb.StatisticInfo{Key: "total", Count: report.Total})
+	out.List = append(out.List, &pb.StatisticInfo{Key: "valid", Count: report.Valid})
+	out.List = append(out.List, &pb.StatisticInfo{Key: "created", Count: report.Created})
+	out.List = append(out.List, &pb.StatisticInfo{Key: "updated", Count: report.Updated})
+	out.List = append(out.List, &pb.StatisticInfo{Key: "skipped", Count: report.Skipped})
+	if len(report.Job) > 0 {
+		out.List = append(out.List, &pb.StatisticInfo{Key: "job:" + report.Job, Count: report.Created + report.Updated})
+	}
+	for _, issue := range report.Issues {
+		// 问题的key为 行号:字段:错误
+		key := fmt.Sprintf("%d:%s:%s", issue.Row, issue.Field, issue.Error)
+		out.List = append(out.List, &pb.StatisticInfo{Key: key, Count: uint32(issue.Row)})
+	}
+}
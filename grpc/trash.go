@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	pbstaus "github.com/xtech-cloud/omo-msp-status/proto/status"
+	pb "github.com/xtech-cloud/omo-msp-vocabulary/proto/vocabulary"
+	"omo.msa.vocabulary/cache"
+)
+
+/**
+回收站服务，proto中没有定义，复用已有的消息并通过反射注册，接口为TrashService.GetList等
+*/
+type TrashService struct{}
+
+/**
+value为数据类型(entity|event|concept|box)，parent为场景，number为数量，key为 UID:操作者:删除时间，msg为列表
+*/
+func (mine *TrashService) GetList(ctx context.Context, in *pb.RequestFilter, out *pb.ReplyStatistic) error {
+	path := "trash.getList"
+	inLog(path, in)
+	num := int64(in.Number)
+	if num < 1 {
+		num = 50
+	}
+	list, err := cache.Context().GetTrash(in.Value, in.Parent, num)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_FormatError)
+		return nil
+	}
+	out.List = make([]*pb.StatisticInfo, 0, len(list))
+	for _, item := range list {
+		key := fmt.Sprintf("%s:%s:%d", item.UID, item.Operator, item.Deleted)
+		out.List = append(out.List, &pb.StatisticInfo{Key: key})
+	}
+	out.Count = uint32(len(list))
+	out.Key = in.Key
+	out.Owner = in.Parent
+	out.Status = outLog(path, out)
+	bytes, _ := json.Marshal(list)
+	out.Status.Msg = string(bytes)
+	return nil
+}
+
+/**
+uid为回收站中的数据，value为数据类型(entity|event|concept|box)
+*/
+func (mine *TrashService) Restore(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyInfo) error {
+	path := "trash.restore"
+	inLog(path, in)
+	if len(in.Uid) < 1 || len(in.Operator) < 1 {
+		out.Status = outError(path, "the uid or operator is empty", pbstaus.ResultStatus_Empty)
+		return nil
+	}
+	err := cache.Context().RestoreTrash(in.Value, in.Uid, in.Operator)
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_NotExisted)
+		return nil
+	}
+	out.Uid = in.Uid
+	out.Status = outLog(path, out)
+	return nil
+}
+
+/**
+彻底删除超过保留天数的数据，只有管理员可以执行，count为删除的数量
+*/
+func (mine *TrashService) Purge(ctx context.Context, in *pb.ReqUpdateFilter, out *pb.ReplyStatistic) error {
+	path := "trash.purge"
+	inLog(path, in)
+	if st := checkAdmin(path, in.Operator); st != nil {
+		out.Status = st
+		return nil
+	}
+	count, err := cache.Context().PurgeTrash()
+	if err != nil {
+		out.Status = outError(path, err.Error(), pbstaus.ResultStatus_DBException)
+		return nil
+	}
+	out.Count = count
+	out.Key = in.Key
+	out.Status = outLog(path, out)
+	return nil
+}
@@ -50,8 +50,12 @@ func main() {
 	_ = proto.RegisterBoxServiceHandler(service.Server(), new(grpc.BoxService))
 	_ = proto.RegisterVEdgeServiceHandler(service.Server(), new(grpc.VEdgeService))
 	_ = proto.RegisterExamineServiceHandler(service.Server(), new(grpc.ExamineService))
+	// 回收站、导入和管理服务没有proto定义，按照反射注册
+	_ = service.Server().Handle(service.Server().NewHandler(new(grpc.TrashService)))
+	_ = service.Server().Handle(service.Server().NewHandler(new(grpc.ImportService)))
+	_ = service.Server().Handle(service.Server().NewHandler(new(grpc.AdminService)))
 
-	//checkTimer()
+	checkTimer()
 	go delayCall()
 
 	app, _ := filepath.Abs(os.Args[0])
@@ -74,16 +78,26 @@ func main() {
 func checkTimer() {
 	c := cron.New()
 	_ = c.AddFunc("*/3 * * * * ?", func() {
-		cache.Context().CheckSyncNodes()
-		cache.Context().CheckSyncLinks()
+		cache.Context().CheckGraphTasks()
+	})
+	_ = c.AddFunc("0 0 3 * * ?", func() {
+		_, _ = cache.Context().PurgeTrash()
+	})
+	_ = c.AddFunc("0 30 3 * * ?", func() {
+		_, _ = cache.Context().CheckIntegrity(true)
+	})
+	_ = c.AddFunc("0 */1 * * * ?", func() {
+		_, _ = cache.Context().SyncReviews()
 	})
 	c.Start()
 }
 
 func delayCall() {
 	time.Sleep(5 * time.Second)
-	//cache.CheckBoxes()
-	cache.CheckConcepts()
+	_, err := cache.Context().RunMigrations()
+	if err != nil {
+		logger.Warn("run the migrations failed that " + err.Error())
+	}
 	//cache.DebugGraph()
 }
 
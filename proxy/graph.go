@@ -54,3 +54,31 @@ func (mine *Graph) AddLink(info *Link) {
 	}
 	mine.Links = append(mine.Links, info)
 }
+
+/**
+路径查询的条件，Weighted为真时按照关系的权重计算距离，权重越高（越亲密）距离越短
+*/
+type PathOption struct {
+	Weighted  bool
+	Relations []string //允许的关系分类，为空时不限制
+	Excludes  []string //路径中不能经过的实体UID
+	Count     int      //返回的路径数量
+}
+
+/**
+一条路径以及路径的总距离
+*/
+type Path struct {
+	Graph
+	Cost float64
+}
+
+/**
+关系的距离，没有设置权重的关系距离为1
+*/
+func linkCost(link *Link, weighted bool) float64 {
+	if weighted && link.Weight > 0 {
+		return 1.0 / float64(link.Weight)
+	}
+	return 1.0
+}
@@ -5,15 +5,68 @@ import (
 	"fmt"
 	"github.com/neo4j/neo4j-go-driver/neo4j"
 	"omo.msa.vocabulary/config"
+	"regexp"
+	"sync"
 )
 
+const PathMaxDepth = 6 //查询多条路径时路径的最大长度
+
 type Neo4JContext struct {
 	driver  neo4j.Driver
 	session neo4j.Session
 }
 
+/**
+节点标签和关系类型不能作为参数传递，只允许白名单内的值拼接到查询语句中
+*/
+type whitelist struct {
+	lock   sync.RWMutex
+	labels map[string]bool
+	kinds  map[string]bool
+}
+
 var neo4jCtx *Neo4JContext
 
+var allowed = &whitelist{labels: make(map[string]bool, 20), kinds: make(map[string]bool, 5)}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func SetLabels(list []string) {
+	allowed.lock.Lock()
+	defer allowed.lock.Unlock()
+	allowed.labels = make(map[string]bool, len(list))
+	for _, item := range list {
+		allowed.labels[item] = true
+	}
+}
+
+func SetKinds(list []string) {
+	allowed.lock.Lock()
+	defer allowed.lock.Unlock()
+	allowed.kinds = make(map[string]bool, len(list))
+	for _, item := range list {
+		allowed.kinds[item] = true
+	}
+}
+
+func checkLabel(label string) error {
+	allowed.lock.RLock()
+	defer allowed.lock.RUnlock()
+	if !identifier.MatchString(label) || !allowed.labels[label] {
+		return errors.New("the node label is not allowed of " + label)
+	}
+	return nil
+}
+
+func checkKind(kind string) error {
+	allowed.lock.RLock()
+	defer allowed.lock.RUnlock()
+	if !identifier.MatchString(kind) || !allowed.kinds[kind] {
+		return errors.New("the link kind is not allowed of " + kind)
+	}
+	return nil
+}
+
 func InitNeo4J(config *config.GraphConfig) error {
 	neo4jCtx = new(Neo4JContext)
 	// 创建neo4j驱动
@@ -37,6 +90,9 @@ func CreateNode(name, label, uid string) (neo4j.Node, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
+	if err := checkLabel(label); err != nil {
+		return nil, err
+	}
 	cypher := fmt.Sprintf("CREATE (n:%s{name:$name, uid: $uid}) RETURN n", label)
 	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"name": name, "uid": uid})
 	if err != nil {
@@ -58,8 +114,11 @@ func CreateNodeLabel(id int64, label string) (neo4j.Node, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("CREATE (a:%s) WHERE id(a) = %d RETURN a", label, id)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	if err := checkLabel(label); err != nil {
+		return nil, err
+	}
+	cypher := fmt.Sprintf("MATCH (a) WHERE id(a) = $id SET a:%s RETURN a", label)
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -75,12 +134,32 @@ func CreateNodeLabel(id int64, label string) (neo4j.Node, error) {
 	return nil, result.Err()
 }
 
+func UpdateNode(id int64, name, cover string) (neo4j.Node, error) {
+	if neo4jCtx.session == nil {
+		return nil, errors.New("the graph session is nil that init first")
+	}
+	cypher := "MATCH (a) WHERE id(a)=$id SET a.name=$name, a.cover=$cover RETURN a"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id, "name": name, "cover": cover})
+	if err != nil {
+		return nil, err
+	}
+	for result.Next() {
+		node, ok := result.Record().GetByIndex(0).(neo4j.Node)
+		if ok {
+			return node, nil
+		} else {
+			return nil, errors.New("node update failed by unknown error")
+		}
+	}
+	return nil, result.Err()
+}
+
 func GetNode(uid string) (neo4j.Node, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a) WHERE a.uid = '%s' RETURN a", uid)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a) WHERE a.uid = $uid RETURN a"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"uid": uid})
 	if err != nil {
 		return nil, err
 	}
@@ -100,8 +179,8 @@ func GetNodeByID(id int64) (neo4j.Node, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a) WHERE id(a)=%d RETURN a", id)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a) WHERE id(a)=$id RETURN a"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -124,9 +203,13 @@ func CreateLink(from, to int64, kind, name, relation string, direction uint8, we
 	if len(kind) < 1 {
 		return nil, errors.New("the kind is empty")
 	}
-	cypher := fmt.Sprintf("MATCH (a),(b) WHERE id(a)=%d AND id(b)=%d CREATE (a)-[r:%s{name:$name, "+
-		"direction:$direction, relation:$relation}]->(b) RETURN r", from, to, kind)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"name": name, "direction": direction, "relation": relation, "weight": weight})
+	if err := checkKind(kind); err != nil {
+		return nil, err
+	}
+	cypher := fmt.Sprintf("MATCH (a),(b) WHERE id(a)=$from AND id(b)=$to CREATE (a)-[r:%s{name:$name, "+
+		"direction:$direction, relation:$relation, weight:$weight}]->(b) RETURN r", kind)
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"from": from, "to": to, "name": name,
+		"direction": direction, "relation": relation, "weight": weight})
 	if err != nil {
 		return nil, err
 	}
@@ -146,8 +229,8 @@ func GetLinkByID(id int64) (neo4j.Relationship, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a)-[r]-(b) WHERE id(r)=%d RETURN r", id)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a)-[r]-(b) WHERE id(r)=$id RETURN r"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -167,8 +250,8 @@ func GetLink(from, to string) (neo4j.Relationship, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a{uid:'%s'})-[r]-(b{uid:'%s'}) RETURN r", from, to)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a{uid:$from})-[r]-(b{uid:$to}) RETURN r"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"from": from, "to": to})
 	if err != nil {
 		return nil, err
 	}
@@ -188,18 +271,52 @@ func GetLinks(from, to string) ([]neo4j.Relationship, error) {
 	if neo4jCtx.session == nil {
 		return nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a{uid:%s})-[r]-(b{uid:%s}) RETURN r", from, to)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a{uid:$from})-[r]-(b{uid:$to}) RETURN r"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"from": from, "to": to})
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("GetLinks..." + cypher)
 	array := make([]neo4j.Relationship, 0, 3)
 	for result.Next() {
 
 		links := result.Record().Values()
 		for _, value := range links {
-			link := value.(neo4j.Relationship)
+			if link, ok := value.(neo4j.Relationship); ok {
+				array = append(array, link)
+			}
+		}
+	}
+	return array, result.Err()
+}
+
+func GetAllNodes() ([]neo4j.Node, error) {
+	if neo4jCtx.session == nil {
+		return nil, errors.New("the graph session is nil that init first")
+	}
+	result, err := neo4jCtx.session.Run("MATCH (n) RETURN n", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	array := make([]neo4j.Node, 0, 100)
+	for result.Next() {
+		if node, ok := result.Record().GetByIndex(0).(neo4j.Node); ok {
+			array = append(array, node)
+		}
+	}
+	return array, result.Err()
+}
+
+func GetAllLinks() ([]neo4j.Relationship, error) {
+	if neo4jCtx.session == nil {
+		return nil, errors.New("the graph session is nil that init first")
+	}
+	result, err := neo4jCtx.session.Run("MATCH ()-[r]->() RETURN r", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	array := make([]neo4j.Relationship, 0, 100)
+	for result.Next() {
+		if link, ok := result.Record().GetByIndex(0).(neo4j.Relationship); ok {
 			array = append(array, link)
 		}
 	}
@@ -210,8 +327,11 @@ func DeleteNode(id int64, label string) error {
 	if neo4jCtx.session == nil {
 		return errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (n:%s) WHERE id(n)=%d DETACH DELETE n RETURN n", label, id)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+	cypher := fmt.Sprintf("MATCH (n:%s) WHERE id(n)=$id DETACH DELETE n", label)
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id})
 	if err != nil {
 		return err
 	}
@@ -225,8 +345,8 @@ func DeleteLink(id int64) error {
 	if neo4jCtx.session == nil {
 		return errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a)-[r]-(b) WHERE id(r)=%d DELETE r", id)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH (a)-[r]-(b) WHERE id(r)=$id DELETE r"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"id": id})
 	if err != nil {
 		return err
 	}
@@ -240,8 +360,8 @@ func FindPath(from, to string) ([]neo4j.Node, []neo4j.Relationship, error) {
 	if neo4jCtx.session == nil {
 		return nil, nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH p=shortestPath((a{uid:'%s'})-[*..6]-(b{uid:'%s'})) RETURN p", from, to)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	cypher := "MATCH p=shortestPath((a{uid:$from})-[*..6]-(b{uid:$to})) RETURN p"
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"from": from, "to": to})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -263,16 +383,108 @@ func FindPath(from, to string) ([]neo4j.Node, []neo4j.Relationship, error) {
 	return nodes, links, result.Err()
 }
 
+/**
+查询节点在指定深度内的所有路径，按照路径长度排序
+*/
+func FindNeighborhood(uid string, depth int, relations, labels []string, limit int) ([]neo4j.Path, error) {
+	if neo4jCtx.session == nil {
+		return nil, errors.New("the graph session is nil that init first")
+	}
+	if relations == nil {
+		relations = make([]string, 0, 1)
+	}
+	if labels == nil {
+		labels = make([]string, 0, 1)
+	}
+	// 可变长度的深度不能作为参数传递，depth是整数所以可以直接拼接
+	cypher := fmt.Sprintf("MATCH p=(a{uid:$uid})-[rs*1..%d]-(b) "+
+		"WHERE ALL(r IN rs WHERE size($relations) = 0 OR r.relation IN $relations) "+
+		"AND ALL(n IN nodes(p)[1..] WHERE size($labels) = 0 OR ANY(l IN labels(n) WHERE l IN $labels)) "+
+		"RETURN p ORDER BY length(p) LIMIT $rows", depth)
+	rows := limit * 10
+	if rows < 1 {
+		rows = 10000
+	}
+	params := map[string]interface{}{"uid": uid, "relations": relations, "labels": labels, "rows": rows}
+	result, err := neo4jCtx.session.Run(cypher, params)
+	if err != nil {
+		return nil, err
+	}
+	array := make([]neo4j.Path, 0, 20)
+	for result.Next() {
+		if path, ok := result.Record().GetByIndex(0).(neo4j.Path); ok {
+			array = append(array, path)
+		}
+	}
+	return array, result.Err()
+}
+
+/**
+查询两个节点之间距离最短的count条简单路径，weighted为真时按照关系的权重计算距离；
+只使用Cypher枚举长度不超过PathMaxDepth的路径，不依赖图数据科学库，忽略关系的方向
+*/
+func FindPaths(from, to string, weighted bool, relations, excludes []string, count int) ([][]neo4j.Node, [][]neo4j.Relationship, []float64, error) {
+	if neo4jCtx.session == nil {
+		return nil, nil, nil, errors.New("the graph session is nil that init first")
+	}
+	if relations == nil {
+		relations = make([]string, 0, 1)
+	}
+	if excludes == nil {
+		excludes = make([]string, 0, 1)
+	}
+	// 可变长度的深度不能作为参数传递，PathMaxDepth是常量所以可以直接拼接
+	cypher := fmt.Sprintf("MATCH p=(a{uid:$from})-[rs*1..%d]-(b{uid:$to}) "+
+		"WHERE ALL(r IN rs WHERE size($relations) = 0 OR r.relation IN $relations) "+
+		"AND NONE(n IN nodes(p) WHERE n.uid IN $excludes) "+
+		"AND ALL(n IN nodes(p) WHERE single(m IN nodes(p) WHERE m = n)) "+
+		"WITH p, reduce(c = 0.0, r IN rs | c + CASE WHEN $weighted AND coalesce(r.weight, 0) > 0 "+
+		"THEN 1.0 / r.weight ELSE 1.0 END) AS cost "+
+		"RETURN nodes(p) AS ns, relationships(p) AS rs, cost ORDER BY cost, length(p) LIMIT $count", PathMaxDepth)
+	params := map[string]interface{}{"from": from, "to": to, "weighted": weighted, "relations": relations,
+		"excludes": excludes, "count": count}
+	result, err := neo4jCtx.session.Run(cypher, params)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nodes := make([][]neo4j.Node, 0, count)
+	links := make([][]neo4j.Relationship, 0, count)
+	costs := make([]float64, 0, count)
+	for result.Next() {
+		ns, _ := result.Record().GetByIndex(0).([]interface{})
+		rs, _ := result.Record().GetByIndex(1).([]interface{})
+		cost, _ := result.Record().GetByIndex(2).(float64)
+		path := make([]neo4j.Node, 0, len(ns))
+		for _, item := range ns {
+			if node, ok := item.(neo4j.Node); ok {
+				path = append(path, node)
+			}
+		}
+		relationships := make([]neo4j.Relationship, 0, len(rs))
+		for _, item := range rs {
+			if link, ok := item.(neo4j.Relationship); ok {
+				relationships = append(relationships, link)
+			}
+		}
+		nodes = append(nodes, path)
+		links = append(links, relationships)
+		costs = append(costs, cost)
+	}
+	return nodes, links, costs, result.Err()
+}
+
 func FindGraph(uid, label string) ([]neo4j.Node, []neo4j.Relationship, error) {
 	if neo4jCtx.session == nil {
 		return nil, nil, errors.New("the graph session is nil that init first")
 	}
-	cypher := fmt.Sprintf("MATCH (a:%s{uid:'%s'})-[r]-(b) RETURN a,r,b", label, uid)
-	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{})
+	if err := checkLabel(label); err != nil {
+		return nil, nil, err
+	}
+	cypher := fmt.Sprintf("MATCH (a:%s{uid:$uid})-[r]-(b) RETURN a,r,b", label)
+	result, err := neo4jCtx.session.Run(cypher, map[string]interface{}{"uid": uid})
 	if err != nil {
 		return nil, nil, err
 	}
-	fmt.Println("FindGraph..." + cypher)
 	nodes := make([]neo4j.Node, 0, 5)
 	links := make([]neo4j.Relationship, 0, 5)
 	for result.Next() {
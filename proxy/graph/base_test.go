@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"strings"
+	"testing"
+)
+
+/**
+记录查询语句和参数的会话，不连接数据库
+*/
+type recordSession struct {
+	cyphers []string
+	params  []map[string]interface{}
+}
+
+type emptyResult struct{}
+
+func (mine *recordSession) LastBookmark() string { return "" }
+
+func (mine *recordSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (mine *recordSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (mine *recordSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (mine *recordSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	mine.cyphers = append(mine.cyphers, cypher)
+	mine.params = append(mine.params, params)
+	return &emptyResult{}, nil
+}
+
+func (mine *recordSession) Close() error { return nil }
+
+func (mine *emptyResult) Keys() ([]string, error)               { return nil, nil }
+func (mine *emptyResult) Next() bool                            { return false }
+func (mine *emptyResult) Err() error                            { return nil }
+func (mine *emptyResult) Record() neo4j.Record                  { return nil }
+func (mine *emptyResult) Summary() (neo4j.ResultSummary, error) { return nil, nil }
+func (mine *emptyResult) Consume() (neo4j.ResultSummary, error) { return nil, nil }
+
+var hostiles = []string{
+	`a'b`,
+	`a"b`,
+	"a`b",
+	`x}) DETACH DELETE n //`,
+	`x}]->(b) MATCH (c) DELETE c`,
+	"line\nMATCH (n) DELETE n",
+	`' OR 1=1`,
+}
+
+func useRecordSession(t *testing.T) *recordSession {
+	t.Helper()
+	session := new(recordSession)
+	neo4jCtx = &Neo4JContext{session: session}
+	SetLabels([]string{"Person"})
+	SetKinds([]string{"Relation"})
+	t.Cleanup(func() {
+		neo4jCtx = nil
+	})
+	return session
+}
+
+func hadParam(params map[string]interface{}, val string) bool {
+	for _, item := range params {
+		switch t := item.(type) {
+		case string:
+			if t == val {
+				return true
+			}
+		case []string:
+			for _, tmp := range t {
+				if tmp == val {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestHostileValuesOnlyInParams(t *testing.T) {
+	for _, value := range hostiles {
+		cases := []struct {
+			name string
+			call func() error
+		}{
+			{"CreateNode", func() error { _, err := CreateNode(value, "Person", value); return err }},
+			{"UpdateNode", func() error { _, err := UpdateNode(1, value, value); return err }},
+			{"GetNode", func() error { _, err := GetNode(value); return err }},
+			{"CreateLink", func() error { _, err := CreateLink(1, 2, "Relation", value, value, 0, 1); return err }},
+			{"GetLink", func() error { _, err := GetLink(value, value); return err }},
+			{"GetLinks", func() error { _, err := GetLinks(value, value); return err }},
+			{"FindPath", func() error { _, _, err := FindPath(value, value); return err }},
+			{"FindNeighborhood", func() error {
+				_, err := FindNeighborhood(value, 2, []string{value}, []string{value}, 10)
+				return err
+			}},
+			{"FindPaths", func() error {
+				_, _, _, err := FindPaths(value, value, true, []string{value}, []string{value}, 3)
+				return err
+			}},
+			{"FindGraph", func() error { _, _, err := FindGraph(value, "Person"); return err }},
+		}
+		for _, c := range cases {
+			session := useRecordSession(t)
+			if err := c.call(); err != nil {
+				t.Errorf("%s(%q): unexpected error %v", c.name, value, err)
+				continue
+			}
+			if len(session.cyphers) != 1 {
+				t.Fatalf("%s(%q): run %d queries, want 1", c.name, value, len(session.cyphers))
+			}
+			cypher := session.cyphers[0]
+			if strings.Contains(cypher, value) || strings.Contains(cypher, "\n") {
+				t.Errorf("%s(%q): the value is spliced into the query %q", c.name, value, cypher)
+			}
+			if !hadParam(session.params[0], value) {
+				t.Errorf("%s(%q): the value is not passed as a param %v", c.name, value, session.params[0])
+			}
+		}
+	}
+}
+
+func TestLabelAndKindWhitelist(t *testing.T) {
+	rejected := append([]string{"", "Other", "Person:Admin", "Person{a:1}", "1Person", "Per son", "Person`"}, hostiles...)
+	for _, label := range rejected {
+		cases := []struct {
+			name string
+			call func() error
+		}{
+			{"CreateNode", func() error { _, err := CreateNode("name", label, "uid"); return err }},
+			{"CreateNodeLabel", func() error { _, err := CreateNodeLabel(1, label); return err }},
+			{"CreateLink", func() error { _, err := CreateLink(1, 2, label, "name", "relation", 0, 1); return err }},
+			{"DeleteNode", func() error { return DeleteNode(1, label) }},
+			{"FindGraph", func() error { _, _, err := FindGraph("uid", label); return err }},
+		}
+		for _, c := range cases {
+			session := useRecordSession(t)
+			if err := c.call(); err == nil {
+				t.Errorf("%s(%q): the label or kind should be rejected", c.name, label)
+			}
+			if len(session.cyphers) > 0 {
+				t.Errorf("%s(%q): run the query %q", c.name, label, session.cyphers[0])
+			}
+		}
+	}
+	// 白名单内的值也必须符合标识符的格式
+	session := useRecordSession(t)
+	SetLabels([]string{"Bad Label", "Person"})
+	if err := checkLabel("Bad Label"); err == nil {
+		t.Error("the label out of the identifier regex should be rejected")
+	}
+	if _, err := CreateNode("name", "Person", "uid"); err != nil || len(session.cyphers) != 1 {
+		t.Errorf("the allowed label is rejected: %v", err)
+	}
+}
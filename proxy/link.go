@@ -1,10 +1,5 @@
 package proxy
 
-import (
-	"errors"
-	"omo.msa.vocabulary/proxy/graph"
-)
-
 type Link struct {
 	Direction uint8
 	ID        int64
@@ -17,8 +12,5 @@ type Link struct {
 }
 
 func (mine *Link) Delete() error {
-	if isNeo4j {
-		return graph.DeleteLink(mine.ID)
-	}
-	return errors.New("not support db")
+	return RemoveLink(mine.ID)
 }
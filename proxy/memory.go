@@ -0,0 +1,660 @@
+package proxy
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// 与neo4j最短路径查询的最大深度保持一致
+const maxPathDepth = 6
+
+// 一次查询返回的路径数量上限
+const maxPathCount = 10
+
+/**
+内存图引擎，使用邻接表保存节点和边，不依赖图数据库，用于小规模部署和测试
+*/
+type memoryGraph struct {
+	lock     sync.RWMutex
+	sequence int64
+	nodes    map[int64]*Node
+	uids     map[string]int64
+	links    map[int64]*Link
+	adjacent map[int64][]int64 //节点ID对应的边ID列表，不区分方向
+}
+
+func newMemoryGraph() *memoryGraph {
+	return &memoryGraph{
+		nodes:    make(map[int64]*Node, 100),
+		uids:     make(map[string]int64, 100),
+		links:    make(map[int64]*Link, 100),
+		adjacent: make(map[int64][]int64, 100),
+	}
+}
+
+func copyNode(info *Node) *Node {
+	if info == nil {
+		return nil
+	}
+	node := *info
+	node.Labels = append(make([]string, 0, len(info.Labels)), info.Labels...)
+	return &node
+}
+
+func copyLink(info *Link) *Link {
+	if info == nil {
+		return nil
+	}
+	link := *info
+	return &link
+}
+
+func hasLabel(info *Node, label string) bool {
+	for _, item := range info.Labels {
+		if item == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (mine *memoryGraph) nextID() int64 {
+	mine.sequence += 1
+	return mine.sequence
+}
+
+func (mine *memoryGraph) otherSide(link *Link, id int64) int64 {
+	if link.From == id {
+		return link.To
+	}
+	return link.From
+}
+
+func (mine *memoryGraph) CreateNode(name, label, uid string) (*Node, error) {
+	if len(label) < 1 {
+		return nil, errors.New("the label is empty")
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	node := &Node{ID: mine.nextID(), UID: uid, Name: name, Labels: []string{label}}
+	mine.nodes[node.ID] = node
+	if len(uid) > 0 {
+		mine.uids[uid] = node.ID
+	}
+	return copyNode(node), nil
+}
+
+func (mine *memoryGraph) CreateLink(from, to int64, kind, name, relation string, direction uint8, weight uint32) (*Link, error) {
+	if len(kind) < 1 {
+		return nil, errors.New("the kind is empty")
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	if mine.nodes[from] == nil || mine.nodes[to] == nil {
+		return nil, errors.New("the node of link not found")
+	}
+	link := &Link{ID: mine.nextID(), Label: kind, Name: name, Relation: relation,
+		Direction: direction, From: from, To: to, Weight: weight}
+	mine.links[link.ID] = link
+	mine.adjacent[from] = append(mine.adjacent[from], link.ID)
+	if from != to {
+		mine.adjacent[to] = append(mine.adjacent[to], link.ID)
+	}
+	return copyLink(link), nil
+}
+
+func (mine *memoryGraph) AddLabel(id int64, label string) (*Node, error) {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	node := mine.nodes[id]
+	if node == nil {
+		return nil, errNodeNotFound(id)
+	}
+	if !hasLabel(node, label) {
+		node.Labels = append(node.Labels, label)
+	}
+	return copyNode(node), nil
+}
+
+func (mine *memoryGraph) UpdateNode(id int64, name, cover string) (*Node, error) {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	node := mine.nodes[id]
+	if node == nil {
+		return nil, errNodeNotFound(id)
+	}
+	node.Name = name
+	node.Cover = cover
+	return copyNode(node), nil
+}
+
+func (mine *memoryGraph) GetNode(uid string) (*Node, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	id, ok := mine.uids[uid]
+	if !ok {
+		return nil, nil
+	}
+	return copyNode(mine.nodes[id]), nil
+}
+
+func (mine *memoryGraph) GetNodeByID(id int64) (*Node, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	return copyNode(mine.nodes[id]), nil
+}
+
+func (mine *memoryGraph) GetLink(from, to string) (*Link, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	a, ok := mine.uids[from]
+	if !ok {
+		return nil, nil
+	}
+	b, ok := mine.uids[to]
+	if !ok {
+		return nil, nil
+	}
+	for _, lid := range mine.adjacent[a] {
+		link := mine.links[lid]
+		if mine.otherSide(link, a) == b {
+			return copyLink(link), nil
+		}
+	}
+	return nil, nil
+}
+
+func (mine *memoryGraph) GetLinkByID(id int64) (*Link, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	return copyLink(mine.links[id]), nil
+}
+
+func (mine *memoryGraph) DeleteNode(id int64, label string) error {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	node := mine.nodes[id]
+	if node == nil || !hasLabel(node, label) {
+		return nil
+	}
+	for _, lid := range append([]int64{}, mine.adjacent[id]...) {
+		mine.removeLink(lid)
+	}
+	delete(mine.adjacent, id)
+	delete(mine.nodes, id)
+	if mine.uids[node.UID] == id {
+		delete(mine.uids, node.UID)
+	}
+	return nil
+}
+
+func (mine *memoryGraph) DeleteLink(id int64) error {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	mine.removeLink(id)
+	return nil
+}
+
+func (mine *memoryGraph) removeLink(id int64) {
+	link := mine.links[id]
+	if link == nil {
+		return
+	}
+	delete(mine.links, id)
+	for _, nid := range []int64{link.From, link.To} {
+		list := mine.adjacent[nid]
+		for i, lid := range list {
+			if lid == id {
+				mine.adjacent[nid] = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (mine *memoryGraph) GetAllNodes() ([]*Node, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]*Node, 0, len(mine.nodes))
+	for _, node := range mine.nodes {
+		list = append(list, copyNode(node))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (mine *memoryGraph) GetAllLinks() ([]*Link, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]*Link, 0, len(mine.links))
+	for _, link := range mine.links {
+		list = append(list, copyLink(link))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+/**
+广度优先搜索两个节点之间的最短路径，忽略边的方向
+*/
+func (mine *memoryGraph) FindPath(from, to string) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = from
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	start, ok := mine.uids[from]
+	if !ok {
+		return tmp, nil
+	}
+	end, ok := mine.uids[to]
+	if !ok {
+		return tmp, nil
+	}
+	// 记录到达每个节点时经过的边
+	previous := map[int64]int64{start: 0}
+	queue := []int64{start}
+	for depth := 0; depth < maxPathDepth && len(queue) > 0; depth += 1 {
+		next := make([]int64, 0, len(queue))
+		for _, id := range queue {
+			for _, lid := range mine.adjacent[id] {
+				other := mine.otherSide(mine.links[lid], id)
+				if _, had := previous[other]; had {
+					continue
+				}
+				previous[other] = lid
+				next = append(next, other)
+			}
+		}
+		if _, had := previous[end]; had {
+			break
+		}
+		queue = next
+	}
+	if _, had := previous[end]; !had || start == end {
+		return tmp, nil
+	}
+	nodes := []int64{end}
+	links := make([]int64, 0, maxPathDepth)
+	for id := end; id != start; {
+		lid := previous[id]
+		links = append(links, lid)
+		id = mine.otherSide(mine.links[lid], id)
+		nodes = append(nodes, id)
+	}
+	for i := len(nodes) - 1; i >= 0; i -= 1 {
+		tmp.AddNode(copyNode(mine.nodes[nodes[i]]))
+	}
+	for i := len(links) - 1; i >= 0; i -= 1 {
+		tmp.AddLink(copyLink(mine.links[links[i]]))
+	}
+	return tmp, nil
+}
+
+/**
+Yen算法查询两个节点之间距离最短的Count条简单路径，每条候选路径由Dijkstra算法计算，忽略边的方向
+*/
+func (mine *memoryGraph) FindPaths(from, to string, option *PathOption) ([]*Path, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]*Path, 0, option.Count)
+	start, ok := mine.uids[from]
+	if !ok {
+		return list, nil
+	}
+	end, ok := mine.uids[to]
+	if !ok || start == end {
+		return list, nil
+	}
+	search := &pathSearch{graph: mine, option: option, excluded: make(map[int64]bool, len(option.Excludes))}
+	for _, uid := range option.Excludes {
+		if id, had := mine.uids[uid]; had {
+			search.excluded[id] = true
+		}
+	}
+	if search.excluded[start] || search.excluded[end] {
+		return list, nil
+	}
+	for _, item := range search.kShortest(start, end, option.Count) {
+		path := new(Path)
+		path.construct()
+		path.Center = from
+		path.Cost = item.cost
+		for _, nid := range item.nodes {
+			path.AddNode(copyNode(mine.nodes[nid]))
+		}
+		for _, lid := range item.links {
+			path.AddLink(copyLink(mine.links[lid]))
+		}
+		list = append(list, path)
+	}
+	return list, nil
+}
+
+type pathCandidate struct {
+	nodes []int64
+	links []int64
+	cost  float64
+}
+
+func (mine *pathCandidate) same(other *pathCandidate) bool {
+	if len(mine.links) != len(other.links) {
+		return false
+	}
+	for i := range mine.links {
+		if mine.links[i] != other.links[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+路径搜索的条件，removedNodes和removedLinks为Yen算法每次偏离时临时去掉的节点和边
+*/
+type pathSearch struct {
+	graph        *memoryGraph
+	option       *PathOption
+	excluded     map[int64]bool
+	removedNodes map[int64]bool
+	removedLinks map[int64]bool
+}
+
+func (mine *pathSearch) allowLink(link *Link) bool {
+	if mine.removedLinks[link.ID] {
+		return false
+	}
+	if len(mine.option.Relations) < 1 {
+		return true
+	}
+	for _, item := range mine.option.Relations {
+		if item == link.Relation {
+			return true
+		}
+	}
+	return false
+}
+
+func (mine *pathSearch) kShortest(start, end int64, count int) []*pathCandidate {
+	shortest := mine.dijkstra(start, end)
+	if shortest == nil {
+		return nil
+	}
+	found := []*pathCandidate{shortest}
+	candidates := make([]*pathCandidate, 0, 10)
+	for len(found) < count {
+		last := found[len(found)-1]
+		for i := 0; i < len(last.links); i += 1 {
+			spur := last.nodes[i]
+			mine.removedNodes = make(map[int64]bool, i)
+			mine.removedLinks = make(map[int64]bool, len(found))
+			for _, item := range found {
+				if len(item.links) > i && samePrefix(item, last, i) {
+					mine.removedLinks[item.links[i]] = true
+				}
+			}
+			rootCost := 0.0
+			for j := 0; j < i; j += 1 {
+				mine.removedNodes[last.nodes[j]] = true
+				rootCost += linkCost(mine.graph.links[last.links[j]], mine.option.Weighted)
+			}
+			tail := mine.dijkstra(spur, end)
+			if tail == nil {
+				continue
+			}
+			next := &pathCandidate{cost: rootCost + tail.cost}
+			next.nodes = append(append(make([]int64, 0, i+len(tail.nodes)), last.nodes[:i]...), tail.nodes...)
+			next.links = append(append(make([]int64, 0, i+len(tail.links)), last.links[:i]...), tail.links...)
+			if !hadCandidate(candidates, next) && !hadCandidate(found, next) {
+				candidates = append(candidates, next)
+			}
+		}
+		mine.removedNodes = nil
+		mine.removedLinks = nil
+		if len(candidates) < 1 {
+			break
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].cost != candidates[j].cost {
+				return candidates[i].cost < candidates[j].cost
+			}
+			return len(candidates[i].links) < len(candidates[j].links)
+		})
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+	return found
+}
+
+/**
+Dijkstra算法计算两个节点之间距离最短的路径，没有路径时返回nil
+*/
+func (mine *pathSearch) dijkstra(start, end int64) *pathCandidate {
+	costs := map[int64]float64{start: 0}
+	hops := map[int64]int{start: 0}
+	previous := make(map[int64]int64, 10)
+	done := make(map[int64]bool, 10)
+	queue := &costQueue{{id: start}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(costItem)
+		if done[item.id] {
+			continue
+		}
+		done[item.id] = true
+		if item.id == end {
+			break
+		}
+		for _, lid := range mine.graph.adjacent[item.id] {
+			link := mine.graph.links[lid]
+			other := mine.graph.otherSide(link, item.id)
+			if done[other] || mine.excluded[other] || mine.removedNodes[other] || !mine.allowLink(link) {
+				continue
+			}
+			cost := costs[item.id] + linkCost(link, mine.option.Weighted)
+			old, had := costs[other]
+			if had && (old < cost || (old == cost && hops[other] <= hops[item.id]+1)) {
+				continue
+			}
+			costs[other] = cost
+			hops[other] = hops[item.id] + 1
+			previous[other] = lid
+			heap.Push(queue, costItem{id: other, cost: cost, hops: hops[other]})
+		}
+	}
+	if !done[end] {
+		return nil
+	}
+	path := &pathCandidate{cost: costs[end]}
+	path.nodes = []int64{end}
+	for id := end; id != start; {
+		lid := previous[id]
+		path.links = append(path.links, lid)
+		id = mine.graph.otherSide(mine.graph.links[lid], id)
+		path.nodes = append(path.nodes, id)
+	}
+	for i, j := 0, len(path.nodes)-1; i < j; i, j = i+1, j-1 {
+		path.nodes[i], path.nodes[j] = path.nodes[j], path.nodes[i]
+	}
+	for i, j := 0, len(path.links)-1; i < j; i, j = i+1, j-1 {
+		path.links[i], path.links[j] = path.links[j], path.links[i]
+	}
+	return path
+}
+
+func samePrefix(a, b *pathCandidate, length int) bool {
+	for i := 0; i < length; i += 1 {
+		if a.links[i] != b.links[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hadCandidate(list []*pathCandidate, info *pathCandidate) bool {
+	for _, item := range list {
+		if item.same(info) {
+			return true
+		}
+	}
+	return false
+}
+
+type costItem struct {
+	id   int64
+	cost float64
+	hops int
+}
+
+/**
+按照距离排序的优先队列，距离相同时经过的边少的优先
+*/
+type costQueue []costItem
+
+func (mine costQueue) Len() int { return len(mine) }
+
+func (mine costQueue) Less(i, j int) bool {
+	if mine[i].cost != mine[j].cost {
+		return mine[i].cost < mine[j].cost
+	}
+	return mine[i].hops < mine[j].hops
+}
+
+func (mine costQueue) Swap(i, j int) { mine[i], mine[j] = mine[j], mine[i] }
+
+func (mine *costQueue) Push(x interface{}) { *mine = append(*mine, x.(costItem)) }
+
+func (mine *costQueue) Pop() interface{} {
+	old := *mine
+	item := old[len(old)-1]
+	*mine = old[:len(old)-1]
+	return item
+}
+
+/**
+查询节点及其直接相连的节点和边
+*/
+func (mine *memoryGraph) FindGraph(uid, label string) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = uid
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	id, ok := mine.uids[uid]
+	if !ok || !hasLabel(mine.nodes[id], label) {
+		return tmp, nil
+	}
+	nodes, links := mine.neighborhood(id, 1)
+	for _, nid := range nodes {
+		tmp.AddNode(copyNode(mine.nodes[nid]))
+	}
+	for _, lid := range links {
+		tmp.AddLink(copyLink(mine.links[lid]))
+	}
+	return tmp, nil
+}
+
+func (mine *memoryGraph) FindNeighborhood(uid string, depth int, relations, labels []string, limit int) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = uid
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	center, ok := mine.uids[uid]
+	if !ok {
+		return tmp, nil
+	}
+	allowLink := func(link *Link) bool {
+		if len(relations) < 1 {
+			return true
+		}
+		for _, item := range relations {
+			if item == link.Relation {
+				return true
+			}
+		}
+		return false
+	}
+	allowNode := func(node *Node) bool {
+		if len(labels) < 1 {
+			return true
+		}
+		for _, item := range labels {
+			if hasLabel(node, item) {
+				return true
+			}
+		}
+		return false
+	}
+	visited := map[int64]bool{center: true}
+	used := make(map[int64]bool, 10)
+	tmp.AddNode(copyNode(mine.nodes[center]))
+	queue := []int64{center}
+	for i := 0; i < depth && len(queue) > 0; i += 1 {
+		next := make([]int64, 0, len(queue))
+		for _, id := range queue {
+			for _, lid := range mine.adjacent[id] {
+				link := mine.links[lid]
+				if used[lid] || !allowLink(link) {
+					continue
+				}
+				other := mine.otherSide(link, id)
+				if !visited[other] {
+					if !allowNode(mine.nodes[other]) {
+						continue
+					}
+					if limit > 0 && len(tmp.Nodes) >= limit {
+						continue
+					}
+					visited[other] = true
+					tmp.AddNode(copyNode(mine.nodes[other]))
+					next = append(next, other)
+				}
+				used[lid] = true
+				tmp.AddLink(copyLink(link))
+			}
+		}
+		queue = next
+	}
+	return tmp, nil
+}
+
+/**
+广度优先遍历指定深度内的节点和边，没有边的孤立节点不返回
+*/
+func (mine *memoryGraph) neighborhood(center int64, depth int) ([]int64, []int64) {
+	nodes := make([]int64, 0, 10)
+	links := make([]int64, 0, 10)
+	if len(mine.adjacent[center]) < 1 {
+		return nodes, links
+	}
+	visited := map[int64]bool{center: true}
+	used := make(map[int64]bool, 10)
+	nodes = append(nodes, center)
+	queue := []int64{center}
+	for i := 0; i < depth && len(queue) > 0; i += 1 {
+		next := make([]int64, 0, len(queue))
+		for _, id := range queue {
+			for _, lid := range mine.adjacent[id] {
+				if !used[lid] {
+					used[lid] = true
+					links = append(links, lid)
+				}
+				other := mine.otherSide(mine.links[lid], id)
+				if visited[other] {
+					continue
+				}
+				visited[other] = true
+				nodes = append(nodes, other)
+				next = append(next, other)
+			}
+		}
+		queue = next
+	}
+	return nodes, links
+}
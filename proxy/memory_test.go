@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+)
+
+/**
+a-b-d、a-c-d、a-d和a-e-f-d四条路径，a-d的权重最低
+*/
+func newTestGraph(t *testing.T) *memoryGraph {
+	t.Helper()
+	g := newMemoryGraph()
+	ids := make(map[string]int64, 6)
+	for _, uid := range []string{"a", "b", "c", "d", "e", "f"} {
+		node, _ := g.CreateNode(uid, "Person", uid)
+		ids[uid] = node.ID
+	}
+	links := []struct {
+		from, to string
+		relation string
+		weight   uint32
+	}{
+		{"a", "b", "friend", 4},
+		{"b", "d", "friend", 4},
+		{"a", "c", "family", 1},
+		{"c", "d", "family", 1},
+		{"a", "d", "friend", 1},
+		{"a", "e", "friend", 10},
+		{"e", "f", "friend", 10},
+		{"f", "d", "friend", 10},
+	}
+	for _, item := range links {
+		if _, err := g.CreateLink(ids[item.from], ids[item.to], "relation", item.relation, item.relation, 0, item.weight); err != nil {
+			t.Fatalf("create link: %v", err)
+		}
+	}
+	return g
+}
+
+func pathNames(path *Path) string {
+	name := ""
+	for _, node := range path.Nodes {
+		name += node.UID
+	}
+	return fmt.Sprintf("%s:%.2f", name, path.Cost)
+}
+
+func TestMemoryFindPaths(t *testing.T) {
+	g := newTestGraph(t)
+	cases := []struct {
+		name   string
+		option *PathOption
+		want   []string
+	}{
+		{"unweighted", &PathOption{Count: 2}, []string{"ad:1.00", "abd:2.00"}},
+		{"weighted", &PathOption{Weighted: true, Count: 4}, []string{"aefd:0.30", "abd:0.50", "ad:1.00", "acd:2.00"}},
+		{"relations", &PathOption{Relations: []string{"family"}, Count: 3}, []string{"acd:2.00"}},
+		{"excludes", &PathOption{Weighted: true, Excludes: []string{"e", "b"}, Count: 3}, []string{"ad:1.00", "acd:2.00"}},
+		{"more than paths", &PathOption{Weighted: true, Count: 10}, []string{"aefd:0.30", "abd:0.50", "ad:1.00", "acd:2.00"}},
+	}
+	for _, c := range cases {
+		paths, err := g.FindPaths("a", "d", c.option)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(paths) != len(c.want) {
+			t.Errorf("%s: got %d paths, want %d", c.name, len(paths), len(c.want))
+			continue
+		}
+		for i, path := range paths {
+			if got := pathNames(path); got != c.want[i] {
+				t.Errorf("%s: path %d = %s, want %s", c.name, i, got, c.want[i])
+			}
+			if len(path.Links) != len(path.Nodes)-1 {
+				t.Errorf("%s: path %d has %d nodes and %d links", c.name, i, len(path.Nodes), len(path.Links))
+			}
+		}
+	}
+	if paths, _ := g.FindPaths("a", "x", &PathOption{Count: 3}); len(paths) != 0 {
+		t.Errorf("the path to a missing node = %d", len(paths))
+	}
+}
+
+func TestMemoryNodeCRUD(t *testing.T) {
+	g := newMemoryGraph()
+	if _, err := g.CreateNode("a", "", "a"); err == nil {
+		t.Error("create a node without label should fail")
+	}
+	node, err := g.CreateNode("a", "Person", "a")
+	if err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	if got, _ := g.GetNode("a"); got == nil || got.ID != node.ID || got.Name != "a" {
+		t.Fatalf("get node = %+v", got)
+	}
+	if got, _ := g.AddLabel(node.ID, "Writer"); got == nil || len(got.Labels) != 2 {
+		t.Errorf("add label = %+v", got)
+	}
+	if got, _ := g.AddLabel(node.ID, "Writer"); got == nil || len(got.Labels) != 2 {
+		t.Errorf("add the same label again = %+v", got)
+	}
+	if got, _ := g.UpdateNode(node.ID, "b", "cover"); got == nil || got.Name != "b" || got.Cover != "cover" {
+		t.Errorf("update node = %+v", got)
+	}
+	if got, _ := g.GetNodeByID(node.ID); got == nil || got.Name != "b" || len(got.Labels) != 2 {
+		t.Errorf("get node by id = %+v", got)
+	}
+	if _, err = g.AddLabel(99, "Writer"); err == nil {
+		t.Error("add label to a missing node should fail")
+	}
+	if _, err = g.UpdateNode(99, "b", ""); err == nil {
+		t.Error("update a missing node should fail")
+	}
+	// 标签不符时不删除
+	_ = g.DeleteNode(node.ID, "Place")
+	if got, _ := g.GetNode("a"); got == nil {
+		t.Fatal("the node is deleted with a wrong label")
+	}
+	if err = g.DeleteNode(node.ID, "Person"); err != nil {
+		t.Fatalf("delete node: %v", err)
+	}
+	if got, _ := g.GetNode("a"); got != nil {
+		t.Errorf("the node is not deleted = %+v", got)
+	}
+	if list, _ := g.GetAllNodes(); len(list) != 0 {
+		t.Errorf("the nodes = %d after delete, want 0", len(list))
+	}
+}
+
+func TestMemoryLinkCRUD(t *testing.T) {
+	g := newMemoryGraph()
+	a, _ := g.CreateNode("a", "Person", "a")
+	b, _ := g.CreateNode("b", "Person", "b")
+	if _, err := g.CreateLink(a.ID, 99, "relation", "friend", "r1", 0, 1); err == nil {
+		t.Error("create a link to a missing node should fail")
+	}
+	link, err := g.CreateLink(a.ID, b.ID, "relation", "friend", "r1", 1, 3)
+	if err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if got, _ := g.GetLinkByID(link.ID); got == nil || got.Name != "friend" || got.Relation != "r1" || got.Weight != 3 {
+		t.Errorf("get link by id = %+v", got)
+	}
+	// 查询时不区分方向
+	if got, _ := g.GetLink("b", "a"); got == nil || got.ID != link.ID {
+		t.Errorf("get link = %+v", got)
+	}
+	if got, _ := g.GetLink("a", "x"); got != nil {
+		t.Errorf("get link to a missing node = %+v", got)
+	}
+	if err = g.DeleteLink(link.ID); err != nil {
+		t.Fatalf("delete link: %v", err)
+	}
+	if got, _ := g.GetLink("a", "b"); got != nil {
+		t.Errorf("the link is not deleted = %+v", got)
+	}
+	// 删除节点时同时删除节点的关系
+	_, _ = g.CreateLink(a.ID, b.ID, "relation", "friend", "r1", 1, 3)
+	_ = g.DeleteNode(b.ID, "Person")
+	if list, _ := g.GetAllLinks(); len(list) != 0 {
+		t.Errorf("the links = %d after delete node, want 0", len(list))
+	}
+	if len(g.adjacent[a.ID]) != 0 {
+		t.Errorf("the adjacent of a = %v, want empty", g.adjacent[a.ID])
+	}
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"omo.msa.vocabulary/proxy/graph"
+)
+
+type neo4jGraph struct {
+}
+
+func switchNode(info neo4j.Node) *Node {
+	if info == nil {
+		return nil
+	}
+	node := new(Node)
+	props := info.Props()
+	node.Name, _ = props["name"].(string)
+	node.UID, _ = props["uid"].(string)
+	node.Cover, _ = props["cover"].(string)
+	node.Labels = info.Labels()
+	node.ID = info.Id()
+	return node
+}
+
+func switchLink(info neo4j.Relationship) *Link {
+	if info == nil {
+		return nil
+	}
+	link := new(Link)
+	link.ID = info.Id()
+	link.Label = info.Type()
+	props := info.Props()
+	link.Name, _ = props["name"].(string)
+	link.Relation, _ = props["relation"].(string)
+	direction, _ := props["direction"].(int64)
+	link.Direction = uint8(direction)
+	weight, _ := props["weight"].(int64)
+	link.Weight = uint32(weight)
+	link.From = info.StartId()
+	link.To = info.EndId()
+	return link
+}
+
+func (mine *neo4jGraph) CreateNode(name, label, uid string) (*Node, error) {
+	node, err := graph.CreateNode(name, label, uid)
+	return switchNode(node), err
+}
+
+func (mine *neo4jGraph) CreateLink(from, to int64, kind, name, relation string, direction uint8, weight uint32) (*Link, error) {
+	link, err := graph.CreateLink(from, to, kind, name, relation, direction, weight)
+	return switchLink(link), err
+}
+
+func (mine *neo4jGraph) AddLabel(id int64, label string) (*Node, error) {
+	node, err := graph.CreateNodeLabel(id, label)
+	if err == nil && node == nil {
+		return nil, errNodeNotFound(id)
+	}
+	return switchNode(node), err
+}
+
+func (mine *neo4jGraph) UpdateNode(id int64, name, cover string) (*Node, error) {
+	node, err := graph.UpdateNode(id, name, cover)
+	if err == nil && node == nil {
+		return nil, errNodeNotFound(id)
+	}
+	return switchNode(node), err
+}
+
+func (mine *neo4jGraph) GetNode(uid string) (*Node, error) {
+	node, err := graph.GetNode(uid)
+	return switchNode(node), err
+}
+
+func (mine *neo4jGraph) GetNodeByID(id int64) (*Node, error) {
+	node, err := graph.GetNodeByID(id)
+	return switchNode(node), err
+}
+
+func (mine *neo4jGraph) GetLink(from, to string) (*Link, error) {
+	link, err := graph.GetLink(from, to)
+	return switchLink(link), err
+}
+
+func (mine *neo4jGraph) GetLinkByID(id int64) (*Link, error) {
+	link, err := graph.GetLinkByID(id)
+	return switchLink(link), err
+}
+
+func (mine *neo4jGraph) DeleteNode(id int64, label string) error {
+	return graph.DeleteNode(id, label)
+}
+
+func (mine *neo4jGraph) DeleteLink(id int64) error {
+	return graph.DeleteLink(id)
+}
+
+func (mine *neo4jGraph) GetAllNodes() ([]*Node, error) {
+	nodes, err := graph.GetAllNodes()
+	list := make([]*Node, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, switchNode(node))
+	}
+	return list, err
+}
+
+func (mine *neo4jGraph) GetAllLinks() ([]*Link, error) {
+	links, err := graph.GetAllLinks()
+	list := make([]*Link, 0, len(links))
+	for _, link := range links {
+		list = append(list, switchLink(link))
+	}
+	return list, err
+}
+
+func (mine *neo4jGraph) FindPath(from, to string) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = from
+	nodes, links, err := graph.FindPath(from, to)
+	for i := 0; i < len(nodes); i += 1 {
+		tmp.AddNode(switchNode(nodes[i]))
+	}
+	for i := 0; i < len(links); i += 1 {
+		tmp.AddLink(switchLink(links[i]))
+	}
+	return tmp, err
+}
+
+func (mine *neo4jGraph) FindPaths(from, to string, option *PathOption) ([]*Path, error) {
+	nodes, links, costs, err := graph.FindPaths(from, to, option.Weighted, option.Relations, option.Excludes, option.Count)
+	list := make([]*Path, 0, len(nodes))
+	for i := range nodes {
+		path := new(Path)
+		path.construct()
+		path.Center = from
+		path.Cost = costs[i]
+		for _, node := range nodes[i] {
+			path.AddNode(switchNode(node))
+		}
+		for _, link := range links[i] {
+			path.AddLink(switchLink(link))
+		}
+		list = append(list, path)
+	}
+	return list, err
+}
+
+func (mine *neo4jGraph) FindNeighborhood(uid string, depth int, relations, labels []string, limit int) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = uid
+	paths, err := graph.FindNeighborhood(uid, depth, relations, labels, limit)
+	if err != nil {
+		return tmp, err
+	}
+	for _, path := range paths {
+		nodes := path.Nodes()
+		links := path.Relationships()
+		// 按照路径长度排序，路径上的节点超出上限时丢弃整条路径
+		count := 0
+		for _, node := range nodes {
+			if !tmp.HadNode(node.Id()) {
+				count += 1
+			}
+		}
+		if limit > 0 && len(tmp.Nodes)+count > limit {
+			continue
+		}
+		for _, node := range nodes {
+			tmp.AddNode(switchNode(node))
+		}
+		for _, link := range links {
+			tmp.AddLink(switchLink(link))
+		}
+	}
+	return tmp, nil
+}
+
+func (mine *neo4jGraph) FindGraph(uid, label string) (*Graph, error) {
+	var tmp = new(Graph)
+	tmp.construct()
+	tmp.Center = uid
+	nodes, links, err := graph.FindGraph(uid, label)
+	for i := 0; i < len(nodes); i += 1 {
+		tmp.AddNode(switchNode(nodes[i]))
+	}
+	for i := 0; i < len(links); i += 1 {
+		tmp.AddLink(switchLink(links[i]))
+	}
+	return tmp, err
+}
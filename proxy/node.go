@@ -2,27 +2,38 @@ package proxy
 
 import (
 	"errors"
-	"omo.msa.vocabulary/proxy/graph"
+	"fmt"
 )
 
 type Node struct {
 	ID     int64
 	UID    string
 	Name   string
+	Cover  string
 	Labels []string
 }
 
+func errNodeNotFound(id int64) error {
+	return fmt.Errorf("not found the node of id %d", id)
+}
+
 func (mine *Node) AddLabel(label string) (*Node, error) {
-	if isNeo4j {
-		node, err := graph.CreateNodeLabel(mine.ID, label)
-		return switchNode(node), err
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	return graphStore.AddLabel(mine.ID, label)
+}
+
+func (mine *Node) Update(name, cover string) (*Node, error) {
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.UpdateNode(mine.ID, name, cover)
 }
 
 func (mine *Node) Delete() error {
-	if isNeo4j {
-		return graph.DeleteNode(mine.ID, mine.Labels[0])
+	if len(mine.Labels) < 1 {
+		return errors.New("the node label is empty")
 	}
-	return errors.New("not support db")
+	return RemoveNode(mine.ID, mine.Labels[0])
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
@@ -25,9 +26,45 @@ type Archived struct {
 	Name    string `json:"name" bson:"name"`
 	Entity  string `json:"entity" bson:"entity"`
 	Scene   string `json:"scene" bson:"scene"`
-	File    string `json:"file" bson:"file"`
-	MD5     string `json:"md5" bson:"md5"`
-	Size    uint32 `json:"size" bson:"size"`
+	Version uint32 `json:"version" bson:"version"` //当前公开的版本
+
+	ArchivedFile `bson:",inline"`
+}
+
+/**
+存档文件，Size和MD5为JSON原文的大小和校验值，Format为数据结构的版本，Encoding为压缩方式，
+数据较大时保存在GridFS中，Blob为文件ID，File为空
+*/
+type ArchivedFile struct {
+	File     string `json:"file" bson:"file"`
+	MD5      string `json:"md5" bson:"md5"`
+	Size     uint32 `json:"size" bson:"size"`
+	Format   uint32 `json:"format" bson:"format"`
+	Encoding string `json:"encoding" bson:"encoding"`
+	Blob     string `json:"blob" bson:"blob"`
+}
+
+/**
+每次发布生成的存档版本，创建后不再修改
+*/
+type ArchivedVersion struct {
+	UID         primitive.ObjectID `bson:"_id"`
+	ID          uint64             `json:"id" bson:"id"`
+	CreatedTime time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedTime time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeleteTime  time.Time          `json:"deleteAt" bson:"deleteAt"`
+	Created     int64              `json:"created" bson:"created"`
+	Updated     int64              `json:"updated" bson:"updated"`
+	Deleted     int64              `json:"deleted" bson:"deleted"`
+	Creator     string             `json:"creator" bson:"creator"`
+	Operator    string             `json:"operator" bson:"operator"`
+
+	Entity   string `json:"entity" bson:"entity"`
+	Archived string `json:"archived" bson:"archived"`
+	Version  uint32 `json:"version" bson:"version"`
+	Note     string `json:"note" bson:"note"`
+
+	ArchivedFile `bson:",inline"`
 }
 
 func CreateArchived(info *Archived) error {
@@ -43,8 +80,21 @@ func GetArchivedNextID() uint64 {
 	return num
 }
 
+func GetArchived(uid string) (*Archived, error) {
+	result, err := findOne(TableArchived, uid)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Archived)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
 func GetArchivedByEntity(uid string) (*Archived, error) {
-	filter := bson.M{"entity": uid}
+	filter := bson.M{"entity": uid, TimeDeleted: 0}
 	result, err := findOneBy(TableArchived, filter)
 	if err != nil {
 		return nil, err
@@ -77,14 +127,14 @@ func GetAllArchived() ([]*Archived, error) {
 }
 
 func HadArchivedItem(entity string) bool {
-	filter := bson.M{"entity": entity}
+	filter := bson.M{"entity": entity, TimeDeleted: 0}
 	had, _ := hadOne(TableArchived, filter)
 	return had
 }
 
 func GetArchivedItems(name string) ([]*Archived, error) {
 	var items = make([]*Archived, 0, 20)
-	msg := bson.M{"name": bson.M{"$regex": name}}
+	msg := bson.M{"name": bson.M{"$regex": name}, TimeDeleted: 0}
 	cursor, err1 := findMany(TableArchived, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -103,7 +153,7 @@ func GetArchivedItems(name string) ([]*Archived, error) {
 
 func GetArchivedListByScene(scene string) ([]*Archived, error) {
 	var items = make([]*Archived, 0, 20)
-	filter := bson.M{"scene": scene}
+	filter := bson.M{"scene": scene, TimeDeleted: 0}
 	cursor, err1 := findMany(TableArchived, filter, 0)
 	if err1 != nil {
 		return nil, err1
@@ -122,7 +172,7 @@ func GetArchivedListByScene(scene string) ([]*Archived, error) {
 
 func GetArchivedListBy(scene, concept string) ([]*Archived, error) {
 	var items = make([]*Archived, 0, 20)
-	filter := bson.M{"scene": scene, "concept": concept}
+	filter := bson.M{"scene": scene, "concept": concept, TimeDeleted: 0}
 	cursor, err1 := findMany(TableArchived, filter, 0)
 	if err1 != nil {
 		return nil, err1
@@ -139,12 +189,48 @@ func GetArchivedListBy(scene, concept string) ([]*Archived, error) {
 	return items, nil
 }
 
-func UpdateArchivedFile(uid, operator, file, md5 string, size uint32) error {
-	msg := bson.M{"file": file, "md5": md5, "size": size, "operator": operator, TimeUpdated: time.Now().Unix()}
+func UpdateArchivedFile(uid, operator string, file *ArchivedFile) error {
+	msg := bson.M{"file": file.File, "md5": file.MD5, "size": file.Size, "format": file.Format, "encoding": file.Encoding,
+		"blob": file.Blob, "operator": operator, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableArchived, uid, msg)
+	return err
+}
+
+func UpdateArchivedVersion(uid, operator string, file *ArchivedFile, version uint32) error {
+	msg := bson.M{"file": file.File, "md5": file.MD5, "size": file.Size, "format": file.Format, "encoding": file.Encoding,
+		"blob": file.Blob, "version": version, "operator": operator, TimeUpdated: time.Now().Unix()}
 	_, err := updateOne(TableArchived, uid, msg)
 	return err
 }
 
+/**
+存档版本的文件只在迁移重新编码时修改
+*/
+func UpdateArchivedVersionFile(uid string, file *ArchivedFile) error {
+	msg := bson.M{"file": file.File, "md5": file.MD5, "size": file.Size, "format": file.Format, "encoding": file.Encoding,
+		"blob": file.Blob, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableArchivedVersion, uid, msg)
+	return err
+}
+
+func GetAllArchivedVersions() ([]*ArchivedVersion, error) {
+	var items = make([]*ArchivedVersion, 0, 20)
+	cursor, err1 := findAll(TableArchivedVersion, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(ArchivedVersion)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func UpdateArchivedAccess(uid, operator string, acc uint8) error {
 	msg := bson.M{"access": acc, "operator": operator, TimeUpdated: time.Now().Unix()}
 	_, err := updateOne(TableArchived, uid, msg)
@@ -160,3 +246,77 @@ func HadArchivedByName(name string) (bool, error) {
 	msg := bson.M{"name": name}
 	return hadOne(TableArchived, msg)
 }
+
+func CreateArchivedVersion(info *ArchivedVersion) error {
+	_, err := insertOne(TableArchivedVersion, info)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func DeleteArchivedVersion(uid string) error {
+	_, err := deleteOne(TableArchivedVersion, uid)
+	return err
+}
+
+/**
+存档版本是否引用了GridFS中的文件，版本复制了发布时的存档文件
+*/
+func HadArchivedVersionBlob(blob string) bool {
+	had, _ := hadOne(TableArchivedVersion, bson.M{"blob": blob})
+	return had
+}
+
+func GetArchivedVersionNextID() uint64 {
+	num, _ := getSequenceNext(TableArchivedVersion)
+	return num
+}
+
+func GetArchivedVersion(entity string, version uint32) (*ArchivedVersion, error) {
+	filter := bson.M{"entity": entity, "version": version}
+	result, err := findOneBy(TableArchivedVersion, filter)
+	if err != nil {
+		return nil, err
+	}
+	model := new(ArchivedVersion)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+/**
+按照版本从新到旧排序，num为0时返回全部
+*/
+func GetArchivedVersions(entity string, num int64) ([]*ArchivedVersion, error) {
+	var items = make([]*ArchivedVersion, 0, 10)
+	filter := bson.M{"entity": entity}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	if num > 0 {
+		opts.SetLimit(num)
+	}
+	cursor, err1 := findManyByOpts(TableArchivedVersion, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(ArchivedVersion)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func GetArchivedLastVersion(entity string) uint32 {
+	list, err := GetArchivedVersions(entity, 1)
+	if err != nil || len(list) < 1 {
+		return 0
+	}
+	return list[0].Version
+}
@@ -0,0 +1,37 @@
+package nosql
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	BucketArchived = "archives"
+)
+
+func UploadBlob(bucket, name string, data []byte) (string, error) {
+	if noSql == nil {
+		return "", errors.New("the nosql store is not init")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	return noSql.Bucket(bucket).Upload(ctx, name, data)
+}
+
+func DownloadBlob(bucket, id string) ([]byte, error) {
+	if noSql == nil {
+		return nil, errors.New("the nosql store is not init")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	return noSql.Bucket(bucket).Download(ctx, id)
+}
+
+func DeleteBlob(bucket, id string) error {
+	if noSql == nil {
+		return errors.New("the nosql store is not init")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	return noSql.Bucket(bucket).Delete(ctx, id)
+}
@@ -2,6 +2,7 @@ package nosql
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -57,6 +58,9 @@ func GetBox(uid string) (*Box, error) {
 	if err1 != nil {
 		return nil, err1
 	}
+	if model.Deleted > 0 {
+		return nil, errors.New("the box had deleted")
+	}
 	return model, nil
 }
 
@@ -273,6 +277,12 @@ func UpdateBoxConcept(uid, operator, concept string) error {
 	return err
 }
 
+func UpdateBoxWorkflow(uid, operator, workflow string) error {
+	msg := bson.M{"operator": operator, "workflow": workflow, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableBox, uid, msg)
+	return err
+}
+
 func UpdateBoxOwner(uid, owner string) error {
 	msg := bson.M{"owner": owner, TimeUpdated: time.Now().Unix()}
 	_, err := updateOne(TableBox, uid, msg)
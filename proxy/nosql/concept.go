@@ -2,6 +2,7 @@ package nosql
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
@@ -53,6 +54,9 @@ func GetConcept(uid string) (*Concept, error) {
 	if err1 != nil {
 		return nil, err1
 	}
+	if model.Deleted > 0 {
+		return nil, errors.New("the concept had deleted")
+	}
 	return model, nil
 }
 
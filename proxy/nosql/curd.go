@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,24 @@ const (
 	TimeDeleted = "deleted"
 )
 
+/**
+唯一索引冲突，mongodb的错误码为11000，内存存储的错误信息包含duplicate key
+*/
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exception mongo.WriteException
+	if errors.As(err, &exception) {
+		for _, item := range exception.WriteErrors {
+			if item.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return strings.Contains(err.Error(), "duplicate key")
+}
+
 func UpdateItemTime(table, uid string, created, updated, del time.Time) {
 	d := del.Unix()
 	if d < 0 {
@@ -52,7 +71,28 @@ func GetAll[T any](table string, items []*T) []*T {
 	return items
 }
 
-func findAll(collection string, limit int64) (*mongo.Cursor, error) {
+/**
+表中没有删除的全部数据
+*/
+func GetAllEnable[T any](table string) ([]*T, error) {
+	cursor, err1 := findAllEnable(table, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	items := make([]*T, 0, 100)
+	for cursor.Next(context.Background()) {
+		var node = new(T)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func findAll(collection string, limit int64) (Cursor, error) {
 	if len(collection) < 1 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -63,7 +103,7 @@ func findAll(collection string, limit int64) (*mongo.Cursor, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
 	filter := bson.M{}
-	var cursor *mongo.Cursor
+	var cursor Cursor
 	var err error
 	if limit > 0 {
 		cursor, err = c.Find(ctx, filter, options.Find().SetSort(bson.M{TimeCreated: -1}).SetLimit(limit))
@@ -90,7 +130,7 @@ func insertOne(collection string, info interface{}) (interface{}, error) {
 	if err != nil {
 		return "", err
 	}
-	return result.InsertedID, nil
+	return result, nil
 }
 
 func getCount(collection string) (int64, error) {
@@ -103,7 +143,7 @@ func getCount(collection string) (int64, error) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
-	result, err := c.EstimatedDocumentCount(ctx, nil)
+	result, err := c.EstimatedDocumentCount(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -137,7 +177,7 @@ func getTotalCount(collection string) (int64, error) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
-	result, err := c.EstimatedDocumentCount(ctx, nil)
+	result, err := c.EstimatedDocumentCount(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -184,7 +224,7 @@ func deleteOne(collection, uid string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.DeletedCount, nil
+	return result, nil
 }
 
 func removeOne(collection, uid, operator string) (int64, error) {
@@ -210,7 +250,36 @@ func removeOne(collection, uid, operator string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return result, nil
+}
+
+/**
+恢复软删除的数据
+*/
+func recoverOne(collection, uid, operator string) (int64, error) {
+	if len(collection) < 1 {
+		return 0, errors.New("the collection is empty")
+	}
+	if len(uid) < 2 {
+		return 0, errors.New("the uid is empty of " + collection)
+	}
+	objID, e := primitive.ObjectIDFromHex(uid)
+	if e != nil {
+		return 0, e
+	}
+	c := noSql.Collection(collection)
+	if c == nil {
+		return 0, errors.New("can not found the collection of" + collection)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	filter := bson.M{"_id": objID, TimeDeleted: bson.M{"$gt": 0}}
+	node := bson.M{"$set": bson.M{"operator": operator, TimeDeleted: 0, TimeUpdated: time.Now().Unix()}}
+	result, err := c.UpdateOne(ctx, filter, node)
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
 }
 
 func hadOne(collection string, filter bson.M) (bool, error) {
@@ -253,7 +322,7 @@ func updateOne(collection, uid string, data bson.M) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return result, nil
 }
 
 /**
@@ -282,7 +351,7 @@ func appendElement(collection, uid string, data bson.M) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return result, nil
 }
 
 /**
@@ -311,7 +380,7 @@ func removeElement(collection, uid string, data bson.M) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return result, nil
 }
 
 func updateOneBy(collection string, filter bson.M, update bson.M) (int64, error) {
@@ -328,10 +397,31 @@ func updateOneBy(collection string, filter bson.M, update bson.M) (int64, error)
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return result, nil
 }
 
-func findOne(collection, uid string) (*mongo.SingleResult, error) {
+/**
+原子更新并返回更新后的文档，不存在时插入
+*/
+func upsertOneBy(collection string, filter bson.M, update bson.M) (SingleResult, error) {
+	if len(collection) < 2 {
+		return nil, errors.New("the collection is empty")
+	}
+	c := noSql.Collection(collection)
+	if c == nil {
+		return nil, errors.New("can not found the collection of" + collection)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	result := c.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return result, nil
+}
+
+func findOne(collection, uid string) (SingleResult, error) {
 	if len(collection) < 2 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -356,7 +446,7 @@ func findOne(collection, uid string) (*mongo.SingleResult, error) {
 	return result, nil
 }
 
-func findOneBy(collection string, filter bson.M) (*mongo.SingleResult, error) {
+func findOneBy(collection string, filter bson.M) (SingleResult, error) {
 	if len(collection) < 2 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -373,7 +463,7 @@ func findOneBy(collection string, filter bson.M) (*mongo.SingleResult, error) {
 	return result, nil
 }
 
-func findOneOfField(collection, uid string, selector bson.M) (*mongo.SingleResult, error) {
+func findOneOfField(collection, uid string, selector bson.M) (SingleResult, error) {
 	if len(collection) < 2 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -398,7 +488,7 @@ func findOneOfField(collection, uid string, selector bson.M) (*mongo.SingleResul
 	return result, nil
 }
 
-func findOneByOpt(collection string, filter bson.M, selector bson.M) (*mongo.SingleResult, error) {
+func findOneByOpt(collection string, filter bson.M, selector bson.M) (SingleResult, error) {
 	if len(collection) < 2 {
 		return nil, errors.New("the uid is empty of " + collection)
 	}
@@ -415,7 +505,7 @@ func findOneByOpt(collection string, filter bson.M, selector bson.M) (*mongo.Sin
 	return result, nil
 }
 
-func findMany(collection string, filter bson.M, limit int64) (*mongo.Cursor, error) {
+func findMany(collection string, filter bson.M, limit int64) (Cursor, error) {
 	if len(collection) < 1 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -425,7 +515,7 @@ func findMany(collection string, filter bson.M, limit int64) (*mongo.Cursor, err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
-	var cursor *mongo.Cursor
+	var cursor Cursor
 	var err error
 	if limit > 0 {
 		cursor, err = c.Find(ctx, filter, options.Find().SetSort(bson.M{TimeCreated: -1}).SetLimit(limit))
@@ -451,7 +541,7 @@ func getCountByFilter(collection string, filter bson.M) (int64, error) {
 	return c.CountDocuments(ctx, filter)
 }
 
-func findManyByOpts(collection string, filter bson.M, opts *options.FindOptions) (*mongo.Cursor, error) {
+func findManyByOpts(collection string, filter bson.M, opts *options.FindOptions) (Cursor, error) {
 	if len(collection) < 1 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -468,7 +558,7 @@ func findManyByOpts(collection string, filter bson.M, opts *options.FindOptions)
 	return cursor, nil
 }
 
-func findAllByOpts(collection string, opts *options.FindOptions) (*mongo.Cursor, error) {
+func findAllByOpts(collection string, opts *options.FindOptions) (Cursor, error) {
 	if len(collection) < 1 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -486,7 +576,7 @@ func findAllByOpts(collection string, opts *options.FindOptions) (*mongo.Cursor,
 	return cursor, nil
 }
 
-func findAllEnable(collection string, limit int64) (*mongo.Cursor, error) {
+func findAllEnable(collection string, limit int64) (Cursor, error) {
 	if len(collection) < 1 {
 		return nil, errors.New("the collection is empty")
 	}
@@ -497,7 +587,7 @@ func findAllEnable(collection string, limit int64) (*mongo.Cursor, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
 	filter := bson.M{TimeDeleted: 0}
-	var cursor *mongo.Cursor
+	var cursor Cursor
 	var err error
 	if limit > 0 {
 		cursor, err = c.Find(ctx, filter, options.Find().SetSort(bson.M{TimeCreated: -1}).SetLimit(limit))
@@ -528,17 +618,15 @@ func dropOne(collection string) error {
 	return nil
 }
 
-func copyOne(collection string) (*mongo.Collection, error) {
+func createIndex(collection string, keys bson.D, unique bool) error {
 	if len(collection) < 1 {
-		return nil, errors.New("the collection is empty")
+		return errors.New("the collection is empty")
 	}
 	c := noSql.Collection(collection)
 	if c == nil {
-		return nil, errors.New("can not found the collection of" + collection)
-	}
-	tmp, err := c.Clone()
-	if err != nil {
-		return nil, err
+		return errors.New("can not found the collection of" + collection)
 	}
-	return tmp, nil
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	return c.CreateIndex(ctx, &Index{Name: indexName(keys), Keys: keys, Unique: unique})
 }
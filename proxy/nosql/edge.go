@@ -40,10 +40,11 @@ func CreateVEdge(info *VEdge) error {
 }
 
 func GetAllVEdges() ([]*VEdge, error) {
-	cursor, err1 := findAll(TableEdge, 0)
+	cursor, err1 := findMany(TableEdge, bson.M{TimeDeleted: 0}, 0)
 	if err1 != nil {
 		return nil, err1
 	}
+	defer cursor.Close(context.Background())
 	var items = make([]*VEdge, 0, 100)
 	for cursor.Next(context.Background()) {
 		var node = new(VEdge)
@@ -102,6 +103,28 @@ func GetVEdgesBySource(uid string) ([]*VEdge, error) {
 	return items, nil
 }
 
+/**
+目标为指定实体的关系
+*/
+func GetVEdgesByTarget(entity string) ([]*VEdge, error) {
+	var items = make([]*VEdge, 0, 20)
+	filter := bson.M{"target.entity": entity, TimeDeleted: 0}
+	cursor, err1 := findMany(TableEdge, filter, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(VEdge)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func GetVEdgesByCenter(uid string) ([]*VEdge, error) {
 	var items = make([]*VEdge, 0, 20)
 	filter := bson.M{"center": uid, TimeDeleted: 0}
@@ -137,3 +160,14 @@ func RemoveEdge(uid string, operator string) error {
 	_, err := removeOne(TableEdge, uid, operator)
 	return err
 }
+
+/**
+用快照恢复关系，已经删除的关系会重新启用
+*/
+func RestoreVEdge(info *VEdge, operator string) error {
+	msg := bson.M{"name": info.Name, "remark": info.Remark, "catalog": info.Catalog, "target": info.Target,
+		"source": info.Source, "direction": info.Direction, "weight": info.Weight, "type": info.Type,
+		"operator": operator, TimeDeleted: 0, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableEdge, info.UID.Hex(), msg)
+	return err
+}
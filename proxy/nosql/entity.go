@@ -104,7 +104,7 @@ func GetEntity(table, uid string) (*Entity, error) {
 		return nil, err1
 	}
 	model.Table = table
-	if model.DeleteTime.UnixNano() > 100 {
+	if model.Deleted > 0 || model.DeleteTime.UnixNano() > 100 {
 		return nil, errors.New("the entity had deleted")
 	}
 	return model, nil
@@ -177,7 +177,7 @@ func GetEntityByMark(table, mark string) (*Entity, error) {
 }
 
 func GetEntitiesByProp(table, key, value string) ([]*Entity, error) {
-	msg := bson.M{"props": bson.M{"$elemMatch": bson.M{"key": key, "values": bson.M{"$elemMatch": bson.M{"name": value}}}}}
+	msg := bson.M{"props": bson.M{"$elemMatch": bson.M{"key": key, "values": bson.M{"$elemMatch": bson.M{"name": value}}}}, TimeDeleted: 0}
 	cursor, err1 := findMany(table, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -195,8 +195,31 @@ func GetEntitiesByProp(table, key, value string) ([]*Entity, error) {
 	return items, nil
 }
 
+/**
+属性值引用了指定实体的实体
+*/
+func GetEntitiesByPropEntity(table, entity string) ([]*Entity, error) {
+	msg := bson.M{"props": bson.M{"$elemMatch": bson.M{"values": bson.M{"$elemMatch": bson.M{"uid": entity}}}}, TimeDeleted: 0}
+	cursor, err1 := findMany(table, msg, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	var items = make([]*Entity, 0, 10)
+	for cursor.Next(context.Background()) {
+		var node = new(Entity)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			node.Table = table
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func GetEntitiesByProp2(table, value string) ([]*Entity, error) {
-	msg := bson.M{"props": bson.M{"$elemMatch": bson.M{"values": bson.M{"$elemMatch": bson.M{"name": value}}}}}
+	msg := bson.M{"props": bson.M{"$elemMatch": bson.M{"values": bson.M{"$elemMatch": bson.M{"name": value}}}}, TimeDeleted: 0}
 	cursor, err1 := findMany(table, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -215,7 +238,7 @@ func GetEntitiesByProp2(table, value string) ([]*Entity, error) {
 }
 
 func GetEntitiesByTag(table, value string) ([]*Entity, error) {
-	msg := bson.M{"tags": value}
+	msg := bson.M{"tags": value, TimeDeleted: 0}
 	cursor, err1 := findMany(table, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -234,7 +257,7 @@ func GetEntitiesByTag(table, value string) ([]*Entity, error) {
 }
 
 func GetEntitiesByOwnerTag(table, scene, value string) ([]*Entity, error) {
-	msg := bson.M{"scene": scene, "tags": value}
+	msg := bson.M{"scene": scene, "tags": value, TimeDeleted: 0}
 	cursor, err1 := findMany(table, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -253,7 +276,7 @@ func GetEntitiesByOwnerTag(table, scene, value string) ([]*Entity, error) {
 }
 
 func GetEntitiesByOwnerProp(table, scene, value string) ([]*Entity, error) {
-	msg := bson.M{"scene": scene, "props": bson.M{"$elemMatch": bson.M{"values": bson.M{"$elemMatch": bson.M{"name": value}}}}}
+	msg := bson.M{"scene": scene, "props": bson.M{"$elemMatch": bson.M{"values": bson.M{"$elemMatch": bson.M{"name": value}}}}, TimeDeleted: 0}
 	cursor, err1 := findMany(table, msg, 0)
 	if err1 != nil {
 		return nil, err1
@@ -718,3 +741,27 @@ func SubtractEntityProperty(table, uid string, key string) error {
 	_, err := removeElement(table, uid, msg)
 	return err
 }
+
+/**
+用快照恢复实体的可编辑字段
+*/
+func RestoreEntity(table string, info *Entity, operator string) error {
+	msg := bson.M{"name": info.Name, "letters": info.FirstLetters, "desc": info.Description, "summary": info.Summary,
+		"cover": info.Cover, "thumb": info.Thumb, "concept": info.Concept, "add": info.Add, "mark": info.Mark,
+		"quote": info.Quote, "tags": info.Tags, "synonyms": info.Synonyms, "props": info.Properties,
+		"operator": operator, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(table, info.UID.Hex(), msg)
+	return err
+}
+
+/**
+更新实体的指定字段，key为数据库中的字段名
+*/
+func UpdateEntityFields(table, uid, operator string, fields bson.M) error {
+	msg := bson.M{"operator": operator, TimeUpdated: time.Now().Unix()}
+	for key, value := range fields {
+		msg[key] = value
+	}
+	_, err := updateOne(table, uid, msg)
+	return err
+}
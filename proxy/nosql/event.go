@@ -2,6 +2,7 @@ package nosql
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -68,6 +69,9 @@ func GetEvent(uid string) (*Event, error) {
 	if err1 != nil {
 		return nil, err1
 	}
+	if model.Deleted > 0 {
+		return nil, errors.New("the event had deleted")
+	}
 	return model, nil
 }
 
@@ -172,6 +176,25 @@ func GetEventsByEntity(parent string) ([]*Event, error) {
 	return items, nil
 }
 
+func GetAllEvents() ([]*Event, error) {
+	var items = make([]*Event, 0, 100)
+	filter := bson.M{TimeDeleted: 0}
+	cursor, err1 := findMany(TableEvent, filter, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Event)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func GetEventsBySubtypeEntity(parent string, tp uint8) ([]*Event, error) {
 	var items = make([]*Event, 0, 20)
 	filter := bson.M{"entity": parent, "subtype": tp, TimeDeleted: 0}
@@ -346,7 +369,7 @@ func GetPendingEventsByQuote(quote string) ([]*Event, error) {
 
 func GetEventsByDuration(quote string, from, to int64) ([]*Event, error) {
 	var items = make([]*Event, 0, 20)
-	filter := bson.M{"quote": quote, TimeCreated: bson.M{"$gt": from, "$lt": to}}
+	filter := bson.M{"quote": quote, TimeCreated: bson.M{"$gt": from, "$lt": to}, TimeDeleted: 0}
 	cursor, err1 := findMany(TableEvent, filter, 0)
 	if err1 != nil {
 		return nil, err1
@@ -24,6 +24,8 @@ type Examine struct {
 	Target string `json:"target" bson:"target"`
 	Value  string `json:"value" bson:"value"`
 	Status uint8  `json:"status" bson:"status"`
+	Base   int64  `json:"base" bson:"base"`     //提交时实体的更新时间，用于检查冲突
+	Remark string `json:"remark" bson:"remark"` //审核人的意见
 }
 
 func CreateExamine(info *Examine) error {
@@ -66,6 +68,39 @@ func GetExamineBy(target, key string, st, tp uint8) (*Examine, error) {
 	return model, nil
 }
 
+func GetExamineByCreator(target, key, creator string, st, tp uint8) (*Examine, error) {
+	filter := bson.M{"target": target, "key": key, "creator": creator, "status": st, "type": tp, TimeDeleted: 0}
+	result, err := findOneBy(TableExamine, filter)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Examine)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+func GetExaminesByKey(target, key string, st, tp uint8) ([]*Examine, error) {
+	var items = make([]*Examine, 0, 5)
+	filter := bson.M{"target": target, "key": key, "status": st, "type": tp, TimeDeleted: 0}
+	cursor, err1 := findMany(TableExamine, filter, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Examine)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func GetExaminesByTarget(target string) ([]*Examine, error) {
 	var items = make([]*Examine, 0, 20)
 	filter := bson.M{"target": target, TimeDeleted: 0}
@@ -141,6 +176,25 @@ func GetExaminesByType(target string, tp, st uint8) ([]*Examine, error) {
 	return items, nil
 }
 
+func GetExaminesByState(st uint8) ([]*Examine, error) {
+	var items = make([]*Examine, 0, 20)
+	filter := bson.M{"status": st, TimeDeleted: 0}
+	cursor, err1 := findMany(TableExamine, filter, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Examine)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
 func GetExaminesByValueType(val string, tp, st uint8) ([]*Examine, error) {
 	var items = make([]*Examine, 0, 20)
 	msg := bson.M{"value": val, "type": tp, "status": st, TimeDeleted: 0}
@@ -165,14 +219,25 @@ func RemoveExamine(uid, operator string) error {
 	return err
 }
 
-func UpdateExamineStatus(uid, operator string, st uint8) error {
-	msg := bson.M{"status": st, "operator": operator, TimeUpdated: time.Now().Unix()}
-	_, err := updateOne(TableExamine, uid, msg)
-	return err
+/**
+只有状态为from时才修改，返回是否修改成功，避免同时审核时重复处理
+*/
+func UpdateExamineStatus(uid, operator, remark string, from, st uint8) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": objID, "status": from}
+	msg := bson.M{"status": st, "operator": operator, "remark": remark, TimeUpdated: time.Now().Unix()}
+	num, err := updateOneBy(TableExamine, filter, bson.M{"$set": msg})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
 }
 
-func UpdateExamineValue(uid, val, operator string) error {
-	msg := bson.M{"value": val, "operator": operator, TimeUpdated: time.Now().Unix()}
+func UpdateExamineValue(uid, val, operator string, base int64) error {
+	msg := bson.M{"value": val, "operator": operator, "base": base, TimeUpdated: time.Now().Unix()}
 	_, err := updateOne(TableExamine, uid, msg)
 	return err
 }
@@ -0,0 +1,727 @@
+package nosql
+
+import (
+	"bytes"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+内存存储使用的bson过滤条件和更新语句解析，支持服务中用到的操作符
+*/
+
+func toDocument(v interface{}) (bson.M, error) {
+	if v == nil {
+		return bson.M{}, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeValue(doc).(bson.M), nil
+}
+
+func fromDocument(doc bson.M, val interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, val)
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.D:
+		doc := make(bson.M, len(t))
+		for _, e := range t {
+			doc[e.Key] = normalizeValue(e.Value)
+		}
+		return doc
+	case primitive.M:
+		doc := make(bson.M, len(t))
+		for key, item := range t {
+			doc[key] = normalizeValue(item)
+		}
+		return doc
+	case primitive.A:
+		list := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			list = append(list, normalizeValue(item))
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			list = append(list, normalizeValue(item))
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.M:
+		doc := make(bson.M, len(t))
+		for key, item := range t {
+			doc[key] = cloneValue(item)
+		}
+		return doc
+	case []interface{}:
+		list := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			list = append(list, cloneValue(item))
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func isOperatorDocument(v interface{}) (bson.M, bool) {
+	doc, ok := v.(bson.M)
+	if !ok || len(doc) < 1 {
+		return nil, false
+	}
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func matchDocument(doc bson.M, filter bson.M) bool {
+	for key, cond := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			subs, ok := cond.([]interface{})
+			if !ok {
+				return false
+			}
+			count := 0
+			for _, sub := range subs {
+				item, ok := sub.(bson.M)
+				if ok && matchDocument(doc, item) {
+					count += 1
+				}
+			}
+			if key == "$and" && count != len(subs) {
+				return false
+			}
+			if key == "$or" && count < 1 {
+				return false
+			}
+			if key == "$nor" && count > 0 {
+				return false
+			}
+		default:
+			values := lookupPath(doc, strings.Split(key, "."))
+			if !matchCondition(values, cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func lookupPath(value interface{}, parts []string) []interface{} {
+	if len(parts) < 1 {
+		return []interface{}{value}
+	}
+	switch t := value.(type) {
+	case bson.M:
+		next, ok := t[parts[0]]
+		if !ok {
+			return nil
+		}
+		return lookupPath(next, parts[1:])
+	case []interface{}:
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx >= 0 && idx < len(t) {
+				return lookupPath(t[idx], parts[1:])
+			}
+			return nil
+		}
+		list := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			if _, ok := item.(bson.M); ok {
+				list = append(list, lookupPath(item, parts)...)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func expandValues(values []interface{}) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		list = append(list, v)
+		if arr, ok := v.([]interface{}); ok {
+			list = append(list, arr...)
+		}
+	}
+	return list
+}
+
+func matchCondition(values []interface{}, cond interface{}) bool {
+	ops, ok := isOperatorDocument(cond)
+	if !ok {
+		return matchEquals(values, cond)
+	}
+	for op, arg := range ops {
+		if !matchOperator(values, op, arg, ops) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchEquals(values []interface{}, target interface{}) bool {
+	if target == nil {
+		if len(values) < 1 {
+			return true
+		}
+		for _, v := range values {
+			if v == nil {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range expandValues(values) {
+		if equalValues(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperator(values []interface{}, op string, arg interface{}, ops bson.M) bool {
+	switch op {
+	case "$eq":
+		return matchEquals(values, arg)
+	case "$ne":
+		return !matchEquals(values, arg)
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, v := range expandValues(values) {
+			c, ok := compareValues(v, arg)
+			if !ok {
+				continue
+			}
+			if (op == "$gt" && c > 0) || (op == "$gte" && c >= 0) || (op == "$lt" && c < 0) || (op == "$lte" && c <= 0) {
+				return true
+			}
+		}
+		return false
+	case "$in", "$nin":
+		arr, _ := arg.([]interface{})
+		had := false
+		for _, item := range arr {
+			if matchEquals(values, item) {
+				had = true
+				break
+			}
+		}
+		if op == "$in" {
+			return had
+		}
+		return !had
+	case "$exists":
+		want, _ := arg.(bool)
+		return want == (len(values) > 0)
+	case "$size":
+		num, ok := toFloat(arg)
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if arr, ok := v.([]interface{}); ok && float64(len(arr)) == num {
+				return true
+			}
+		}
+		return false
+	case "$regex":
+		reg := compileRegex(arg, ops["$options"])
+		if reg == nil {
+			return false
+		}
+		for _, v := range expandValues(values) {
+			if str, ok := v.(string); ok && reg.MatchString(str) {
+				return true
+			}
+		}
+		return false
+	case "$options":
+		return true
+	case "$not":
+		return !matchCondition(values, arg)
+	case "$elemMatch":
+		sub, ok := arg.(bson.M)
+		if !ok {
+			return false
+		}
+		_, isOps := isOperatorDocument(sub)
+		for _, v := range values {
+			arr, ok := v.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, elem := range arr {
+				if isOps {
+					if matchCondition([]interface{}{elem}, sub) {
+						return true
+					}
+				} else if doc, ok := elem.(bson.M); ok && matchDocument(doc, sub) {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func compileRegex(pattern, opts interface{}) *regexp.Regexp {
+	var expr string
+	var flags string
+	switch t := pattern.(type) {
+	case string:
+		expr = t
+	case primitive.Regex:
+		expr = t.Pattern
+		flags = t.Options
+	default:
+		return nil
+	}
+	if str, ok := opts.(string); ok {
+		flags = flags + str
+	}
+	prefix := ""
+	for _, f := range flags {
+		if f == 'i' || f == 'm' || f == 's' {
+			prefix = prefix + string(f)
+		}
+	}
+	if len(prefix) > 0 {
+		expr = "(?" + prefix + ")" + expr
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	return reg
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	default:
+		return 0, false
+	}
+}
+
+func isInteger(v interface{}) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(x[:], y[:]), true
+	case primitive.DateTime:
+		y, ok := b.(primitive.DateTime)
+		if !ok {
+			return 0, false
+		}
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		} else if !x {
+			return -1, true
+		}
+		return 1, true
+	default:
+		return 0, false
+	}
+}
+
+func equalValues(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sortDocuments(list []bson.M, order interface{}) {
+	type sortKey struct {
+		parts []string
+		asc   bool
+	}
+	keys := make([]sortKey, 0, 2)
+	appendKey := func(path string, val interface{}) {
+		num, _ := toFloat(val)
+		keys = append(keys, sortKey{parts: strings.Split(path, "."), asc: num >= 0})
+	}
+	switch t := order.(type) {
+	case primitive.D:
+		for _, e := range t {
+			appendKey(e.Key, e.Value)
+		}
+	case primitive.M:
+		for key, val := range t {
+			appendKey(key, val)
+		}
+	case map[string]interface{}:
+		for key, val := range t {
+			appendKey(key, val)
+		}
+	}
+	if len(keys) < 1 {
+		return
+	}
+	first := func(doc bson.M, parts []string) interface{} {
+		values := lookupPath(doc, parts)
+		if len(values) < 1 {
+			return nil
+		}
+		return values[0]
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		for _, key := range keys {
+			a := first(list[i], key.parts)
+			b := first(list[j], key.parts)
+			var c int
+			if a == nil && b == nil {
+				c = 0
+			} else if a == nil {
+				c = -1
+			} else if b == nil {
+				c = 1
+			} else {
+				c, _ = compareValues(a, b)
+			}
+			if c == 0 {
+				continue
+			}
+			if key.asc {
+				return c < 0
+			}
+			return c > 0
+		}
+		return false
+	})
+}
+
+/**
+按照投影返回文档的部分字段，值为1时只返回这些字段和_id，值为0时去掉这些字段，
+不支持$slice等投影操作符，路径只能经过子文档，不能经过数组
+*/
+func projectDocument(doc bson.M, projection interface{}) (bson.M, error) {
+	fields, err := toDocument(projection)
+	if err != nil || len(fields) < 1 {
+		return doc, err
+	}
+	include := false
+	for key, val := range fields {
+		if _, ok := isOperatorDocument(val); ok {
+			return nil, errors.New("not support the projection operator of " + key)
+		}
+		if key != "_id" && projectionFlag(val) {
+			include = true
+		}
+	}
+	if !include {
+		for key := range fields {
+			unsetPath(doc, key)
+		}
+		return doc, nil
+	}
+	tmp := make(bson.M, len(fields)+1)
+	if id, ok := doc["_id"]; ok {
+		if flag, had := fields["_id"]; !had || projectionFlag(flag) {
+			tmp["_id"] = id
+		}
+	}
+	for key, val := range fields {
+		if key == "_id" || !projectionFlag(val) {
+			continue
+		}
+		if value, ok := documentAt(doc, strings.Split(key, ".")); ok {
+			_ = setPath(tmp, key, value)
+		}
+	}
+	return tmp, nil
+}
+
+func projectionFlag(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	num, _ := toFloat(v)
+	return num != 0
+}
+
+func documentAt(doc bson.M, parts []string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range parts {
+		t, ok := current.(bson.M)
+		if !ok {
+			return nil, false
+		}
+		current, ok = t[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func applyUpdate(doc bson.M, update bson.M, insert bool) (bson.M, error) {
+	if _, ok := isOperatorDocument(update); !ok {
+		next := cloneValue(update).(bson.M)
+		next["_id"] = doc["_id"]
+		return next, nil
+	}
+	next := cloneValue(doc).(bson.M)
+	for op, arg := range update {
+		fields, ok := arg.(bson.M)
+		if !ok {
+			return nil, errors.New("the argument of " + op + " must be a document")
+		}
+		for path, val := range fields {
+			var err error
+			switch op {
+			case "$set":
+				err = setPath(next, path, cloneValue(val))
+			case "$setOnInsert":
+				if insert {
+					err = setPath(next, path, cloneValue(val))
+				}
+			case "$max", "$min":
+				values := lookupPath(next, strings.Split(path, "."))
+				if len(values) < 1 || values[0] == nil {
+					err = setPath(next, path, cloneValue(val))
+					break
+				}
+				num, ok := compareValues(val, values[0])
+				if ok && ((op == "$max" && num > 0) || (op == "$min" && num < 0)) {
+					err = setPath(next, path, cloneValue(val))
+				}
+			case "$unset":
+				unsetPath(next, path)
+			case "$inc":
+				values := lookupPath(next, strings.Split(path, "."))
+				var current interface{} = int32(0)
+				if len(values) > 0 {
+					current = values[0]
+				}
+				var sum interface{}
+				sum, err = addNumbers(current, val)
+				if err == nil {
+					err = setPath(next, path, sum)
+				}
+			case "$push", "$addToSet":
+				var arr []interface{}
+				arr, err = arrayAt(next, path)
+				if err != nil {
+					break
+				}
+				items := []interface{}{val}
+				if each, ok := val.(bson.M); ok {
+					if list, ok := each["$each"].([]interface{}); ok {
+						items = list
+					}
+				}
+				for _, item := range items {
+					if op == "$addToSet" && matchEquals([]interface{}{arr}, item) {
+						continue
+					}
+					arr = append(arr, cloneValue(item))
+				}
+				err = setPath(next, path, arr)
+			case "$pull":
+				var arr []interface{}
+				arr, err = arrayAt(next, path)
+				if err != nil {
+					break
+				}
+				rest := make([]interface{}, 0, len(arr))
+				for _, elem := range arr {
+					if !pullMatched(elem, val) {
+						rest = append(rest, elem)
+					}
+				}
+				err = setPath(next, path, rest)
+			default:
+				err = errors.New("not support the update operator " + op)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return next, nil
+}
+
+func pullMatched(elem, cond interface{}) bool {
+	if _, ok := isOperatorDocument(cond); ok {
+		return matchCondition([]interface{}{elem}, cond)
+	}
+	if sub, ok := cond.(bson.M); ok {
+		if doc, ok := elem.(bson.M); ok {
+			return matchDocument(doc, sub)
+		}
+		return false
+	}
+	return equalValues(elem, cond)
+}
+
+func addNumbers(a, b interface{}) (interface{}, error) {
+	x, ok1 := toFloat(a)
+	y, ok2 := toFloat(b)
+	if !ok1 || !ok2 {
+		return nil, errors.New("can not apply $inc to a non-numeric value")
+	}
+	if isInteger(a) && isInteger(b) {
+		return int64(x) + int64(y), nil
+	}
+	return x + y, nil
+}
+
+func arrayAt(doc bson.M, path string) ([]interface{}, error) {
+	values := lookupPath(doc, strings.Split(path, "."))
+	if len(values) < 1 || values[0] == nil {
+		return make([]interface{}, 0, 1), nil
+	}
+	arr, ok := values[0].([]interface{})
+	if !ok {
+		return nil, errors.New("the field " + path + " is not an array")
+	}
+	list := make([]interface{}, 0, len(arr)+1)
+	list = append(list, arr...)
+	return list, nil
+}
+
+func setPath(doc bson.M, path string, val interface{}) error {
+	parts := strings.Split(path, ".")
+	var current interface{} = doc
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch t := current.(type) {
+		case bson.M:
+			if last {
+				t[part] = val
+				return nil
+			}
+			next, ok := t[part]
+			if !ok || next == nil {
+				next = bson.M{}
+				t[part] = next
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return errors.New("can not set the field " + path)
+			}
+			if last {
+				t[idx] = val
+				return nil
+			}
+			current = t[idx]
+		default:
+			return errors.New("can not set the field " + path)
+		}
+	}
+	return nil
+}
+
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	var current interface{} = doc
+	for i, part := range parts {
+		t, ok := current.(bson.M)
+		if !ok {
+			return
+		}
+		if i == len(parts)-1 {
+			delete(t, part)
+			return
+		}
+		current = t[part]
+	}
+}
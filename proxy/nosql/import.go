@@ -0,0 +1,114 @@
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+/**
+已经提交的导入任务，用于整体回滚
+*/
+type ImportJob struct {
+	UID         primitive.ObjectID `bson:"_id"`
+	ID          uint64             `json:"id" bson:"id"`
+	CreatedTime time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedTime time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeleteTime  time.Time          `json:"deleteAt" bson:"deleteAt"`
+	Created     int64              `json:"created" bson:"created"`
+	Updated     int64              `json:"updated" bson:"updated"`
+	Deleted     int64              `json:"deleted" bson:"deleted"`
+	Creator     string             `json:"creator" bson:"creator"`
+	Operator    string             `json:"operator" bson:"operator"`
+
+	Kind   string           `json:"kind" bson:"kind"`
+	Format string           `json:"format" bson:"format"`
+	Owner  string           `json:"owner" bson:"owner"`
+	Match  string           `json:"match" bson:"match"`
+	Status uint8            `json:"status" bson:"status"`
+	Total  uint32           `json:"total" bson:"total"`
+	Items  []*ImportJobItem `json:"items" bson:"items"`
+}
+
+/**
+导入任务中每一行的处理结果，更新的实体保存更新前的快照，Updated为导入写入后实体的更新时间，
+Done表示该条目已经回滚
+*/
+type ImportJobItem struct {
+	Row      int     `json:"row" bson:"row"`
+	Action   string  `json:"action" bson:"action"`
+	Entity   string  `json:"entity" bson:"entity"`
+	Table    string  `json:"table" bson:"table"`
+	Snapshot *Entity `json:"snapshot" bson:"snapshot"`
+	Updated  int64   `json:"updated" bson:"updated"`
+	Done     bool    `json:"done" bson:"done"`
+}
+
+func CreateImportJob(info *ImportJob) error {
+	_, err := insertOne(TableImportJob, info)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetImportJobNextID() uint64 {
+	num, _ := getSequenceNext(TableImportJob)
+	return num
+}
+
+func GetImportJob(uid string) (*ImportJob, error) {
+	result, err := findOne(TableImportJob, uid)
+	if err != nil {
+		return nil, err
+	}
+	model := new(ImportJob)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+func GetImportJobsByOwner(owner string, num int64) ([]*ImportJob, error) {
+	var items = make([]*ImportJob, 0, 20)
+	filter := bson.M{"owner": owner, TimeDeleted: 0}
+	opts := options.Find().SetSort(bson.D{{Key: TimeCreated, Value: -1}}).SetLimit(num)
+	cursor, err1 := findManyByOpts(TableImportJob, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(ImportJob)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func AppendImportJobItem(uid string, item *ImportJobItem) error {
+	_, err := appendElement(TableImportJob, uid, bson.M{"items": item})
+	return err
+}
+
+func UpdateImportJobStatus(uid string, st uint8, operator string) error {
+	msg := bson.M{"status": st, "operator": operator, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableImportJob, uid, msg)
+	return err
+}
+
+/**
+标记第index个条目已经回滚，再次回滚时跳过
+*/
+func UpdateImportJobItemDone(uid string, index int) error {
+	msg := bson.M{fmt.Sprintf("items.%d.done", index): true, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableImportJob, uid, msg)
+	return err
+}
@@ -0,0 +1,152 @@
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"omo.msa.vocabulary/tool"
+	"strings"
+	"sync"
+)
+
+/**
+索引同步的结果，名称为表名.索引名
+*/
+type IndexReport struct {
+	Created []string `json:"created"`
+	Extra   []string `json:"extra"` //数据库中存在但是没有声明的索引，只报告不删除
+	Failed  []string `json:"failed"`
+}
+
+var indexCtx struct {
+	lock sync.Mutex
+	last *IndexReport
+}
+
+/**
+按照mongodb的默认规则生成索引名称，例如scene_1_name_1
+*/
+func indexName(keys bson.D) string {
+	arr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		arr = append(arr, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(arr, "_")
+}
+
+func newIndex(unique bool, fields ...string) *Index {
+	keys := make(bson.D, 0, len(fields))
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	return &Index{Name: indexName(keys), Keys: keys, Unique: unique}
+}
+
+func entityIndexes() []*Index {
+	list := make([]*Index, 0, 12)
+	list = append(list, newIndex(false, "name"))
+	list = append(list, newIndex(false, "scene", "name"))
+	list = append(list, newIndex(false, "scene", "status"))
+	list = append(list, newIndex(false, "scene", "concept"))
+	list = append(list, newIndex(false, "concept"))
+	list = append(list, newIndex(false, "status"))
+	list = append(list, newIndex(false, "letters", "relates"))
+	list = append(list, newIndex(false, "relates"))
+	list = append(list, newIndex(false, "mark"))
+	list = append(list, newIndex(false, "quote"))
+	list = append(list, newIndex(false, "tags"))
+	list = append(list, newIndex(false, "props.key"))
+	return list
+}
+
+/**
+声明的索引，实体表是动态的，使用相同的索引
+*/
+func indexCatalog(entities []string) map[string][]*Index {
+	catalog := make(map[string][]*Index, 20)
+	for _, table := range entities {
+		catalog[table] = entityIndexes()
+	}
+	archived := newIndex(true, "entity")
+	archived.Partial = bson.M{TimeDeleted: 0}
+	catalog[TableSequence] = []*Index{newIndex(true, "name")}
+	catalog[TableMigration] = []*Index{newIndex(true, "version")}
+	catalog[TableArchived] = []*Index{archived, newIndex(false, "scene", "concept"), newIndex(false, "name")}
+	catalog[TableArchivedVersion] = []*Index{newIndex(true, "entity", "version")}
+	catalog[TableRevision] = []*Index{newIndex(true, "entity", "version")}
+	catalog[TableEvent] = []*Index{newIndex(false, "entity", "type"), newIndex(false, "quote"), newIndex(false, "owner"),
+		newIndex(false, "targets")}
+	catalog[TableEdge] = []*Index{newIndex(false, "center"), newIndex(false, "source"), newIndex(false, "target.entity")}
+	catalog[TableBox] = []*Index{newIndex(false, "owner", "type"), newIndex(false, "name"), newIndex(false, "keywords"),
+		newIndex(false, "contents.keyword"), newIndex(false, "concept")}
+	catalog[TableConcept] = []*Index{newIndex(false, "parent"), newIndex(false, "name"), newIndex(false, "attributes")}
+	catalog[TableAttribute] = []*Index{newIndex(false, "key"), newIndex(false, "name")}
+	catalog[TableRelation] = []*Index{newIndex(false, "parent"), newIndex(false, "name")}
+	catalog[TableExamine] = []*Index{newIndex(false, "target", "status"), newIndex(false, "status", "type"),
+		newIndex(false, "target", "key")}
+	catalog[TableRecord] = []*Index{newIndex(false, "entity"), newIndex(false, "to")}
+	catalog[TableGraphTask] = []*Index{newIndex(false, "status", "next")}
+	catalog[TableReview] = []*Index{newIndex(false, "status", "reviewer"), newIndex(false, "box", "status"),
+		newIndex(false, "kind", "target")}
+	catalog[TableImportJob] = []*Index{newIndex(false, "owner", "status")}
+	return catalog
+}
+
+/**
+按照声明创建缺少的索引，并报告数据库中多余的索引，创建失败的索引(例如存在重复数据的唯一索引)不影响启动
+*/
+func EnsureIndexes(entities []string) *IndexReport {
+	report := &IndexReport{Created: make([]string, 0, 10), Extra: make([]string, 0, 2), Failed: make([]string, 0, 1)}
+	for table, indexes := range indexCatalog(entities) {
+		c := noSql.Collection(table)
+		ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+		names, err := c.IndexNames(ctx)
+		cancel()
+		if err != nil {
+			report.Failed = append(report.Failed, table)
+			log.Warnf("list the indexes failed that table = %s; err = %s", table, err.Error())
+			continue
+		}
+		for _, index := range indexes {
+			if tool.HasItem(names, index.Name) {
+				continue
+			}
+			ctx, cancel = context.WithTimeout(context.Background(), timeOut)
+			err = c.CreateIndex(ctx, index)
+			cancel()
+			if err != nil {
+				report.Failed = append(report.Failed, table+"."+index.Name)
+				log.Warnf("create the index failed that table = %s; index = %s; err = %s", table, index.Name, err.Error())
+			} else {
+				report.Created = append(report.Created, table+"."+index.Name)
+			}
+		}
+		for _, name := range names {
+			if name != "_id_" && !hadIndex(indexes, name) {
+				report.Extra = append(report.Extra, table+"."+name)
+				log.Warnf("the index is not declared that table = %s; index = %s", table, name)
+			}
+		}
+	}
+	log.Infof("ensure the indexes done that created = %d; extra = %d; failed = %d", len(report.Created), len(report.Extra), len(report.Failed))
+	indexCtx.lock.Lock()
+	indexCtx.last = report
+	indexCtx.lock.Unlock()
+	return report
+}
+
+func GetIndexReport() *IndexReport {
+	indexCtx.lock.Lock()
+	defer indexCtx.lock.Unlock()
+	return indexCtx.last
+}
+
+func hadIndex(list []*Index, name string) bool {
+	for _, item := range list {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,495 @@
+package nosql
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+内存存储，不依赖数据库，用于本地开发和测试
+*/
+type memoryStore struct {
+	lock    sync.RWMutex
+	tables  map[string]*memoryCollection
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	lock  sync.RWMutex
+	files map[string][]byte
+}
+
+type memoryCollection struct {
+	lock    sync.RWMutex
+	name    string
+	docs    []bson.M
+	indexes []*memoryIndex
+}
+
+type memoryIndex struct {
+	name    string
+	fields  []string
+	unique  bool
+	partial bson.M
+}
+
+type memoryCursor struct {
+	docs    []bson.M
+	index   int
+	current bson.M
+}
+
+type memoryResult struct {
+	doc bson.M
+	err error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tables: make(map[string]*memoryCollection, 20), buckets: make(map[string]*memoryBucket, 2)}
+}
+
+func (mine *memoryStore) Collection(name string) Collection {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	c, ok := mine.tables[name]
+	if !ok {
+		c = &memoryCollection{name: name, docs: make([]bson.M, 0, 100)}
+		mine.tables[name] = c
+	}
+	return c
+}
+
+func (mine *memoryStore) CollectionNames(ctx context.Context) ([]string, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]string, 0, len(mine.tables))
+	for name := range mine.tables {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (mine *memoryStore) Bucket(name string) Bucket {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	b, ok := mine.buckets[name]
+	if !ok {
+		b = &memoryBucket{files: make(map[string][]byte, 10)}
+		mine.buckets[name] = b
+	}
+	return b
+}
+
+func (mine *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (mine *memoryBucket) Upload(ctx context.Context, name string, data []byte) (string, error) {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	id := primitive.NewObjectID().Hex()
+	mine.files[id] = append([]byte(nil), data...)
+	return id, nil
+}
+
+func (mine *memoryBucket) Download(ctx context.Context, id string) ([]byte, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	data, ok := mine.files[id]
+	if !ok {
+		return nil, errors.New("not found the file of " + id)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (mine *memoryBucket) Delete(ctx context.Context, id string) error {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	if _, ok := mine.files[id]; !ok {
+		return errors.New("not found the file of " + id)
+	}
+	delete(mine.files, id)
+	return nil
+}
+
+func (mine *memoryCollection) InsertOne(ctx context.Context, doc interface{}) (interface{}, error) {
+	item, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := item["_id"]
+	if !ok || id == nil {
+		id = primitive.NewObjectID()
+		item["_id"] = id
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	if mine.duplicated(item, -1) {
+		return nil, errors.New("duplicate key error of " + mine.name)
+	}
+	mine.docs = append(mine.docs, item)
+	return id, nil
+}
+
+func (mine *memoryCollection) FindOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) SingleResult {
+	one := options.MergeFindOneOptions(opts...)
+	var skip int64
+	if one.Skip != nil {
+		skip = *one.Skip
+	}
+	list, err := mine.query(filter, one.Sort, skip, 1)
+	if err != nil {
+		return &memoryResult{err: err}
+	}
+	if len(list) < 1 {
+		return &memoryResult{err: mongo.ErrNoDocuments}
+	}
+	doc, err := projectDocument(list[0], one.Projection)
+	if err != nil {
+		return &memoryResult{err: err}
+	}
+	return &memoryResult{doc: doc}
+}
+
+func (mine *memoryCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (Cursor, error) {
+	many := options.MergeFindOptions(opts...)
+	var skip, limit int64
+	if many.Skip != nil {
+		skip = *many.Skip
+	}
+	if many.Limit != nil {
+		limit = *many.Limit
+	}
+	list, err := mine.query(filter, many.Sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range list {
+		list[i], err = projectDocument(doc, many.Projection)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &memoryCursor{docs: list, index: 0}, nil
+}
+
+func (mine *memoryCollection) CountDocuments(ctx context.Context, filter bson.M, opts ...*options.CountOptions) (int64, error) {
+	list, err := mine.query(filter, nil, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(list)), nil
+}
+
+func (mine *memoryCollection) EstimatedDocumentCount(ctx context.Context) (int64, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	return int64(len(mine.docs)), nil
+}
+
+func (mine *memoryCollection) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	cond, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	change, err := toDocument(update)
+	if err != nil {
+		return 0, err
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	for i, db := range mine.docs {
+		if !matchDocument(db, cond) {
+			continue
+		}
+		next, er := applyUpdate(db, change, false)
+		if er != nil {
+			return 0, er
+		}
+		if reflect.DeepEqual(db, next) {
+			return 0, nil
+		}
+		if mine.duplicated(next, i) {
+			return 0, errors.New("duplicate key error of " + mine.name)
+		}
+		mine.docs[i] = next
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (mine *memoryCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, opts ...*options.FindOneAndUpdateOptions) SingleResult {
+	one := options.MergeFindOneAndUpdateOptions(opts...)
+	cond, err := toDocument(filter)
+	if err != nil {
+		return &memoryResult{err: err}
+	}
+	change, err := toDocument(update)
+	if err != nil {
+		return &memoryResult{err: err}
+	}
+	after := one.ReturnDocument != nil && *one.ReturnDocument == options.After
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	index := -1
+	if one.Sort != nil {
+		list := make([]bson.M, 0, 10)
+		for _, db := range mine.docs {
+			if matchDocument(db, cond) {
+				list = append(list, db)
+			}
+		}
+		sortDocuments(list, one.Sort)
+		if len(list) > 0 {
+			index = mine.indexOf(list[0])
+		}
+	} else {
+		for i, db := range mine.docs {
+			if matchDocument(db, cond) {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		if one.Upsert == nil || !*one.Upsert {
+			return &memoryResult{err: mongo.ErrNoDocuments}
+		}
+		seed := make(bson.M, len(cond)+1)
+		for key, val := range cond {
+			if _, ok := isOperatorDocument(val); !ok && key[0] != '$' {
+				_ = setPath(seed, key, cloneValue(val))
+			}
+		}
+		next, er := applyUpdate(seed, change, true)
+		if er != nil {
+			return &memoryResult{err: er}
+		}
+		if next["_id"] == nil {
+			next["_id"] = primitive.NewObjectID()
+		}
+		if mine.duplicated(next, -1) {
+			return &memoryResult{err: errors.New("duplicate key error of " + mine.name)}
+		}
+		mine.docs = append(mine.docs, next)
+		if !after {
+			return &memoryResult{err: mongo.ErrNoDocuments}
+		}
+		return &memoryResult{doc: cloneValue(next).(bson.M)}
+	}
+	db := mine.docs[index]
+	next, er := applyUpdate(db, change, false)
+	if er != nil {
+		return &memoryResult{err: er}
+	}
+	if mine.duplicated(next, index) {
+		return &memoryResult{err: errors.New("duplicate key error of " + mine.name)}
+	}
+	mine.docs[index] = next
+	if after {
+		return &memoryResult{doc: cloneValue(next).(bson.M)}
+	}
+	return &memoryResult{doc: cloneValue(db).(bson.M)}
+}
+
+func (mine *memoryCollection) DeleteOne(ctx context.Context, filter bson.M) (int64, error) {
+	cond, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	for i, db := range mine.docs {
+		if matchDocument(db, cond) {
+			mine.docs = append(mine.docs[:i], mine.docs[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (mine *memoryCollection) Drop(ctx context.Context) error {
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	mine.docs = make([]bson.M, 0, 100)
+	return nil
+}
+
+func (mine *memoryCollection) CreateIndex(ctx context.Context, index *Index) error {
+	partial, err := toDocument(index.Partial)
+	if err != nil {
+		return err
+	}
+	tmp := &memoryIndex{name: index.Name, unique: index.Unique, partial: partial, fields: make([]string, 0, len(index.Keys))}
+	for _, key := range index.Keys {
+		tmp.fields = append(tmp.fields, key.Key)
+	}
+	if len(tmp.name) < 1 {
+		tmp.name = indexName(index.Keys)
+	}
+	mine.lock.Lock()
+	defer mine.lock.Unlock()
+	for _, item := range mine.indexes {
+		if item.name == tmp.name {
+			return nil
+		}
+	}
+	if tmp.unique {
+		for i := 0; i < len(mine.docs); i += 1 {
+			for j := i + 1; j < len(mine.docs); j += 1 {
+				if tmp.conflict(mine.docs[i], mine.docs[j]) {
+					return errors.New("duplicate key error of " + mine.name)
+				}
+			}
+		}
+	}
+	mine.indexes = append(mine.indexes, tmp)
+	return nil
+}
+
+func (mine *memoryCollection) IndexNames(ctx context.Context) ([]string, error) {
+	mine.lock.RLock()
+	defer mine.lock.RUnlock()
+	list := make([]string, 0, len(mine.indexes)+1)
+	list = append(list, "_id_")
+	for _, item := range mine.indexes {
+		list = append(list, item.name)
+	}
+	return list, nil
+}
+
+/**
+两个文档是否违反唯一索引，不满足部分索引条件的文档不参与比较
+*/
+func (mine *memoryIndex) conflict(a, b bson.M) bool {
+	if !mine.unique {
+		return false
+	}
+	if len(mine.partial) > 0 && (!matchDocument(a, mine.partial) || !matchDocument(b, mine.partial)) {
+		return false
+	}
+	return sameFields(a, b, mine.fields)
+}
+
+func (mine *memoryCollection) indexOf(doc bson.M) int {
+	for i, db := range mine.docs {
+		if equalValues(db["_id"], doc["_id"]) {
+			return i
+		}
+	}
+	return -1
+}
+
+/**
+检查文档是否与其他文档的主键或者唯一索引冲突，skip为文档自身的位置
+*/
+func (mine *memoryCollection) duplicated(doc bson.M, skip int) bool {
+	for i, db := range mine.docs {
+		if i == skip {
+			continue
+		}
+		if equalValues(db["_id"], doc["_id"]) {
+			return true
+		}
+		for _, index := range mine.indexes {
+			if index.conflict(db, doc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameFields(a, b bson.M, fields []string) bool {
+	for _, field := range fields {
+		parts := strings.Split(field, ".")
+		x := lookupPath(a, parts)
+		y := lookupPath(b, parts)
+		var left, right interface{}
+		if len(x) > 0 {
+			left = x[0]
+		}
+		if len(y) > 0 {
+			right = y[0]
+		}
+		if !equalValues(left, right) {
+			return false
+		}
+	}
+	return true
+}
+
+func (mine *memoryCollection) query(filter bson.M, order interface{}, skip, limit int64) ([]bson.M, error) {
+	cond, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	mine.lock.RLock()
+	list := make([]bson.M, 0, 20)
+	for _, db := range mine.docs {
+		if matchDocument(db, cond) {
+			list = append(list, cloneValue(db).(bson.M))
+		}
+	}
+	mine.lock.RUnlock()
+	if order != nil {
+		sortDocuments(list, order)
+	}
+	if skip > 0 {
+		if skip >= int64(len(list)) {
+			return make([]bson.M, 0, 1), nil
+		}
+		list = list[skip:]
+	}
+	if limit > 0 && limit < int64(len(list)) {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (mine *memoryCursor) Next(ctx context.Context) bool {
+	if mine.index >= len(mine.docs) {
+		mine.current = nil
+		return false
+	}
+	mine.current = mine.docs[mine.index]
+	mine.index += 1
+	return true
+}
+
+func (mine *memoryCursor) Decode(val interface{}) error {
+	if mine.current == nil {
+		return errors.New("the cursor has no current document")
+	}
+	return fromDocument(mine.current, val)
+}
+
+func (mine *memoryCursor) Err() error {
+	return nil
+}
+
+func (mine *memoryCursor) Close(ctx context.Context) error {
+	mine.docs = nil
+	mine.current = nil
+	return nil
+}
+
+func (mine *memoryResult) Decode(val interface{}) error {
+	if mine.err != nil {
+		return mine.err
+	}
+	return fromDocument(mine.doc, val)
+}
+
+func (mine *memoryResult) Err() error {
+	return mine.err
+}
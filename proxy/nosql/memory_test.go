@@ -0,0 +1,362 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"omo.msa.vocabulary/proxy"
+	"testing"
+	"time"
+)
+
+const testEntityTable = "entities"
+
+func initTestStore(t *testing.T) {
+	t.Helper()
+	if err := InitDB("", "", "test", StoreMemory, []string{testEntityTable}); err != nil {
+		t.Fatalf("init the memory store failed: %v", err)
+	}
+}
+
+func newTestEntity(name string, props []*proxy.PropertyInfo) *Entity {
+	db := new(Entity)
+	db.UID = primitive.NewObjectID()
+	db.ID = GetEntityNextID(testEntityTable)
+	db.Created = time.Now().Unix()
+	db.Name = name
+	db.Scene = "scene"
+	db.Tags = []string{"a", "b"}
+	db.Properties = props
+	return db
+}
+
+func TestMemoryEntityCRUD(t *testing.T) {
+	initTestStore(t)
+	ref := newTestEntity("beijing", nil)
+	if err := CreateEntity(ref, testEntityTable); err != nil {
+		t.Fatalf("create entity: %v", err)
+	}
+	props := []*proxy.PropertyInfo{{Key: "birth", Words: []proxy.WordInfo{{UID: ref.UID.Hex(), Name: "北京"}}}}
+	db := newTestEntity("test", props)
+	if err := CreateEntity(db, testEntityTable); err != nil {
+		t.Fatalf("create entity: %v", err)
+	}
+	if db.ID != 2 {
+		t.Errorf("the entity id = %d, want 2", db.ID)
+	}
+	uid := db.UID.Hex()
+
+	got, err := GetEntity(testEntityTable, uid)
+	if err != nil || got.Name != "test" || len(got.Properties) != 1 {
+		t.Fatalf("get entity = %+v, %v", got, err)
+	}
+	if err = UpdateEntityName(testEntityTable, uid, "renamed", "add", "tester"); err != nil {
+		t.Fatalf("update entity: %v", err)
+	}
+	got, _ = GetEntity(testEntityTable, uid)
+	if got.Name != "renamed" || got.Operator != "tester" || got.Tags[1] != "b" {
+		t.Errorf("update entity = %+v", got)
+	}
+
+	cases := []struct {
+		name string
+		find func() ([]*Entity, error)
+		want int
+	}{
+		{"prop key and value", func() ([]*Entity, error) { return GetEntitiesByProp(testEntityTable, "birth", "北京") }, 1},
+		{"prop other key", func() ([]*Entity, error) { return GetEntitiesByProp(testEntityTable, "death", "北京") }, 0},
+		{"prop value", func() ([]*Entity, error) { return GetEntitiesByProp2(testEntityTable, "北京") }, 1},
+		{"prop entity", func() ([]*Entity, error) { return GetEntitiesByPropEntity(testEntityTable, ref.UID.Hex()) }, 1},
+		{"tag", func() ([]*Entity, error) { return GetEntitiesByTag(testEntityTable, "a") }, 2},
+		{"owner", func() ([]*Entity, error) { return GetEntitiesByOwner(testEntityTable, "scene") }, 2},
+	}
+	for _, c := range cases {
+		list, er := c.find()
+		if er != nil || len(list) != c.want {
+			t.Errorf("%s: got %d entities, %v; want %d", c.name, len(list), er, c.want)
+		}
+	}
+
+	if err = RemoveEntity(testEntityTable, uid, "tester"); err != nil {
+		t.Fatalf("remove entity: %v", err)
+	}
+	if _, err = GetEntity(testEntityTable, uid); err == nil {
+		t.Error("get a removed entity should fail")
+	}
+	if list, _ := GetEntitiesByTag(testEntityTable, "a"); len(list) != 1 {
+		t.Errorf("the removed entity is listed, count = %d", len(list))
+	}
+	if num := GetEntityCountByScene(testEntityTable, "scene"); num != 1 {
+		t.Errorf("entity count = %d, want 1", num)
+	}
+}
+
+func TestMemoryEventCRUD(t *testing.T) {
+	initTestStore(t)
+	entity := primitive.NewObjectID().Hex()
+	uids := make([]string, 0, 2)
+	for _, target := range []string{"t1", "t2"} {
+		db := new(Event)
+		db.UID = primitive.NewObjectID()
+		db.ID = GetEventNextID()
+		db.Entity = entity
+		db.Name = "event-" + target
+		db.Targets = []string{target, "common"}
+		if err := CreateEvent(db); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		uids = append(uids, db.UID.Hex())
+	}
+	if list, err := GetEventsByEntity(entity); err != nil || len(list) != 2 {
+		t.Fatalf("get events = %d, %v", len(list), err)
+	}
+	if num := GetEventCountByTarget("common"); num != 2 {
+		t.Errorf("count by array element = %d, want 2", num)
+	}
+	if num := GetEventCountByEntityTarget(entity, "t1"); num != 1 {
+		t.Errorf("count by entity and target = %d, want 1", num)
+	}
+	if err := UpdateEventTargets(uids[0], "tester", []string{"t3"}); err != nil {
+		t.Fatalf("update event: %v", err)
+	}
+	if num := GetEventCountByTarget("common"); num != 1 {
+		t.Errorf("count after update = %d, want 1", num)
+	}
+	if err := RemoveEvent(uids[1], "tester"); err != nil {
+		t.Fatalf("remove event: %v", err)
+	}
+	if num := GetEventCountByEntity(entity); num != 1 {
+		t.Errorf("count after remove = %d, want 1", num)
+	}
+}
+
+func TestMemorySequence(t *testing.T) {
+	initTestStore(t)
+	for i := uint64(1); i <= 3; i++ {
+		num, err := getSequenceNext("items")
+		if err != nil || num != i {
+			t.Fatalf("sequence next = %d, %v; want %d", num, err, i)
+		}
+	}
+	// $max不能让序列变小
+	for _, id := range []uint64{10, 2} {
+		_, _ = insertOne("items", bson.M{"_id": primitive.NewObjectID(), "id": id})
+	}
+	if err := RepairSequences([]string{"items"}); err != nil {
+		t.Fatalf("repair sequences: %v", err)
+	}
+	if num, _ := getSequenceCount("items"); num != 10 {
+		t.Errorf("sequence after repair = %d, want 10", num)
+	}
+	_, _ = upsertOneBy(TableSequence, bson.M{"name": "items"}, bson.M{"$max": bson.M{"count": 5}})
+	if num, _ := getSequenceCount("items"); num != 10 {
+		t.Errorf("sequence after smaller max = %d, want 10", num)
+	}
+}
+
+func TestMemoryFilter(t *testing.T) {
+	doc, _ := toDocument(bson.M{
+		"name":   "test",
+		"status": 2,
+		"tags":   bson.A{"a", "b"},
+		"props": bson.A{
+			bson.M{"key": "k1", "values": bson.A{bson.M{"uid": "u1", "name": "n1"}}},
+			bson.M{"key": "k2", "values": bson.A{bson.M{"uid": "", "name": "n2"}}},
+		},
+	})
+	cases := []struct {
+		name   string
+		filter bson.M
+		want   bool
+	}{
+		{"equal", bson.M{"name": "test"}, true},
+		{"array element", bson.M{"tags": "b"}, true},
+		{"in", bson.M{"status": bson.M{"$in": bson.A{1, 2}}}, true},
+		{"in miss", bson.M{"status": bson.M{"$in": bson.A{3}}}, false},
+		{"in array", bson.M{"tags": bson.M{"$in": bson.A{"x", "a"}}}, true},
+		{"nin", bson.M{"tags": bson.M{"$nin": bson.A{"a"}}}, false},
+		{"ne", bson.M{"status": bson.M{"$ne": 2}}, false},
+		{"lt", bson.M{"status": bson.M{"$lt": 3}}, true},
+		{"elemMatch", bson.M{"props": bson.M{"$elemMatch": bson.M{"key": "k1", "values": bson.M{"$elemMatch": bson.M{"name": "n1"}}}}}, true},
+		{"elemMatch same element", bson.M{"props": bson.M{"$elemMatch": bson.M{"key": "k1", "values": bson.M{"$elemMatch": bson.M{"name": "n2"}}}}}, false},
+		{"dot path", bson.M{"props.values.uid": "u1"}, true},
+		{"missing field", bson.M{"deleted": 0}, false},
+		{"or", bson.M{"$or": bson.A{bson.M{"name": "x"}, bson.M{"status": 2}}}, true},
+	}
+	for _, c := range cases {
+		cond, _ := toDocument(c.filter)
+		if got := matchDocument(doc, cond); got != c.want {
+			t.Errorf("%s: match = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	doc, _ := toDocument(bson.M{"count": int64(3), "tags": bson.A{"a"}})
+	cases := []struct {
+		name   string
+		update bson.M
+		field  string
+		want   interface{}
+	}{
+		{"inc", bson.M{"$inc": bson.M{"count": 2}}, "count", int64(5)},
+		{"max smaller", bson.M{"$max": bson.M{"count": 1}}, "count", int64(5)},
+		{"max bigger", bson.M{"$max": bson.M{"count": int64(9)}}, "count", int64(9)},
+		{"min", bson.M{"$min": bson.M{"count": int64(4)}}, "count", int64(4)},
+		{"set", bson.M{"$set": bson.M{"name": "x"}}, "name", "x"},
+	}
+	for _, c := range cases {
+		change, _ := toDocument(c.update)
+		next, err := applyUpdate(doc, change, false)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got, _ := toFloat(next[c.field]); c.field == "count" {
+			want, _ := toFloat(c.want)
+			if got != want {
+				t.Errorf("%s: count = %v, want %v", c.name, next[c.field], c.want)
+			}
+		} else if next[c.field] != c.want {
+			t.Errorf("%s: %s = %v, want %v", c.name, c.field, next[c.field], c.want)
+		}
+		doc = next
+	}
+	change, _ := toDocument(bson.M{"$addToSet": bson.M{"tags": "a"}})
+	next, err := applyUpdate(doc, change, false)
+	if err != nil || len(next["tags"].([]interface{})) != 1 {
+		t.Errorf("addToSet duplicated = %v, %v", next["tags"], err)
+	}
+}
+
+func TestMemoryProjection(t *testing.T) {
+	initTestStore(t)
+	db := newTestEntity("test", nil)
+	db.Description = "desc"
+	if err := CreateEntity(db, testEntityTable); err != nil {
+		t.Fatalf("create entity: %v", err)
+	}
+	result, err := findOneByOpt(testEntityTable, bson.M{"_id": db.UID}, bson.M{"name": 1, "tags": 1})
+	if err != nil {
+		t.Fatalf("find one with projection: %v", err)
+	}
+	doc := bson.M{}
+	if err = result.Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(doc) != 3 || doc["name"] != "test" || doc["_id"] != db.UID || doc["tags"] == nil {
+		t.Errorf("the included document = %v, want _id, name and tags", doc)
+	}
+	cursor, err := noSql.Collection(testEntityTable).Find(context.Background(), bson.M{}, options.Find().SetProjection(bson.M{"desc": 0, "_id": 0}))
+	if err != nil || !cursor.Next(context.Background()) {
+		t.Fatalf("find with projection: %v", err)
+	}
+	doc = bson.M{}
+	_ = cursor.Decode(&doc)
+	if _, ok := doc["desc"]; ok || doc["_id"] != nil || doc["name"] != "test" {
+		t.Errorf("the excluded document = %v, want no desc and _id", doc)
+	}
+	if _, err = findOneByOpt(testEntityTable, bson.M{}, bson.M{"tags": bson.M{"$slice": 1}}); err == nil {
+		t.Error("the projection operator should not be supported")
+	}
+}
+
+func TestMemoryRevisionVersionUnique(t *testing.T) {
+	initTestStore(t)
+	entity := primitive.NewObjectID().Hex()
+	for i, version := range []uint32{1, 2, 2} {
+		db := new(Revision)
+		db.UID = primitive.NewObjectID()
+		db.ID = GetRevisionNextID()
+		db.Entity = entity
+		db.Version = version
+		err := CreateRevision(db)
+		if i < 2 && err != nil {
+			t.Fatalf("create revision %d: %v", version, err)
+		}
+		if i == 2 && !IsDuplicateKey(err) {
+			t.Errorf("create a repeated version = %v, want a duplicate key error", err)
+		}
+	}
+	if version := GetRevisionLastVersion(entity); version != 2 {
+		t.Errorf("the last version = %d, want 2", version)
+	}
+}
+
+func TestMemoryRecoverTrashItemsBy(t *testing.T) {
+	initTestStore(t)
+	entity := primitive.NewObjectID().Hex()
+	for i, deleted := range []int64{100, 200, 300} {
+		db := new(Event)
+		db.UID = primitive.NewObjectID()
+		db.ID = GetEventNextID()
+		db.Entity = entity
+		db.Name = "event"
+		db.Deleted = deleted
+		if i == 2 {
+			db.Entity = "other"
+		}
+		if err := CreateEvent(db); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+	// 实体删除前单独删除的事件不恢复
+	list, err := RecoverTrashItemsBy(TableEvent, bson.M{"entity": entity}, 200, "tester")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("recover events = %d, %v; want 1", len(list), err)
+	}
+	if num := GetEventCountByEntity(entity); num != 1 {
+		t.Errorf("the recovered count = %d, want 1", num)
+	}
+	if num := GetEventCountByEntity("other"); num != 0 {
+		t.Errorf("the event of other entity is recovered, count = %d", num)
+	}
+}
+
+func TestMemoryMigrationLock(t *testing.T) {
+	initTestStore(t)
+	if locked, err := LockMigration("a", 60); err != nil || !locked {
+		t.Fatalf("lock migration = %v, %v", locked, err)
+	}
+	if locked, _ := LockMigration("b", 60); locked {
+		t.Error("the lock held by other owner should not be locked again")
+	}
+	if held, err := RefreshMigration("a"); err != nil || !held {
+		t.Errorf("refresh the own lock = %v, %v", held, err)
+	}
+	if held, _ := RefreshMigration("b"); held {
+		t.Error("refresh the lock of other owner should fail")
+	}
+	if err := UnlockMigration("a"); err != nil {
+		t.Fatalf("unlock migration: %v", err)
+	}
+	if held, _ := RefreshMigration("a"); held {
+		t.Error("refresh an unlocked lock should fail")
+	}
+}
+
+func TestMemoryReviewAssign(t *testing.T) {
+	initTestStore(t)
+	db := &Review{UID: primitive.NewObjectID(), Created: time.Now().Unix(), Target: "t", Reviewer: "a", Status: 0}
+	if err := CreateReview(db); err != nil {
+		t.Fatalf("create review: %v", err)
+	}
+	uid := db.UID.Hex()
+	if ok, err := UpdateReviewClaim(uid, "a", 0, 1); err != nil || !ok {
+		t.Fatalf("claim review = %v, %v", ok, err)
+	}
+	// 认领后按照等待状态重新分配不能覆盖认领
+	if ok, _ := UpdateReviewAssign(uid, "a", "b", 0, 0); ok {
+		t.Error("assign a claimed review as waiting should fail")
+	}
+	if ok, _ := UpdateReviewAssign(uid, "c", "b", 1, 0); ok {
+		t.Error("assign a review of other reviewer should fail")
+	}
+	if ok, err := UpdateReviewAssign(uid, "a", "b", 1, 0); err != nil || !ok {
+		t.Fatalf("reassign the claimed review = %v, %v", ok, err)
+	}
+	got, _ := GetReview(uid)
+	if got.Reviewer != "b" || got.Status != 0 || got.Claimed != 0 {
+		t.Errorf("the reassigned review = %+v", got)
+	}
+}
@@ -0,0 +1,112 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+/**
+数据迁移的执行记录，版本为0的记录用作多个实例之间的锁
+*/
+type Migration struct {
+	UID      primitive.ObjectID `bson:"_id"`
+	Version  uint32             `json:"version" bson:"version"`
+	Name     string             `json:"name" bson:"name"`
+	Status   uint8              `json:"status" bson:"status"`
+	Started  int64              `json:"started" bson:"started"`
+	Finished int64              `json:"finished" bson:"finished"`
+	Error    string             `json:"error" bson:"error"`
+	Owner    string             `json:"owner" bson:"owner"`
+	Locked   int64              `json:"locked" bson:"locked"` //加锁的时间，0表示没有加锁
+}
+
+func GetMigrations() ([]*Migration, error) {
+	var items = make([]*Migration, 0, 20)
+	filter := bson.M{"version": bson.M{"$gt": 0}}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+	cursor, err1 := findManyByOpts(TableMigration, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Migration)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+/**
+保存迁移的执行结果，同一个版本只保留一条记录
+*/
+func UpdateMigration(version uint32, name, owner string, st uint8, started int64, msg string) error {
+	filter := bson.M{"version": version}
+	update := bson.M{"$set": bson.M{"name": name, "owner": owner, "status": st, "started": started,
+		"finished": time.Now().Unix(), "error": msg}}
+	_, err := upsertOneBy(TableMigration, filter, update)
+	return err
+}
+
+/**
+获取迁移锁，锁超过expire秒没有释放时认为持有者已经退出
+*/
+func LockMigration(owner string, expire int64) (bool, error) {
+	err := createIndex(TableMigration, bson.D{{Key: "version", Value: 1}}, true)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"version": 0}
+	_, err = upsertOneBy(TableMigration, filter, bson.M{"$setOnInsert": bson.M{"name": "lock", "locked": int64(0)}})
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	filter["locked"] = bson.M{"$lt": now - expire}
+	num, err := updateOneBy(TableMigration, filter, bson.M{"$set": bson.M{"locked": now, "owner": owner}})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
+
+/**
+刷新迁移锁的时间，锁已经被其他实例获取时返回false
+*/
+func RefreshMigration(owner string) (bool, error) {
+	filter := bson.M{"version": 0, "owner": owner, "locked": bson.M{"$gt": 0}}
+	_, err := updateOneBy(TableMigration, filter, bson.M{"$set": bson.M{"locked": time.Now().Unix()}})
+	if err != nil {
+		return false, err
+	}
+	// 同一秒内刷新时没有修改的数据，按照持有者判断
+	return hadOne(TableMigration, filter)
+}
+
+/**
+迁移锁的记录，没有执行过迁移时返回错误
+*/
+func GetMigrationLock() (*Migration, error) {
+	result, err := findOneBy(TableMigration, bson.M{"version": 0})
+	if err != nil {
+		return nil, err
+	}
+	model := new(Migration)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+func UnlockMigration(owner string) error {
+	filter := bson.M{"version": 0, "owner": owner}
+	_, err := updateOneBy(TableMigration, filter, bson.M{"$set": bson.M{"locked": int64(0)}})
+	return err
+}
@@ -0,0 +1,182 @@
+package nosql
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type mongoStore struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+type mongoCollection struct {
+	c *mongo.Collection
+}
+
+type mongoBucket struct {
+	db   *mongo.Database
+	name string
+}
+
+func (mine *mongoStore) Collection(name string) Collection {
+	return &mongoCollection{c: mine.db.Collection(name)}
+}
+
+func (mine *mongoStore) CollectionNames(ctx context.Context) ([]string, error) {
+	return mine.db.ListCollectionNames(ctx, bson.M{})
+}
+
+func (mine *mongoStore) Bucket(name string) Bucket {
+	return &mongoBucket{db: mine.db, name: name}
+}
+
+func (mine *mongoStore) Ping(ctx context.Context) error {
+	return mine.client.Ping(ctx, nil)
+}
+
+func (mine *mongoCollection) InsertOne(ctx context.Context, doc interface{}) (interface{}, error) {
+	result, err := mine.c.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedID, nil
+}
+
+func (mine *mongoCollection) FindOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) SingleResult {
+	return mine.c.FindOne(ctx, filter, opts...)
+}
+
+func (mine *mongoCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (Cursor, error) {
+	cursor, err := mine.c.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (mine *mongoCollection) CountDocuments(ctx context.Context, filter bson.M, opts ...*options.CountOptions) (int64, error) {
+	return mine.c.CountDocuments(ctx, filter, opts...)
+}
+
+func (mine *mongoCollection) EstimatedDocumentCount(ctx context.Context) (int64, error) {
+	return mine.c.EstimatedDocumentCount(ctx)
+}
+
+func (mine *mongoCollection) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	result, err := mine.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (mine *mongoCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, opts ...*options.FindOneAndUpdateOptions) SingleResult {
+	return mine.c.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+func (mine *mongoCollection) DeleteOne(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := mine.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (mine *mongoCollection) Drop(ctx context.Context) error {
+	return mine.c.Drop(ctx)
+}
+
+func (mine *mongoCollection) CreateIndex(ctx context.Context, index *Index) error {
+	opts := options.Index().SetUnique(index.Unique)
+	if len(index.Name) > 0 {
+		opts.SetName(index.Name)
+	}
+	if len(index.Partial) > 0 {
+		opts.SetPartialFilterExpression(index.Partial)
+	}
+	model := mongo.IndexModel{Keys: index.Keys, Options: opts}
+	_, err := mine.c.Indexes().CreateOne(ctx, model)
+	return err
+}
+
+func (mine *mongoCollection) IndexNames(ctx context.Context) ([]string, error) {
+	cursor, err := mine.c.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	list := make([]string, 0, 10)
+	for cursor.Next(ctx) {
+		var item = new(struct {
+			Name string `bson:"name"`
+		})
+		if er := cursor.Decode(item); er != nil {
+			return nil, er
+		}
+		list = append(list, item.Name)
+	}
+	return list, nil
+}
+
+func (mine *mongoBucket) open(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(mine.db, options.GridFSBucket().SetName(mine.name))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = bucket.SetReadDeadline(deadline)
+		_ = bucket.SetWriteDeadline(deadline)
+	} else {
+		_ = bucket.SetReadDeadline(time.Now().Add(timeOut))
+		_ = bucket.SetWriteDeadline(time.Now().Add(timeOut))
+	}
+	return bucket, nil
+}
+
+func (mine *mongoBucket) Upload(ctx context.Context, name string, data []byte) (string, error) {
+	bucket, err := mine.open(ctx)
+	if err != nil {
+		return "", err
+	}
+	id, err := bucket.UploadFromStream(name, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return id.Hex(), nil
+}
+
+func (mine *mongoBucket) Download(ctx context.Context, id string) ([]byte, error) {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := mine.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	_, err = bucket.DownloadToStream(uid, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (mine *mongoBucket) Delete(ctx context.Context, id string) error {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	bucket, err := mine.open(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(uid)
+}
@@ -0,0 +1,180 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+/**
+审核任务，待审核的实体或者修改建议分配给收藏夹的审核人
+*/
+type Review struct {
+	UID     primitive.ObjectID `bson:"_id"`
+	Created int64              `json:"created" bson:"created"`
+	Updated int64              `json:"updated" bson:"updated"`
+	Deleted int64              `json:"deleted" bson:"deleted"`
+
+	Kind     uint8  `json:"kind" bson:"kind"`     //任务类型，实体或者修改建议
+	Target   string `json:"target" bson:"target"` //实体或者修改建议的UID
+	Entity   string `json:"entity" bson:"entity"`
+	Box      string `json:"box" bson:"box"`
+	Reviewer string `json:"reviewer" bson:"reviewer"`
+	Status   uint8  `json:"status" bson:"status"`
+	Assigned int64  `json:"assigned" bson:"assigned"` //分配的时间
+	Claimed  int64  `json:"claimed" bson:"claimed"`   //认领的时间
+	Finished int64  `json:"finished" bson:"finished"`
+	Overdue  bool   `json:"overdue" bson:"overdue"` //超过SLA没有完成
+}
+
+func CreateReview(info *Review) error {
+	_, err := insertOne(TableReview, info)
+	return err
+}
+
+func GetReview(uid string) (*Review, error) {
+	result, err := findOne(TableReview, uid)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Review)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+func getReviewsBy(filter bson.M) ([]*Review, error) {
+	var items = make([]*Review, 0, 20)
+	filter[TimeDeleted] = 0
+	opts := options.Find().SetSort(bson.D{{Key: TimeCreated, Value: 1}})
+	cursor, err1 := findManyByOpts(TableReview, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Review)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+/**
+没有完成的审核任务，按照创建时间排序
+*/
+func GetOpenReviews(done uint8) ([]*Review, error) {
+	return getReviewsBy(bson.M{"status": bson.M{"$ne": done}})
+}
+
+func GetOpenReviewsByReviewer(reviewer string, done uint8) ([]*Review, error) {
+	return getReviewsBy(bson.M{"reviewer": reviewer, "status": bson.M{"$ne": done}})
+}
+
+func GetOpenReviewsByBox(box string, done uint8) ([]*Review, error) {
+	return getReviewsBy(bson.M{"box": box, "status": bson.M{"$ne": done}})
+}
+
+func GetOpenReviewBy(kind uint8, target string, done uint8) (*Review, error) {
+	filter := bson.M{"kind": kind, "target": target, "status": bson.M{"$ne": done}, TimeDeleted: 0}
+	result, err := findOneBy(TableReview, filter)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Review)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+/**
+统计任务数量，box或者reviewer为空时不作为条件
+*/
+func GetReviewCount(box, reviewer string, st uint8) uint32 {
+	filter := bson.M{"status": st, TimeDeleted: 0}
+	if box != "" {
+		filter["box"] = box
+	}
+	if reviewer != "" {
+		filter["reviewer"] = reviewer
+	}
+	num, err := getCountBy(TableReview, filter)
+	if err != nil {
+		return 0
+	}
+	return uint32(num)
+}
+
+/**
+只有状态为from并且审核人仍然是previous的任务才可以重新分配，避免覆盖同时发生的认领，返回是否分配成功
+*/
+func UpdateReviewAssign(uid, previous, reviewer string, from, st uint8) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	filter := bson.M{"_id": objID, "status": from, "reviewer": previous}
+	num, err := updateOneBy(TableReview, filter, bson.M{"$set": bson.M{"reviewer": reviewer, "status": st,
+		"assigned": now, "claimed": int64(0), TimeUpdated: now}})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
+
+/**
+只有状态为from并且没有分配给其他人的任务才可以认领，返回是否认领成功
+*/
+func UpdateReviewClaim(uid, reviewer string, from, st uint8) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	filter := bson.M{"_id": objID, "status": from, "reviewer": bson.M{"$in": bson.A{"", reviewer}}}
+	num, err := updateOneBy(TableReview, filter, bson.M{"$set": bson.M{"reviewer": reviewer, "status": st,
+		"claimed": now, TimeUpdated: now}})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
+
+/**
+释放认领的任务，只有认领人可以释放，返回是否释放成功
+*/
+func UpdateReviewRelease(uid, reviewer string, from, st uint8) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": objID, "status": from, "reviewer": reviewer}
+	num, err := updateOneBy(TableReview, filter, bson.M{"$set": bson.M{"reviewer": "", "status": st,
+		"claimed": int64(0), TimeUpdated: time.Now().Unix()}})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
+
+func UpdateReviewFinish(uid string, st uint8) error {
+	msg := bson.M{"status": st, "finished": time.Now().Unix(), TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableReview, uid, msg)
+	return err
+}
+
+func UpdateReviewOverdue(uid string) error {
+	msg := bson.M{"overdue": true, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableReview, uid, msg)
+	return err
+}
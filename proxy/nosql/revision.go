@@ -0,0 +1,103 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+/**
+实体的修订记录，创建后不再修改，Snapshot和Relations为修改后的完整数据
+*/
+type Revision struct {
+	UID         primitive.ObjectID `bson:"_id"`
+	ID          uint64             `json:"id" bson:"id"`
+	CreatedTime time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedTime time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeleteTime  time.Time          `json:"deleteAt" bson:"deleteAt"`
+	Created     int64              `json:"created" bson:"created"`
+	Updated     int64              `json:"updated" bson:"updated"`
+	Deleted     int64              `json:"deleted" bson:"deleted"`
+	Creator     string             `json:"creator" bson:"creator"`
+	Operator    string             `json:"operator" bson:"operator"`
+
+	Entity    string           `json:"entity" bson:"entity"`
+	Version   uint32           `json:"version" bson:"version"`
+	Action    string           `json:"action" bson:"action"`
+	Remark    string           `json:"remark" bson:"remark"`
+	Fields    []*RevisionField `json:"fields" bson:"fields"`
+	Snapshot  *Entity          `json:"snapshot" bson:"snapshot"`
+	Relations []*VEdge         `json:"relations" bson:"relations"`
+}
+
+/**
+修改的字段，Before和After为JSON格式的值
+*/
+type RevisionField struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before" bson:"before"`
+	After  string `json:"after" bson:"after"`
+}
+
+func CreateRevision(info *Revision) error {
+	_, err := insertOne(TableRevision, info)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetRevisionNextID() uint64 {
+	num, _ := getSequenceNext(TableRevision)
+	return num
+}
+
+func GetRevisionByVersion(entity string, version uint32) (*Revision, error) {
+	msg := bson.M{"entity": entity, "version": version}
+	result, err := findOneBy(TableRevision, msg)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Revision)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	return model, nil
+}
+
+/**
+按照版本从新到旧排序，num为0时返回全部
+*/
+func GetRevisionsByEntity(entity string, num int64) ([]*Revision, error) {
+	var items = make([]*Revision, 0, 20)
+	filter := bson.M{"entity": entity}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	if num > 0 {
+		opts.SetLimit(num)
+	}
+	cursor, err1 := findManyByOpts(TableRevision, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(Revision)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func GetRevisionLastVersion(entity string) uint32 {
+	list, err := GetRevisionsByEntity(entity, 1)
+	if err != nil || len(list) < 1 {
+		return 0
+	}
+	return list[0].Version
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
@@ -16,31 +17,27 @@ type Sequence struct {
 	Count       uint64             `json:"count" bson:"count"`
 }
 
-func createSequence(name string) error {
-	var db = new(Sequence)
-	db.Count = 0
-	db.UID = primitive.NewObjectID()
-	db.Name = name
-	db.CreatedTime = time.Now()
-	_, err := insertOne(TableSequence, db)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
+/**
+原子递增序列并返回递增后的值，序列不存在时自动创建
+*/
 func getSequenceNext(name string) (uint64, error) {
-	num, _ := getSequenceCount(name)
-	if num < 1 {
-		_ = createSequence(name)
-	}
 	filter := bson.M{"name": name}
-	update := bson.M{"$inc": bson.M{"count": 1}, "$set": bson.M{"updatedAt": time.Now()}}
-	_, err := updateOneBy(TableSequence, filter, update)
+	update := bson.M{"$inc": bson.M{"count": 1}, "$set": bson.M{"updatedAt": time.Now()},
+		"$setOnInsert": bson.M{"createdAt": time.Now()}}
+	result, err := upsertOneBy(TableSequence, filter, update)
+	if err != nil {
+		// 并发创建同一个序列时，唯一索引会让其中一个失败，此时序列已经存在，重试一次即可
+		result, err = upsertOneBy(TableSequence, filter, update)
+		if err != nil {
+			return 0, err
+		}
+	}
+	model := new(Sequence)
+	err = result.Decode(model)
 	if err != nil {
 		return 0, err
 	}
-	return num + 1, nil
+	return model.Count, nil
 }
 
 func getSequenceCount(name string) (uint64, error) {
@@ -57,9 +54,31 @@ func getSequenceCount(name string) (uint64, error) {
 	return model.Count, nil
 }
 
+/**
+获取表中最大的ID，包括已经删除的数据
+*/
+func getMaxID(table string) (uint64, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}}).SetLimit(1)
+	cursor, err := findManyByOpts(table, bson.M{}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+	var max uint64
+	for cursor.Next(context.Background()) {
+		var item = new(struct {
+			ID uint64 `bson:"id"`
+		})
+		if er := cursor.Decode(item); er == nil {
+			max = item.ID
+		}
+	}
+	return max, nil
+}
+
 func GetAllSequences() ([]*Sequence, error) {
 	var items = make([]*Sequence, 0, 100)
-	cursor, err1 := findAllEnable(TableSequence, 0)
+	cursor, err1 := findMany(TableSequence, bson.M{}, 0)
 	if err1 != nil {
 		return nil, err1
 	}
@@ -75,6 +94,50 @@ func GetAllSequences() ([]*Sequence, error) {
 	return items, nil
 }
 
+/**
+修复序列：合并同名的序列，建立序列名称的唯一索引，并保证每个序列不小于对应表中最大的ID
+*/
+func RepairSequences(tables []string) error {
+	all, err := GetAllSequences()
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]*Sequence, len(all))
+	for _, item := range all {
+		old, ok := kept[item.Name]
+		if !ok {
+			kept[item.Name] = item
+			continue
+		}
+		if item.Count > old.Count {
+			kept[item.Name] = item
+			item = old
+		}
+		err = DeleteSequence(item.UID.Hex())
+		if err != nil {
+			return err
+		}
+	}
+	err = createIndex(TableSequence, bson.D{{Key: "name", Value: 1}}, true)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		max, er := getMaxID(table)
+		if er != nil {
+			return er
+		}
+		filter := bson.M{"name": table}
+		update := bson.M{"$max": bson.M{"count": max}, "$set": bson.M{"updatedAt": time.Now()},
+			"$setOnInsert": bson.M{"createdAt": time.Now()}}
+		_, er = upsertOneBy(TableSequence, filter, update)
+		if er != nil {
+			return er
+		}
+	}
+	return nil
+}
+
 func UpdateSequenceName(uid, name string) error {
 	msg := bson.M{"name": name, "updatedAt": time.Now()}
 	_, err := updateOne(TableSequence, uid, msg)
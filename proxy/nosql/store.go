@@ -0,0 +1,72 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StoreMongo  = "mongodb"
+	StoreMemory = "memory"
+)
+
+/**
+存储后端，每个表(实体，事件，概念，属性，关系，收藏夹，归档，记录，关系边，审核，序列)对应一个集合
+*/
+type Store interface {
+	Collection(name string) Collection
+	CollectionNames(ctx context.Context) ([]string, error)
+	Bucket(name string) Bucket
+	Ping(ctx context.Context) error
+}
+
+/**
+大文件存储，mongodb使用GridFS
+*/
+type Bucket interface {
+	Upload(ctx context.Context, name string, data []byte) (string, error)
+	Download(ctx context.Context, id string) ([]byte, error)
+	Delete(ctx context.Context, id string) error
+}
+
+/**
+集合的基本操作，过滤条件和更新语句统一使用bson格式
+*/
+type Collection interface {
+	InsertOne(ctx context.Context, doc interface{}) (interface{}, error)
+	FindOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) SingleResult
+	Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (Cursor, error)
+	CountDocuments(ctx context.Context, filter bson.M, opts ...*options.CountOptions) (int64, error)
+	EstimatedDocumentCount(ctx context.Context) (int64, error)
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error)
+	FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, opts ...*options.FindOneAndUpdateOptions) SingleResult
+	DeleteOne(ctx context.Context, filter bson.M) (int64, error)
+	Drop(ctx context.Context) error
+	CreateIndex(ctx context.Context, index *Index) error
+	IndexNames(ctx context.Context) ([]string, error)
+}
+
+/**
+索引定义，Partial不为空时只有满足条件的文档参与索引
+*/
+type Index struct {
+	Name    string
+	Keys    bson.D
+	Unique  bool
+	Partial bson.M
+}
+
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+type SingleResult interface {
+	Decode(val interface{}) error
+	Err() error
+}
+
+var noSql Store
@@ -9,15 +9,21 @@ const (
 	/**
 	用户地址表
 	*/
-	TableAddress      = "address"
-	TableConcept      = "concepts"
-	TableRelation     = "relations"
-	TableAttribute    = "attributes"
-	TableEvent        = "events"
-	TableRelationCase = "cases"
-	TableBox          = "boxes"
-	TableArchived     = "archived"
-	TableRecord       = "records"
-	TableEdge         = "edges"
-	TableExamine      = "examines"
+	TableAddress         = "address"
+	TableConcept         = "concepts"
+	TableRelation        = "relations"
+	TableAttribute       = "attributes"
+	TableEvent           = "events"
+	TableRelationCase    = "cases"
+	TableBox             = "boxes"
+	TableArchived        = "archived"
+	TableArchivedVersion = "archived_versions"
+	TableRecord          = "records"
+	TableEdge            = "edges"
+	TableExamine         = "examines"
+	TableGraphTask       = "graph_tasks"
+	TableImportJob       = "import_jobs"
+	TableRevision        = "revisions"
+	TableMigration       = "migrations"
+	TableReview          = "reviews"
 )
@@ -0,0 +1,140 @@
+package nosql
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+/**
+待同步到图数据库的操作
+*/
+type GraphTask struct {
+	UID         primitive.ObjectID `bson:"_id"`
+	CreatedTime time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedTime time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeleteTime  time.Time          `json:"deleteAt" bson:"deleteAt"`
+	Created     int64              `json:"created" bson:"created"`
+	Updated     int64              `json:"updated" bson:"updated"`
+	Deleted     int64              `json:"deleted" bson:"deleted"`
+	Creator     string             `json:"creator" bson:"creator"`
+	Operator    string             `json:"operator" bson:"operator"`
+
+	Type      uint8  `json:"type" bson:"type"`
+	Status    uint8  `json:"status" bson:"status"`
+	Entity    string `json:"entity" bson:"entity"`
+	Name      string `json:"name" bson:"name"`
+	Concept   string `json:"concept" bson:"concept"`
+	Cover     string `json:"cover" bson:"cover"`
+	From      string `json:"from" bson:"from"`
+	To        string `json:"to" bson:"to"`
+	Kind      string `json:"kind" bson:"kind"`
+	Relation  string `json:"relation" bson:"relation"`
+	Direction uint8  `json:"direction" bson:"direction"`
+	Weight    uint32 `json:"weight" bson:"weight"`
+	Retries   uint32 `json:"retries" bson:"retries"`
+	Next      int64  `json:"next" bson:"next"` //下次执行的时间
+	Error     string `json:"error" bson:"error"`
+}
+
+func CreateGraphTask(info *GraphTask) error {
+	_, err := insertOne(TableGraphTask, info)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetGraphTasksByNext(st uint8, next int64, num int64) ([]*GraphTask, error) {
+	var items = make([]*GraphTask, 0, num)
+	filter := bson.M{"status": st, "next": bson.M{"$lte": next}}
+	opts := options.Find().SetSort(bson.D{{Key: TimeCreated, Value: 1}}).SetLimit(num)
+	cursor, err1 := findManyByOpts(TableGraphTask, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(GraphTask)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func GetGraphTasksByStatus(st uint8) ([]*GraphTask, error) {
+	var items = make([]*GraphTask, 0, 20)
+	filter := bson.M{"status": st}
+	cursor, err1 := findMany(TableGraphTask, filter, 0)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(GraphTask)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+/**
+最近失败过的任务，包括等待重试和死信状态的任务，按照更新时间从新到旧
+*/
+func GetGraphTasksByFailed(num int64) ([]*GraphTask, error) {
+	var items = make([]*GraphTask, 0, num)
+	filter := bson.M{"error": bson.M{"$ne": ""}}
+	opts := options.Find().SetSort(bson.D{{Key: TimeUpdated, Value: -1}}).SetLimit(num)
+	cursor, err1 := findManyByOpts(TableGraphTask, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(GraphTask)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func GetGraphTaskCount(st uint8) uint32 {
+	num, _ := getCountBy(TableGraphTask, bson.M{"status": st})
+	return uint32(num)
+}
+
+/**
+重试过至少一次的任务数量
+*/
+func GetGraphTaskRetryCount(st uint8) uint32 {
+	num, _ := getCountBy(TableGraphTask, bson.M{"status": st, "retries": bson.M{"$gt": 0}})
+	return uint32(num)
+}
+
+func UpdateGraphTaskRetry(uid string, retries uint32, next int64, msg string) error {
+	data := bson.M{"retries": retries, "next": next, "error": msg, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableGraphTask, uid, data)
+	return err
+}
+
+func UpdateGraphTaskStatus(uid string, st uint8, msg string) error {
+	data := bson.M{"status": st, "error": msg, TimeUpdated: time.Now().Unix()}
+	_, err := updateOne(TableGraphTask, uid, data)
+	return err
+}
+
+func DeleteGraphTask(uid string) error {
+	_, err := deleteOne(TableGraphTask, uid)
+	return err
+}
@@ -0,0 +1,152 @@
+package nosql
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/**
+回收站中的数据，只读取各个表共有的字段
+*/
+type TrashItem struct {
+	UID      primitive.ObjectID `bson:"_id"`
+	Name     string             `json:"name" bson:"name"`
+	Operator string             `json:"operator" bson:"operator"`
+	Deleted  int64              `json:"deleted" bson:"deleted"`
+	Entity   string             `json:"entity" bson:"entity"` //事件所属的实体
+	Parent   string             `json:"parent" bson:"parent"` //概念的上级
+	Blob     string             `json:"blob" bson:"blob"`     //存档保存在GridFS中的文件
+}
+
+/**
+按照删除时间从新到旧返回表中软删除的数据，key为场景对应的字段，为空时不过滤场景
+*/
+func GetTrashItems(table, key, scene string, num int64) ([]*TrashItem, error) {
+	var items = make([]*TrashItem, 0, 20)
+	filter := bson.M{TimeDeleted: bson.M{"$gt": 0}}
+	if len(key) > 0 && len(scene) > 0 {
+		filter[key] = scene
+	}
+	opts := options.Find().SetSort(bson.D{{Key: TimeDeleted, Value: -1}}).SetLimit(num)
+	cursor, err1 := findManyByOpts(table, filter, opts)
+	if err1 != nil {
+		return nil, err1
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var node = new(TrashItem)
+		if err := cursor.Decode(node); err != nil {
+			return nil, err
+		} else {
+			items = append(items, node)
+		}
+	}
+	return items, nil
+}
+
+func GetTrashItem(table, uid string) (*TrashItem, error) {
+	result, err := findOne(table, uid)
+	if err != nil {
+		return nil, err
+	}
+	model := new(TrashItem)
+	err1 := result.Decode(model)
+	if err1 != nil {
+		return nil, err1
+	}
+	if model.Deleted < 1 {
+		return nil, errors.New("the item is not in trash")
+	}
+	return model, nil
+}
+
+func RecoverTrashItem(table, uid, operator string) error {
+	num, err := recoverOne(table, uid, operator)
+	if err != nil {
+		return err
+	}
+	if num < 1 {
+		return errors.New("not found the item in trash")
+	}
+	return nil
+}
+
+/**
+恢复实体最近一次被删除的存档
+*/
+func RecoverArchivedByEntity(entity, operator string) error {
+	filter := bson.M{"entity": entity, TimeDeleted: bson.M{"$gt": 0}}
+	opts := options.Find().SetSort(bson.D{{Key: TimeDeleted, Value: -1}}).SetLimit(1)
+	cursor, err := findManyByOpts(TableArchived, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+	if !cursor.Next(context.Background()) {
+		return nil
+	}
+	var node = new(TrashItem)
+	if err = cursor.Decode(node); err != nil {
+		return err
+	}
+	_, err = recoverOne(TableArchived, node.UID.Hex(), operator)
+	return err
+}
+
+/**
+恢复表中满足条件并且删除时间不早于since的数据，用于恢复随上级数据一起删除的数据，返回恢复的数据
+*/
+func RecoverTrashItemsBy(table string, filter bson.M, since int64, operator string) ([]*TrashItem, error) {
+	cond := bson.M{TimeDeleted: bson.M{"$gte": since}}
+	for key, val := range filter {
+		cond[key] = val
+	}
+	cursor, err := findMany(table, cond, 0)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*TrashItem, 0, 10)
+	for cursor.Next(context.Background()) {
+		var node = new(TrashItem)
+		if er := cursor.Decode(node); er == nil {
+			list = append(list, node)
+		}
+	}
+	_ = cursor.Close(context.Background())
+	for i, item := range list {
+		_, err = recoverOne(table, item.UID.Hex(), operator)
+		if err != nil {
+			return list[:i], err
+		}
+	}
+	return list, nil
+}
+
+/**
+彻底删除表中删除时间早于before的数据，返回删除的数据
+*/
+func PurgeTrashItems(table string, before int64) ([]*TrashItem, error) {
+	filter := bson.M{TimeDeleted: bson.M{"$gt": 0, "$lt": before}}
+	cursor, err := findMany(table, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*TrashItem, 0, 20)
+	for cursor.Next(context.Background()) {
+		var node = new(TrashItem)
+		if er := cursor.Decode(node); er == nil {
+			list = append(list, node)
+		}
+	}
+	_ = cursor.Close(context.Background())
+	for i, item := range list {
+		_, err = deleteOne(table, item.UID.Hex())
+		if err != nil {
+			return list[:i], err
+		}
+	}
+	return list, nil
+}
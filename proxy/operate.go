@@ -1,136 +1,119 @@
 package proxy
 
-import (
-	"errors"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
-	"omo.msa.vocabulary/proxy/graph"
-)
-
-var isNeo4j = true
-
-func switchNode(info neo4j.Node) *Node {
-	if info == nil {
-		return nil
-	}
-	node := new(Node)
-	props := info.Props()
-	node.Name, _ = props["name"].(string)
-	node.UID, _ = props["uid"].(string)
-	node.Labels = info.Labels()
-	node.ID = info.Id()
-	return node
-}
-
-func switchLink(info neo4j.Relationship) *Link {
-	if info == nil {
-		return nil
-	}
-	link := new(Link)
-	link.ID = info.Id()
-	link.Label = info.Type()
-	props := info.Props()
-	link.Name = props["name"].(string)
-	link.Relation = props["relation"].(string)
-	link.Direction = uint8(props["direction"].(int64))
-	link.From = info.StartId()
-	link.To = info.EndId()
-	return link
-}
+import "errors"
 
 func CreateNode(name, label, uid string) (*Node, error) {
-	if isNeo4j {
-		node, err := graph.CreateNode(name, label, uid)
-		return switchNode(node), err
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.CreateNode(name, label, uid)
 }
 
 func CreateLink(from, to int64, kind, name, relation string, direction uint8, weight uint32) (*Link, error) {
-	if isNeo4j {
-		link, err := graph.CreateLink(from, to, kind, name, relation, direction, weight)
-		return switchLink(link), err
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.CreateLink(from, to, kind, name, relation, direction, weight)
 }
 
 func RemoveNode(id int64, label string) error {
-	if isNeo4j {
-		return graph.DeleteNode(id, label)
+	if err := checkGraph(); err != nil {
+		return err
 	}
-	return errors.New("not support db")
+	return graphStore.DeleteNode(id, label)
 }
 
 func RemoveLink(id int64) error {
-	if isNeo4j {
-		return graph.DeleteLink(id)
+	if err := checkGraph(); err != nil {
+		return err
 	}
-	return errors.New("not support db")
+	return graphStore.DeleteLink(id)
 }
 
 func GetNode(uid string) (*Node, error) {
-	if isNeo4j {
-		node, err := graph.GetNode(uid)
-		return switchNode(node), err
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.GetNode(uid)
 }
 
 func GetNodeByID(id int64) (*Node, error) {
-	if isNeo4j {
-		node, err := graph.GetNodeByID(id)
-		return switchNode(node), err
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.GetNodeByID(id)
 }
 
 func GetLink(from, to string) (*Link, error) {
-	if isNeo4j {
-		link, err := graph.GetLink(from, to)
-		return switchLink(link), err
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.GetLink(from, to)
 }
 
 func GetLinkByID(id int64) (*Link, error) {
-	if isNeo4j {
-		link, err := graph.GetLinkByID(id)
-		return switchLink(link), err
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	return graphStore.GetLinkByID(id)
+}
+
+func GetAllNodes() ([]*Node, error) {
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	return graphStore.GetAllNodes()
+}
+
+func GetAllLinks() ([]*Link, error) {
+	if err := checkGraph(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not support db")
+	return graphStore.GetAllLinks()
 }
 
 func FindPath(from, to string) (*Graph, error) {
-	var tmp = new(Graph)
-	tmp.construct()
-	tmp.Center = from
-	var err error
-	if isNeo4j {
-		nodes, links, err1 := graph.FindPath(from, to)
-		for i := 0; i < len(nodes); i += 1 {
-			tmp.AddNode(switchNode(nodes[i]))
-		}
-		for i := 0; i < len(links); i += 1 {
-			tmp.AddLink(switchLink(links[i]))
-		}
-		err = err1
-	}
-	return tmp, err
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	return graphStore.FindPath(from, to)
+}
+
+/**
+查询两个节点之间距离最短的多条路径，距离相同时经过的关系少的优先
+*/
+func FindPaths(from, to string, option *PathOption) ([]*Path, error) {
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	if option == nil {
+		option = new(PathOption)
+	}
+	if option.Count < 1 {
+		option.Count = 1
+	}
+	if option.Count > maxPathCount {
+		return nil, errors.New("the count of path is more than the max")
+	}
+	return graphStore.FindPaths(from, to, option)
+}
+
+/**
+查询节点在指定深度内的邻居，relations为允许的关系分类，labels为允许的节点标签，为空时不限制，limit为节点数量上限
+*/
+func FindNeighborhood(uid string, depth int, relations, labels []string, limit int) (*Graph, error) {
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	if depth < 1 || depth > maxPathDepth {
+		return nil, errors.New("the depth is out of range")
+	}
+	return graphStore.FindNeighborhood(uid, depth, relations, labels, limit)
 }
 
 func FindGraph(uid, label string) (*Graph, error) {
-	var tmp = new(Graph)
-	tmp.construct()
-	tmp.Center = uid
-	var err error
-	if isNeo4j {
-		nodes, links, err1 := graph.FindGraph(uid, label)
-		for i := 0; i < len(nodes); i += 1 {
-			tmp.AddNode(switchNode(nodes[i]))
-		}
-		for i := 0; i < len(links); i += 1 {
-			tmp.AddLink(switchLink(links[i]))
-		}
-		err = err1
-	}
-	return tmp, err
+	if err := checkGraph(); err != nil {
+		return nil, err
+	}
+	return graphStore.FindGraph(uid, label)
 }
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"errors"
+	"omo.msa.vocabulary/config"
+	"omo.msa.vocabulary/proxy/graph"
+)
+
+const (
+	GraphNeo4j  = "neo4j"
+	GraphMemory = "memory"
+)
+
+/**
+图数据库后端，节点对应实体，边对应实体之间的关系
+*/
+type GraphStore interface {
+	CreateNode(name, label, uid string) (*Node, error)
+	CreateLink(from, to int64, kind, name, relation string, direction uint8, weight uint32) (*Link, error)
+	AddLabel(id int64, label string) (*Node, error)
+	UpdateNode(id int64, name, cover string) (*Node, error)
+	GetNode(uid string) (*Node, error)
+	GetNodeByID(id int64) (*Node, error)
+	GetLink(from, to string) (*Link, error)
+	GetLinkByID(id int64) (*Link, error)
+	DeleteNode(id int64, label string) error
+	DeleteLink(id int64) error
+	GetAllNodes() ([]*Node, error)
+	GetAllLinks() ([]*Link, error)
+	FindPath(from, to string) (*Graph, error)
+	FindPaths(from, to string, option *PathOption) ([]*Path, error)
+	FindGraph(uid, label string) (*Graph, error)
+	FindNeighborhood(uid string, depth int, relations, labels []string, limit int) (*Graph, error)
+}
+
+var graphStore GraphStore
+
+func InitGraph(conf *config.GraphConfig) error {
+	switch conf.Type {
+	case "", GraphNeo4j:
+		err := graph.InitNeo4J(conf)
+		if err != nil {
+			return err
+		}
+		graphStore = new(neo4jGraph)
+	case GraphMemory:
+		graphStore = newMemoryGraph()
+	default:
+		return errors.New("not support the graph type of " + conf.Type)
+	}
+	return nil
+}
+
+func checkGraph() error {
+	if graphStore == nil {
+		return errors.New("the graph store is nil that init first")
+	}
+	return nil
+}
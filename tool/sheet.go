@@ -0,0 +1,201 @@
+package tool
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/**
+读取CSV表格，兼容带BOM的UTF-8文件
+*/
+func ReadCSV(body []byte) ([][]string, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.ReadAll()
+}
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:"t"`
+	} `xml:"is"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelations struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+/**
+读取Excel(xlsx)文件中第一个工作表的内容，日期单元格保持Excel的天数
+*/
+func ReadExcel(body []byte) ([][]string, error) {
+	table, _, err := ReadExcelCells(body)
+	return table, err
+}
+
+/**
+读取Excel(xlsx)文件中第一个工作表的内容，同时返回每个单元格是否为数字，日期单元格是数字类型的天数
+*/
+func ReadExcelCells(body []byte) ([][]string, [][]bool, error) {
+	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, nil, errors.New("the file is not a xlsx")
+	}
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	shared := new(xlsxSharedStrings)
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err = readZipXML(file, shared); err != nil {
+			return nil, nil, err
+		}
+	}
+	name, err := firstSheet(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, ok := files[name]
+	if !ok {
+		return nil, nil, errors.New("not found the sheet of xlsx")
+	}
+	sheet := new(xlsxSheet)
+	if err = readZipXML(file, sheet); err != nil {
+		return nil, nil, err
+	}
+	table := make([][]string, 0, len(sheet.Rows))
+	numbers := make([][]bool, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		line := make([]string, 0, len(row.Cells))
+		flags := make([]bool, 0, len(row.Cells))
+		for i, cell := range row.Cells {
+			col := i
+			if len(cell.Ref) > 0 {
+				col = columnIndex(cell.Ref)
+			}
+			for len(line) < col {
+				line = append(line, "")
+				flags = append(flags, false)
+			}
+			line = append(line, cellValue(cell, shared))
+			flags = append(flags, cell.Type == "" || cell.Type == "n")
+		}
+		table = append(table, line)
+		numbers = append(numbers, flags)
+	}
+	return table, numbers, nil
+}
+
+func firstSheet(files map[string]*zip.File) (string, error) {
+	book := new(xlsxWorkbook)
+	rels := new(xlsxRelations)
+	file, ok1 := files["xl/workbook.xml"]
+	relFile, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok1 || !ok2 {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+	if err := readZipXML(file, book); err != nil {
+		return "", err
+	}
+	if err := readZipXML(relFile, rels); err != nil {
+		return "", err
+	}
+	if len(book.Sheets) < 1 {
+		return "", errors.New("the xlsx has not any sheet")
+	}
+	for _, item := range rels.Items {
+		if item.ID == book.Sheets[0].ID {
+			if strings.HasPrefix(item.Target, "/") {
+				return strings.TrimPrefix(item.Target, "/"), nil
+			}
+			return path.Join("xl", item.Target), nil
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+func readZipXML(file *zip.File, data interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(reader, 256<<20))
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(body, data)
+}
+
+func cellValue(cell xlsxCell, shared *xlsxSharedStrings) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(shared.Items) {
+			return ""
+		}
+		item := shared.Items[index]
+		if len(item.Runs) < 1 {
+			return item.Text
+		}
+		builder := new(strings.Builder)
+		for _, run := range item.Runs {
+			builder.WriteString(run.Text)
+		}
+		return builder.String()
+	case "inlineStr":
+		return cell.Inline.Text
+	default:
+		return cell.Value
+	}
+}
+
+/**
+单元格引用（例如AB12）对应的列序号，从0开始
+*/
+func columnIndex(ref string) int {
+	index := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		index = index*26 + int(ch-'A') + 1
+	}
+	return index - 1
+}